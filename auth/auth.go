@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+)
+
+var (
+	errNoChallenge          = errors.New("No supported authentication challenge")
+	errUnsupportedAlgorithm = errors.New("Unsupported digest algorithm")
+)
+
+// Authentication schemes
+const (
+	SchemeBasic  = "Basic"
+	SchemeDigest = "Digest"
+)
+
+// Challenge is the WWW-Authenticate challenge of HTTP or RTSP server.
+// It is reused for subsequent requests, counting the digest nonce uses.
+type Challenge struct {
+	Scheme string
+
+	Realm     string
+	Nonce     string
+	Opaque    string
+	Algorithm string
+	QOP       []string
+	Stale     bool
+
+	mu         sync.Mutex
+	nonceCount uint32
+}
+
+// ParseChallenge parses WWW-Authenticate header value
+func ParseChallenge(header string) (*Challenge, error) {
+	header = strings.TrimSpace(header)
+	i := strings.IndexByte(header, ' ')
+	scheme := header
+	if i >= 0 {
+		scheme = header[:i]
+	}
+
+	c := &Challenge{}
+	switch {
+	case strings.EqualFold(scheme, SchemeBasic):
+		c.Scheme = SchemeBasic
+	case strings.EqualFold(scheme, SchemeDigest):
+		c.Scheme = SchemeDigest
+	default:
+		return nil, errNoChallenge
+	}
+	if i < 0 {
+		return c, nil
+	}
+
+	for name, value := range parseParams(header[i+1:]) {
+		switch name {
+		case "realm":
+			c.Realm = value
+		case "nonce":
+			c.Nonce = value
+		case "opaque":
+			c.Opaque = value
+		case "algorithm":
+			c.Algorithm = value
+		case "qop":
+			for _, q := range strings.Split(value, ",") {
+				c.QOP = append(c.QOP, strings.TrimSpace(q))
+			}
+		case "stale":
+			c.Stale = strings.EqualFold(value, "true")
+		}
+	}
+
+	if c.Scheme == SchemeDigest {
+		if _, err := c.hash(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// SelectChallenge returns the strongest supported challenge of the WWW-Authenticate headers
+func SelectChallenge(headers []string) (*Challenge, error) {
+	var basic *Challenge
+	for _, h := range headers {
+		c, err := ParseChallenge(h)
+		if err != nil {
+			continue
+		}
+		if c.Scheme == SchemeDigest {
+			return c, nil
+		}
+		basic = c
+	}
+	if basic == nil {
+		return nil, errNoChallenge
+	}
+	return basic, nil
+}
+
+// Authorization returns the Authorization header value of the request. The uri is
+// the request URI for HTTP, e.g. "/snapshot.jpg", and the full URL for RTSP.
+func (c *Challenge) Authorization(username, password, method, uri string) string {
+	if c.Scheme == SchemeBasic {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	}
+
+	h, _ := c.hash()
+	digest := func(s string) string {
+		h.Reset()
+		h.Write([]byte(s))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	c.mu.Lock()
+	c.nonceCount++
+	nc := fmt.Sprintf("%08x", c.nonceCount)
+	c.mu.Unlock()
+
+	cnonce := newCnonce()
+	ha1 := digest(username + ":" + c.Realm + ":" + password)
+	if strings.HasSuffix(strings.ToLower(c.Algorithm), "-sess") {
+		ha1 = digest(ha1 + ":" + c.Nonce + ":" + cnonce)
+	}
+	ha2 := digest(method + ":" + uri)
+
+	qop := ""
+	for _, q := range c.QOP {
+		if q == "auth" {
+			qop = q
+		}
+	}
+
+	var response string
+	if qop == "" {
+		response = digest(ha1 + ":" + c.Nonce + ":" + ha2)
+	} else {
+		response = digest(ha1 + ":" + c.Nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	params := []string{
+		param("username", username),
+		param("realm", c.Realm),
+		param("nonce", c.Nonce),
+		param("uri", uri),
+		param("response", response),
+	}
+	if c.Algorithm != "" {
+		params = append(params, "algorithm="+c.Algorithm)
+	}
+	if c.Opaque != "" {
+		params = append(params, param("opaque", c.Opaque))
+	}
+	if qop != "" {
+		params = append(params, "qop="+qop, "nc="+nc, param("cnonce", cnonce))
+	}
+	return "Digest " + strings.Join(params, ", ")
+}
+
+// hash returns hash of the digest algorithm
+func (c *Challenge) hash() (hash.Hash, error) {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(c.Algorithm), "-sess")) {
+	case "", "MD5":
+		return md5.New(), nil
+	case "SHA-256":
+		return sha256.New(), nil
+	}
+	return nil, errUnsupportedAlgorithm
+}
+
+func param(name, value string) string {
+	return name + `="` + strings.Replace(value, `"`, `\"`, -1) + `"`
+}
+
+func newCnonce() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parseParams parses comma separated name=value pairs with optionally quoted values
+func parseParams(s string) map[string]string {
+	params := map[string]string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,\t")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			b := strings.Builder{}
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			value = b.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[name] = value
+	}
+	return params
+}
@@ -0,0 +1,474 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/videonext/onvif/profiles/event"
+)
+
+var errBridgeClosed = errors.New("Bridge is closed")
+
+// Event is the notification forwarded to the sinks as JSON
+type Event struct {
+	// Device identifier given to Publish or Attach
+	Device string `json:"device"`
+
+	// Output topic produced by the TopicMapper
+	Topic string `json:"topic"`
+
+	// Topic of the notification, e.g. "tns1:VideoSource/MotionAlarm"
+	ONVIFTopic string `json:"onvifTopic"`
+
+	Time time.Time `json:"time"`
+
+	PropertyOperation event.PropertyOperation `json:"propertyOperation,omitempty"`
+
+	// SimpleItem values by name
+	Source map[string]string `json:"source,omitempty"`
+	Key    map[string]string `json:"key,omitempty"`
+	Data   map[string]string `json:"data,omitempty"`
+
+	// ElementItem XML content by name
+	Elements map[string]string `json:"elements,omitempty"`
+
+	// Typed event decoded with the registry, e.g. *event.MotionAlarm
+	Decoded interface{} `json:"decoded,omitempty"`
+}
+
+// NewEvent converts the notification of the device into Event.
+// Topic is set to the ONVIF topic, the Bridge replaces it with the mapped one.
+func NewEvent(device string, n *event.NotificationMessage) *Event {
+	m := &n.Message.Message
+	e := &Event{
+		Device:            device,
+		ONVIFTopic:        n.Topic.Canonical(),
+		PropertyOperation: m.PropertyOperation,
+		Source:            items(m.Source.SimpleItem),
+		Key:               items(m.Key.SimpleItem),
+		Data:              items(m.Data.SimpleItem),
+	}
+	e.Topic = e.ONVIFTopic
+
+	t, err := m.Time()
+	if err != nil {
+		t = time.Now()
+	}
+	e.Time = t.UTC()
+
+	for _, i := range m.Data.ElementItem {
+		if e.Elements == nil {
+			e.Elements = map[string]string{}
+		}
+		e.Elements[i.Name] = strings.TrimSpace(i.InnerXML)
+	}
+	return e
+}
+
+func items(l []event.SimpleItem) map[string]string {
+	if len(l) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(l))
+	for _, i := range l {
+		m[i.Name] = i.Value
+	}
+	return m
+}
+
+// Sink receives batches of events, e.g. WebhookSink or MQTTSink
+type Sink interface {
+	// Name identifies the sink in the stats and the dead-letter file
+	Name() string
+
+	// Send delivers the batch. The whole batch is retried on error,
+	// so the events may be delivered more than once.
+	Send(ctx context.Context, events []*Event) error
+}
+
+// TopicMapper returns output topic of the device notification topic.
+// Events mapped to empty topic are dropped.
+type TopicMapper func(device, topic string) string
+
+// DefaultTopicMapper maps topics to MQTT style topics under the prefix,
+// e.g. "onvif/camera1/tns1/VideoSource/MotionAlarm" for prefix "onvif"
+func DefaultTopicMapper(prefix string) TopicMapper {
+	return func(device, topic string) string {
+		parts := []string{}
+		if prefix != "" {
+			parts = append(parts, prefix)
+		}
+		parts = append(parts, topicLevel(device), strings.Replace(topic, ":", "/", 1))
+		return strings.Join(parts, "/")
+	}
+}
+
+// MapTopics returns TopicMapper using the table of topic patterns, either exact topics
+// or prefixes ending with "/*" like "tns1:RuleEngine/*". The longest match wins.
+// The "{device}" placeholder of the output topic is replaced with the device.
+// Unmatched topics are passed to the fallback, or dropped if it is nil.
+func MapTopics(table map[string]string, fallback TopicMapper) TopicMapper {
+	return func(device, topic string) string {
+		out, ok := table[topic]
+		for prefix := topic; !ok; {
+			i := strings.LastIndex(prefix, "/")
+			if i < 0 {
+				break
+			}
+			prefix = prefix[:i]
+			out, ok = table[prefix+"/*"]
+		}
+		if !ok {
+			if fallback == nil {
+				return ""
+			}
+			return fallback(device, topic)
+		}
+		return strings.Replace(out, "{device}", topicLevel(device), -1)
+	}
+}
+
+// topicLevel replaces characters not allowed in the MQTT topic level
+func topicLevel(s string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(s)
+}
+
+type options struct {
+	topicMapper   TopicMapper
+	registry      *event.Registry
+	queueSize     int
+	batchSize     int
+	flushInterval time.Duration
+	attempts      int
+	backoff       time.Duration
+	sendTimeout   time.Duration
+	deadLetter    string
+}
+
+var defaultOptions = options{
+	topicMapper:   DefaultTopicMapper("onvif"),
+	registry:      event.DefaultRegistry,
+	queueSize:     1000,
+	batchSize:     100,
+	flushInterval: time.Second,
+	attempts:      3,
+	backoff:       time.Second,
+	sendTimeout:   10 * time.Second,
+}
+
+// Option type
+type Option func(*options)
+
+// WithTopicMapper is an Option to set the mapping of output topics
+func WithTopicMapper(m TopicMapper) Option {
+	return func(o *options) {
+		o.topicMapper = m
+	}
+}
+
+// WithRegistry is an Option to set the registry decoding typed events, nil disables decoding
+func WithRegistry(r *event.Registry) Option {
+	return func(o *options) {
+		o.registry = r
+	}
+}
+
+// WithQueueSize is an Option to set the number of events queued per sink,
+// events are dropped for the sink when its queue is full
+func WithQueueSize(n int) Option {
+	return func(o *options) {
+		o.queueSize = n
+	}
+}
+
+// WithBatch is an Option to send up to size events at once, waiting
+// at most interval for the batch to fill
+func WithBatch(size int, interval time.Duration) Option {
+	return func(o *options) {
+		o.batchSize = size
+		o.flushInterval = interval
+	}
+}
+
+// WithRetry is an Option to set the number of send attempts and the delay
+// before the first retry, doubled with every next retry
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.attempts = attempts
+		o.backoff = backoff
+	}
+}
+
+// WithSendTimeout is an Option to set the timeout of a single send attempt
+func WithSendTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.sendTimeout = d
+	}
+}
+
+// WithDeadLetter is an Option to append the events failed after all attempts
+// to the file, one JSON DeadLetter per line
+func WithDeadLetter(path string) Option {
+	return func(o *options) {
+		o.deadLetter = path
+	}
+}
+
+// DeadLetter is the record of the dead-letter file
+type DeadLetter struct {
+	Sink  string    `json:"sink"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+	Event *Event    `json:"event"`
+}
+
+// SinkStats contains delivery counters of the sink
+type SinkStats struct {
+	Sink string
+
+	// Events delivered
+	Sent uint64
+
+	// Failed send attempts which were retried
+	Retries uint64
+
+	// Events given up after all attempts
+	Failed uint64
+
+	// Events dropped because the queue of the sink was full
+	Dropped uint64
+
+	// Failed events not written to the dead-letter file and the last error
+	DeadLetterFailed uint64
+	DeadLetterError  error
+}
+
+// Bridge forwards notifications of many devices to the sinks. Every sink
+// has its own queue, batches and retries, so a slow sink does not delay the others.
+type Bridge struct {
+	opts  options
+	sinks []*sinkQueue
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+
+	// Publish calls queueing the events
+	publishing sync.WaitGroup
+
+	deadLetterMu sync.Mutex
+}
+
+type sinkQueue struct {
+	sink   Sink
+	events chan *Event
+
+	mu    sync.Mutex
+	stats SinkStats
+}
+
+// New creates Bridge instance and starts delivery to the sinks
+func New(sinks []Sink, opt ...Option) *Bridge {
+	opts := defaultOptions
+	for _, o := range opt {
+		o(&opts)
+	}
+	if opts.batchSize < 1 {
+		opts.batchSize = 1
+	}
+	if opts.attempts < 1 {
+		opts.attempts = 1
+	}
+
+	b := &Bridge{opts: opts}
+	for _, s := range sinks {
+		q := &sinkQueue{sink: s, events: make(chan *Event, opts.queueSize), stats: SinkStats{Sink: s.Name()}}
+		b.sinks = append(b.sinks, q)
+		b.wg.Add(1)
+		go b.run(q)
+	}
+	return b
+}
+
+// Publish forwards the notification of the device to all sinks
+func (b *Bridge) Publish(device string, n *event.NotificationMessage) error {
+	e := NewEvent(device, n)
+	e.Topic = b.opts.topicMapper(device, e.ONVIFTopic)
+	if e.Topic == "" {
+		return nil
+	}
+	if b.opts.registry != nil {
+		if decoded, err := b.opts.registry.Decode(n); err == nil {
+			e.Decoded = decoded
+		}
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return errBridgeClosed
+	}
+	b.publishing.Add(1)
+	b.mu.Unlock()
+	defer b.publishing.Done()
+
+	for _, q := range b.sinks {
+		select {
+		case q.events <- e:
+		default:
+			q.mu.Lock()
+			q.stats.Dropped++
+			q.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+// Attach forwards notifications of the device until the channel is closed,
+// e.g. the channel of event.Subscriber
+func (b *Bridge) Attach(device string, notifications <-chan event.NotificationMessage) {
+	go func() {
+		for n := range notifications {
+			if b.Publish(device, &n) == errBridgeClosed {
+				return
+			}
+		}
+	}()
+}
+
+// Stats returns delivery counters of the sinks
+func (b *Bridge) Stats() []SinkStats {
+	stats := make([]SinkStats, 0, len(b.sinks))
+	for _, q := range b.sinks {
+		q.mu.Lock()
+		stats = append(stats, q.stats)
+		q.mu.Unlock()
+	}
+	return stats
+}
+
+// Close stops accepting events, delivers the queued ones and waits for the sinks.
+// The sinks are not closed.
+func (b *Bridge) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return errBridgeClosed
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	b.publishing.Wait()
+	for _, q := range b.sinks {
+		close(q.events)
+	}
+
+	b.wg.Wait()
+	return nil
+}
+
+func (b *Bridge) run(q *sinkQueue) {
+	defer b.wg.Done()
+
+	var batch []*Event
+	var timer *time.Timer
+	var flush <-chan time.Time
+
+	for {
+		select {
+		case e, ok := <-q.events:
+			if !ok {
+				if timer != nil {
+					timer.Stop()
+				}
+				b.send(q, batch)
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) == 1 {
+				timer = time.NewTimer(b.opts.flushInterval)
+				flush = timer.C
+			}
+			if len(batch) < b.opts.batchSize {
+				continue
+			}
+			timer.Stop()
+		case <-flush:
+		}
+
+		flush = nil
+		b.send(q, batch)
+		batch = nil
+	}
+}
+
+// send delivers the batch with retries, failed events go to the dead-letter file
+func (b *Bridge) send(q *sinkQueue, batch []*Event) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var err error
+	backoff := b.opts.backoff
+	for attempt := 0; attempt < b.opts.attempts; attempt++ {
+		if attempt > 0 {
+			q.mu.Lock()
+			q.stats.Retries++
+			q.mu.Unlock()
+
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), b.opts.sendTimeout)
+		err = q.sink.Send(ctx, batch)
+		cancel()
+		if err == nil {
+			q.mu.Lock()
+			q.stats.Sent += uint64(len(batch))
+			q.mu.Unlock()
+			return
+		}
+	}
+
+	q.mu.Lock()
+	q.stats.Failed += uint64(len(batch))
+	q.mu.Unlock()
+
+	if n, derr := b.writeDeadLetters(q.sink.Name(), err, batch); derr != nil {
+		q.mu.Lock()
+		q.stats.DeadLetterFailed += uint64(len(batch) - n)
+		q.stats.DeadLetterError = derr
+		q.mu.Unlock()
+	}
+}
+
+// writeDeadLetters appends the events to the dead-letter file and returns the number written
+func (b *Bridge) writeDeadLetters(sink string, err error, batch []*Event) (int, error) {
+	if b.opts.deadLetter == "" {
+		return 0, nil
+	}
+
+	b.deadLetterMu.Lock()
+	defer b.deadLetterMu.Unlock()
+
+	f, ferr := os.OpenFile(b.opts.deadLetter, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if ferr != nil {
+		return 0, ferr
+	}
+
+	enc := json.NewEncoder(f)
+	now := time.Now().UTC()
+	for i, e := range batch {
+		if werr := enc.Encode(&DeadLetter{Sink: sink, Error: err.Error(), Time: now, Event: e}); werr != nil {
+			f.Close()
+			return i, werr
+		}
+	}
+	return len(batch), f.Close()
+}
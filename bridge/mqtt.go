@@ -0,0 +1,312 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPuback     = 4
+	mqttPingresp   = 13
+	mqttDisconnect = 14
+)
+
+var errMQTTPacketTooLarge = errors.New("MQTT packet is too large")
+
+// MQTTSink publishes every event as JSON to its topic on the MQTT 3.1.1 broker.
+// The connection is established on the first Send and reestablished after errors.
+type MQTTSink struct {
+	address   string
+	clientID  string
+	username  string
+	password  string
+	qos       byte
+	retain    bool
+	keepAlive time.Duration
+
+	mu       sync.Mutex
+	conn     net.Conn
+	reader   *bufio.Reader
+	packetID uint16
+	lastSent time.Time
+}
+
+// MQTTOption type
+type MQTTOption func(*MQTTSink)
+
+// WithClientID is an MQTTOption to set the client identifier
+func WithClientID(id string) MQTTOption {
+	return func(s *MQTTSink) {
+		s.clientID = id
+	}
+}
+
+// WithMQTTCredentials is an MQTTOption to set the broker user name and password
+func WithMQTTCredentials(username, password string) MQTTOption {
+	return func(s *MQTTSink) {
+		s.username = username
+		s.password = password
+	}
+}
+
+// WithQoS is an MQTTOption to publish with QoS 0 or 1, default is 1
+func WithQoS(qos byte) MQTTOption {
+	return func(s *MQTTSink) {
+		s.qos = qos
+	}
+}
+
+// WithRetain is an MQTTOption to publish retained messages, e.g. for property topics
+func WithRetain(retain bool) MQTTOption {
+	return func(s *MQTTSink) {
+		s.retain = retain
+	}
+}
+
+// WithKeepAlive is an MQTTOption to set the keep alive interval. The connection
+// idle for the interval is reestablished, since the broker may have closed it.
+func WithKeepAlive(d time.Duration) MQTTOption {
+	return func(s *MQTTSink) {
+		s.keepAlive = d
+	}
+}
+
+// NewMQTTSink creates MQTTSink instance. The address is "host:port" or "tcp://host:port".
+func NewMQTTSink(address string, opt ...MQTTOption) *MQTTSink {
+	s := &MQTTSink{
+		address:   strings.TrimPrefix(address, "tcp://"),
+		clientID:  fmt.Sprintf("onvif-bridge-%d", time.Now().UnixNano()),
+		qos:       1,
+		keepAlive: time.Minute,
+	}
+	for _, o := range opt {
+		o(s)
+	}
+	if s.qos > 1 {
+		s.qos = 1
+	}
+	return s
+}
+
+// Name returns the broker address
+func (s *MQTTSink) Name() string {
+	return "mqtt://" + s.address
+}
+
+// Send publishes the events to their topics
+func (s *MQTTSink) Send(ctx context.Context, events []*Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil && s.keepAlive > 0 && time.Since(s.lastSent) >= s.keepAlive {
+		s.closeConn()
+	}
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetDeadline(deadline)
+	} else {
+		s.conn.SetDeadline(time.Time{})
+	}
+
+	for _, e := range events {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := s.publish(e.Topic, payload); err != nil {
+			s.closeConn()
+			return err
+		}
+	}
+	return nil
+}
+
+// Close disconnects from the broker
+func (s *MQTTSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	s.conn.SetDeadline(time.Now().Add(time.Second))
+	s.writePacket(mqttDisconnect<<4, nil)
+	s.closeConn()
+	return nil
+}
+
+func (s *MQTTSink) connect(ctx context.Context) error {
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+
+	flags := byte(0x02) // clean session
+	payload := mqttString(s.clientID)
+	if s.username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(s.username)...)
+		if s.password != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(s.password)...)
+		}
+	}
+
+	body := mqttString("MQTT")
+	body = append(body, 4, flags)
+	body = append(body, byte(s.keepAlive/time.Second>>8), byte(s.keepAlive/time.Second))
+	body = append(body, payload...)
+
+	if err := s.writePacket(mqttConnect<<4, body); err != nil {
+		s.closeConn()
+		return err
+	}
+
+	packetType, ack, err := s.readPacket()
+	if err != nil {
+		s.closeConn()
+		return err
+	}
+	if packetType != mqttConnack || len(ack) != 2 {
+		s.closeConn()
+		return errors.New("MQTT broker did not acknowledge the connection")
+	}
+	if ack[1] != 0 {
+		s.closeConn()
+		return fmt.Errorf("MQTT broker refused the connection, return code %d", ack[1])
+	}
+	return nil
+}
+
+func (s *MQTTSink) publish(topic string, payload []byte) error {
+	header := byte(mqttPublish<<4) | s.qos<<1
+	if s.retain {
+		header |= 0x01
+	}
+
+	body := mqttString(topic)
+	if s.qos > 0 {
+		s.packetID++
+		if s.packetID == 0 {
+			s.packetID = 1
+		}
+		body = append(body, byte(s.packetID>>8), byte(s.packetID))
+	}
+	body = append(body, payload...)
+
+	if err := s.writePacket(header, body); err != nil {
+		return err
+	}
+	if s.qos == 0 {
+		return nil
+	}
+
+	for {
+		packetType, ack, err := s.readPacket()
+		if err != nil {
+			return err
+		}
+		switch packetType {
+		case mqttPuback:
+			if len(ack) == 2 && binary.BigEndian.Uint16(ack) == s.packetID {
+				return nil
+			}
+		case mqttPingresp:
+		default:
+			return fmt.Errorf("Unexpected MQTT packet type %d", packetType)
+		}
+	}
+}
+
+func (s *MQTTSink) writePacket(header byte, body []byte) error {
+	if len(body) > 268435455 {
+		return errMQTTPacketTooLarge
+	}
+
+	packet := []byte{header}
+	for n := len(body); ; {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	packet = append(packet, body...)
+
+	if _, err := s.conn.Write(packet); err != nil {
+		return err
+	}
+	s.lastSent = time.Now()
+	return nil
+}
+
+func (s *MQTTSink) readPacket() (byte, []byte, error) {
+	header, err := s.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length := 0
+	for shift := uint(0); ; shift += 7 {
+		if shift > 21 {
+			return 0, nil, errMQTTPacketTooLarge
+		}
+		b, err := s.reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+func (s *MQTTSink) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+		s.reader = nil
+	}
+}
+
+// mqttString encodes the UTF-8 string with the length prefix
+func mqttString(s string) []byte {
+	b := make([]byte, 2, 2+len(s))
+	binary.BigEndian.PutUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
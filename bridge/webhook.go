@@ -0,0 +1,107 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// WebhookSink posts batches of events as JSON array to the URL
+type WebhookSink struct {
+	url    string
+	name   string
+	client *http.Client
+	header http.Header
+}
+
+// WebhookOption type
+type WebhookOption func(*WebhookSink)
+
+// WithHTTPClient is a WebhookOption to set the HTTP client
+func WithHTTPClient(c *http.Client) WebhookOption {
+	return func(s *WebhookSink) {
+		s.client = c
+	}
+}
+
+// WithHeader is a WebhookOption to add the request header, e.g. Authorization
+func WithHeader(key, value string) WebhookOption {
+	return func(s *WebhookSink) {
+		s.header.Add(key, value)
+	}
+}
+
+// WithName is a WebhookOption to set the name of the sink used in the statistics
+// and dead letters, by default it is the URL without credentials and query
+func WithName(name string) WebhookOption {
+	return func(s *WebhookSink) {
+		s.name = name
+	}
+}
+
+// NewWebhookSink creates WebhookSink instance
+func NewWebhookSink(rawurl string, opt ...WebhookOption) *WebhookSink {
+	s := &WebhookSink{url: rawurl, name: redactURL(rawurl), client: http.DefaultClient, header: http.Header{}}
+	for _, o := range opt {
+		o(s)
+	}
+	return s
+}
+
+// Name returns the name set with WithName or the URL without credentials and query,
+// they may contain secrets, e.g. API tokens
+func (s *WebhookSink) Name() string {
+	return s.name
+}
+
+// Send posts the events, any 2xx status is success
+func (s *WebhookSink) Send(ctx context.Context, events []*Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range s.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// the error is written to the dead letters
+		if e, ok := err.(*url.Error); ok {
+			e.URL = redactURL(e.URL)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// redactURL removes the user info, query and fragment of the URL
+func redactURL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "webhook"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	return u.String()
+}
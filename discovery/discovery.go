@@ -3,25 +3,35 @@ package discovery
 import (
 	"errors"
 	"net"
-	"regexp"
-	"strings"
 	"time"
+)
 
-	"github.com/clbanning/mxj"
-	uuid "github.com/satori/go.uuid"
+var (
+	errWrongDiscoveryResponse = errors.New("Response is not related to discovery request")
+	errNoXAddr                = errors.New("Device does not have any xAddr")
 )
 
-var errWrongDiscoveryResponse = errors.New("Response is not related to discovery request")
+// WS-Discovery multicast group
+var multicastAddress = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 3702}
 
 // Device contains data of ONVIF camera
 type Device struct {
-	ID    string
-	Name  string
-	XAddr string
+	ID     string
+	Name   string
+	XAddr  string
+	XAddrs []string
+	Types  QNames
+	Scopes []string
 }
 
-// StartDiscovery send a WS-Discovery message and wait for all matching device to respond
-func StartDiscovery(duration time.Duration) ([]Device, error) {
+// StartDiscovery send a WS-Discovery message and wait for all matching device to respond.
+// By default it probes for dn:NetworkVideoTransmitter, use WithTypes and WithScopes to change that.
+func StartDiscovery(duration time.Duration, opt ...Option) ([]Device, error) {
+	opts := defaultProbeOptions
+	for _, o := range opt {
+		o(&opts)
+	}
+
 	// Get list of interface address
 	addrs, err := net.InterfaceAddrs()
 	if err != nil {
@@ -42,10 +52,10 @@ func StartDiscovery(duration time.Duration) ([]Device, error) {
 
 	// Discover device on each interface's network
 	for _, ipAddr := range ipAddrs {
-		devices, err := discoverDevices(ipAddr, duration)
-		if err != nil {
-			//			return []Device{}, err
-			continue
+		// Devices received before the error are kept
+		devices, derr := discoverDevices(ipAddr, duration, &opts)
+		if derr != nil {
+			err = derr
 		}
 
 		for _, dd := range devices {
@@ -72,40 +82,16 @@ func StartDiscovery(duration time.Duration) ([]Device, error) {
 	return discoveryResults, nil
 }
 
-func discoverDevices(ipAddr string, duration time.Duration) ([]Device, error) {
+func discoverDevices(ipAddr string, duration time.Duration, opts *probeOptions) ([]Device, error) {
 	// Create WS-Discovery request
-	requestID := "uuid:" + uuid.NewV4().String()
-	request := `		
-		<?xml version="1.0" encoding="UTF-8"?>
-		<e:Envelope
-		    xmlns:e="http://www.w3.org/2003/05/soap-envelope"
-		    xmlns:w="http://schemas.xmlsoap.org/ws/2004/08/addressing"
-		    xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery"
-		    xmlns:dn="http://www.onvif.org/ver10/network/wsdl">
-		    <e:Header>
-		        <w:MessageID>` + requestID + `</w:MessageID>
-		        <w:To e:mustUnderstand="true">urn:schemas-xmlsoap-org:ws:2005:04:discovery</w:To>
-		        <w:Action a:mustUnderstand="true">http://schemas.xmlsoap.org/ws/2005/04/discovery/Probe
-		        </w:Action>
-		    </e:Header>
-		    <e:Body>
-		        <d:Probe>
-		            <d:Types>dn:NetworkVideoTransmitter</d:Types>
-		        </d:Probe>
-		    </e:Body>
-		</e:Envelope>`
-
-	// Clean WS-Discovery message
-	request = regexp.MustCompile(`\>\s+\<`).ReplaceAllString(request, "><")
-	request = regexp.MustCompile(`\s+`).ReplaceAllString(request, " ")
-
-	// Create UDP address for local and multicast address
-	localAddress, err := net.ResolveUDPAddr("udp4", ipAddr+":0")
+	requestID := newMessageID()
+	request, err := marshalMessage(requestID, ActionProbe, ToDiscovery, "", nil, newProbe(opts))
 	if err != nil {
 		return []Device{}, err
 	}
 
-	multicastAddress, err := net.ResolveUDPAddr("udp4", "239.255.255.250:3702")
+	// Create UDP address for local and multicast address
+	localAddress, err := net.ResolveUDPAddr("udp4", ipAddr+":0")
 	if err != nil {
 		return []Device{}, err
 	}
@@ -124,7 +110,7 @@ func discoverDevices(ipAddr string, duration time.Duration) ([]Device, error) {
 	}
 
 	// Send WS-Discovery request to multicast address
-	_, err = conn.WriteToUDP([]byte(request), multicastAddress)
+	_, err = conn.WriteToUDP(request, multicastAddress)
 	if err != nil {
 		return []Device{}, err
 	}
@@ -136,10 +122,8 @@ func discoverDevices(ipAddr string, duration time.Duration) ([]Device, error) {
 	for {
 		// Create buffer and receive UDP response
 		buffer := make([]byte, 10*1024)
-		_, _, err = conn.ReadFromUDP(buffer)
+		n, _, err := conn.ReadFromUDP(buffer)
 
-		//fmt.Println(string(buffer))
-		//		pretty.Println(buffer)
 		// Check if connection timeout
 		if err != nil {
 			if udpErr, ok := err.(net.Error); ok && udpErr.Timeout() {
@@ -150,63 +134,38 @@ func discoverDevices(ipAddr string, duration time.Duration) ([]Device, error) {
 		}
 
 		// Read and parse WS-Discovery response
-		device, err := readDiscoveryResponse(requestID, buffer)
-		if err != nil && err != errWrongDiscoveryResponse {
-			return discoveryResults, err
+		// Skip responses to other requests, unparsable ones and
+		// matches without XAddrs, which are to be resolved
+		devices, err := readDiscoveryResponse(requestID, buffer[:n])
+		if err != nil {
+			continue
 		}
 
-		// Push device to results
-		discoveryResults = append(discoveryResults, device)
+		for _, device := range devices {
+			// Skip devices which ignored the probe types or scopes
+			if !opts.match(&device) {
+				continue
+			}
+
+			// Push device to results
+			discoveryResults = append(discoveryResults, device)
+		}
 	}
 
 	return discoveryResults, nil
 }
 
 // readDiscoveryResponse reads and parses WS-Discovery response
-func readDiscoveryResponse(messageID string, buffer []byte) (Device, error) {
-	// Inital result
-	result := Device{}
-
-	//	fmt.Println("!!!!readDiscoveryResponse")
-
-	// Parse XML to map
-	mapXML, err := mxj.NewMapXml(buffer)
+func readDiscoveryResponse(messageID string, buffer []byte) ([]Device, error) {
+	msg, err := ParseMessage(buffer)
 	if err != nil {
-		return result, err
+		return []Device{}, err
 	}
 
 	// Check if this response is for our request
-	responseMessageID, _ := mapXML.ValueForPathString("Envelope.Header.RelatesTo")
-	if responseMessageID != messageID {
-		return result, errWrongDiscoveryResponse
+	if msg.Header.RelatesTo != messageID || msg.Body.ProbeMatches == nil {
+		return []Device{}, errWrongDiscoveryResponse
 	}
 
-	// Get device's ID and clean it
-	deviceID, _ := mapXML.ValueForPathString("Envelope.Body.ProbeMatches.ProbeMatch.EndpointReference.Address")
-	deviceID = strings.Replace(deviceID, "urn:uuid:", "", 1)
-
-	// Get device's name
-	deviceName := ""
-	scopes, _ := mapXML.ValueForPathString("Envelope.Body.ProbeMatches.ProbeMatch.Scopes")
-	for _, scope := range strings.Split(scopes, " ") {
-		if strings.HasPrefix(scope, "onvif://www.onvif.org/name/") {
-			deviceName = strings.Replace(scope, "onvif://www.onvif.org/name/", "", 1)
-			deviceName = strings.Replace(deviceName, "_", " ", -1)
-			break
-		}
-	}
-
-	// Get device's xAddrs
-	xAddrs, _ := mapXML.ValueForPathString("Envelope.Body.ProbeMatches.ProbeMatch.XAddrs")
-	listXAddr := strings.Split(xAddrs, " ")
-	if len(listXAddr) == 0 {
-		return result, errors.New("Device does not have any xAddr")
-	}
-
-	// Finalize result
-	result.ID = deviceID
-	result.Name = deviceName
-	result.XAddr = listXAddr[0]
-
-	return result, nil
+	return msg.Devices()
 }
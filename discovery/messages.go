@@ -0,0 +1,358 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/videonext/onvif/soap"
+)
+
+// WS-Discovery and WS-Addressing namespaces and actions
+const (
+	NsDiscovery  = "http://schemas.xmlsoap.org/ws/2005/04/discovery"
+	NsAddressing = "http://schemas.xmlsoap.org/ws/2004/08/addressing"
+
+	ActionProbe          = NsDiscovery + "/Probe"
+	ActionProbeMatches   = NsDiscovery + "/ProbeMatches"
+	ActionResolve        = NsDiscovery + "/Resolve"
+	ActionResolveMatches = NsDiscovery + "/ResolveMatches"
+	ActionHello          = NsDiscovery + "/Hello"
+	ActionBye            = NsDiscovery + "/Bye"
+
+	// ToDiscovery is the To header of multicast messages
+	ToDiscovery = "urn:schemas-xmlsoap-org:ws:2005:04:discovery"
+	// ToAnonymous is the To header of responses
+	ToAnonymous = NsAddressing + "/role/anonymous"
+)
+
+// MessageID type
+type MessageID struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/08/addressing MessageID"`
+
+	Value string `xml:",chardata"`
+}
+
+// RelatesTo type
+type RelatesTo struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/08/addressing RelatesTo"`
+
+	Value string `xml:",chardata"`
+}
+
+// To type
+type To struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/08/addressing To"`
+
+	MustUnderstand string `xml:"http://www.w3.org/2003/05/soap-envelope mustUnderstand,attr,omitempty"`
+
+	Value string `xml:",chardata"`
+}
+
+// Action type
+type Action struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/08/addressing Action"`
+
+	MustUnderstand string `xml:"http://www.w3.org/2003/05/soap-envelope mustUnderstand,attr,omitempty"`
+
+	Value string `xml:",chardata"`
+}
+
+// EndpointReference type
+type EndpointReference struct {
+	Address string `xml:"http://schemas.xmlsoap.org/ws/2004/08/addressing Address"`
+}
+
+// Scopes type
+type Scopes struct {
+	MatchBy string `xml:"MatchBy,attr,omitempty"`
+
+	Value string `xml:",chardata"`
+}
+
+// QNames is a list of qualified names, like the Types of a Probe.
+// Namespace prefixes are declared on the element itself when marshalled.
+type QNames []xml.Name
+
+// MarshalXML implements xml.Marshaler
+func (q QNames) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	prefixes := map[string]string{}
+	names := []string{}
+	for _, n := range q {
+		prefix, ok := prefixes[n.Space]
+		if !ok {
+			if prefix, ok = knownPrefixes[n.Space]; !ok {
+				prefix = "ns" + strconv.Itoa(len(prefixes))
+			}
+			prefixes[n.Space] = prefix
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: n.Space})
+		}
+		names = append(names, prefix+":"+n.Local)
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeToken(xml.CharData(strings.Join(names, " "))); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Well-known prefixes are resolved, otherwise the prefix is kept as the Space.
+func (q *QNames) UnmarshalText(text []byte) error {
+	*q = nil
+	for _, f := range strings.Fields(string(text)) {
+		n := xml.Name{Local: f}
+		if i := strings.Index(f, ":"); i >= 0 {
+			n.Space, n.Local = f[:i], f[i+1:]
+			for ns, prefix := range knownPrefixes {
+				if prefix == n.Space {
+					n.Space = ns
+					break
+				}
+			}
+		}
+		*q = append(*q, n)
+	}
+	return nil
+}
+
+// Probe type
+type Probe struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Probe"`
+
+	Types QNames `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Types,omitempty"`
+
+	Scopes *Scopes `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Scopes,omitempty"`
+}
+
+// ProbeMatch type
+type ProbeMatch struct {
+	EndpointReference EndpointReference `xml:"http://schemas.xmlsoap.org/ws/2004/08/addressing EndpointReference"`
+
+	Types QNames `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Types,omitempty"`
+
+	Scopes Scopes `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Scopes,omitempty"`
+
+	XAddrs string `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery XAddrs,omitempty"`
+
+	MetadataVersion uint32 `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery MetadataVersion"`
+}
+
+// ProbeMatches type
+type ProbeMatches struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery ProbeMatches"`
+
+	ProbeMatch []ProbeMatch `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery ProbeMatch,omitempty"`
+}
+
+// Resolve type
+type Resolve struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Resolve"`
+
+	EndpointReference EndpointReference `xml:"http://schemas.xmlsoap.org/ws/2004/08/addressing EndpointReference"`
+}
+
+// ResolveMatches type
+type ResolveMatches struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery ResolveMatches"`
+
+	ResolveMatch *ProbeMatch `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery ResolveMatch,omitempty"`
+}
+
+var errNotProbeMatches = errors.New("Message is not ProbeMatches")
+
+// AppSequence type
+type AppSequence struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery AppSequence"`
+
+	InstanceID uint32 `xml:"InstanceId,attr"`
+
+	SequenceID string `xml:"SequenceId,attr,omitempty"`
+
+	MessageNumber uint32 `xml:"MessageNumber,attr"`
+}
+
+// Hello type
+type Hello struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Hello"`
+
+	EndpointReference EndpointReference `xml:"http://schemas.xmlsoap.org/ws/2004/08/addressing EndpointReference"`
+
+	Types QNames `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Types,omitempty"`
+
+	Scopes *Scopes `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Scopes,omitempty"`
+
+	XAddrs string `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery XAddrs,omitempty"`
+
+	MetadataVersion uint32 `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery MetadataVersion"`
+}
+
+// Bye type
+type Bye struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Bye"`
+
+	EndpointReference EndpointReference `xml:"http://schemas.xmlsoap.org/ws/2004/08/addressing EndpointReference"`
+}
+
+// Message is a received WS-Discovery message
+type Message struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2003/05/soap-envelope Envelope"`
+
+	Header struct {
+		MessageID string `xml:"MessageID"`
+
+		RelatesTo string `xml:"RelatesTo"`
+
+		To string `xml:"To"`
+
+		Action string `xml:"Action"`
+
+		AppSequence *AppSequence `xml:"AppSequence"`
+	} `xml:"Header"`
+
+	Body struct {
+		Probe *Probe `xml:"Probe"`
+
+		ProbeMatches *ProbeMatches `xml:"ProbeMatches"`
+
+		Resolve *Resolve `xml:"Resolve"`
+
+		ResolveMatches *ResolveMatches `xml:"ResolveMatches"`
+
+		Hello *Hello `xml:"Hello"`
+
+		Bye *Bye `xml:"Bye"`
+	} `xml:"Body"`
+}
+
+// ParseMessage parses raw WS-Discovery message, e.g. an UDP packet
+func ParseMessage(buffer []byte) (*Message, error) {
+	m := new(Message)
+	if err := soap.NewDecoder(bytes.NewReader(buffer)).Decode(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ParseProbeMatches parses raw ProbeMatches message and returns matched devices
+func ParseProbeMatches(buffer []byte) ([]Device, error) {
+	m, err := ParseMessage(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if m.Body.ProbeMatches == nil {
+		return nil, errNotProbeMatches
+	}
+	return m.Devices()
+}
+
+// Devices returns devices announced by ProbeMatches, ResolveMatches or Hello.
+// Matches without XAddrs are skipped.
+func (m *Message) Devices() ([]Device, error) {
+	matches := []ProbeMatch{}
+	switch {
+	case m.Body.ProbeMatches != nil:
+		matches = m.Body.ProbeMatches.ProbeMatch
+	case m.Body.ResolveMatches != nil && m.Body.ResolveMatches.ResolveMatch != nil:
+		matches = append(matches, *m.Body.ResolveMatches.ResolveMatch)
+	case m.Body.Hello != nil:
+		h := m.Body.Hello
+		match := ProbeMatch{EndpointReference: h.EndpointReference, Types: h.Types, XAddrs: h.XAddrs, MetadataVersion: h.MetadataVersion}
+		if h.Scopes != nil {
+			match.Scopes = *h.Scopes
+		}
+		matches = append(matches, match)
+	}
+
+	var err error
+	devices := []Device{}
+	for i := range matches {
+		var device Device
+		if device, err = newDevice(&matches[i]); err != nil {
+			continue
+		}
+		devices = append(devices, device)
+	}
+
+	if len(devices) == 0 && err != nil {
+		return devices, err
+	}
+	return devices, nil
+}
+
+func newMessageID() string {
+	return "uuid:" + uuid.NewV4().String()
+}
+
+// marshalMessage creates WS-Discovery message with WS-Addressing headers
+func marshalMessage(messageID, action, to, relatesTo string, seq *AppSequence, body interface{}) ([]byte, error) {
+	envelope := soap.SOAPEnvelope{}
+	envelope.Header.Headers = []interface{}{
+		&MessageID{Value: messageID},
+		&To{MustUnderstand: "true", Value: to},
+		&Action{MustUnderstand: "true", Value: action},
+	}
+	if relatesTo != "" {
+		envelope.Header.Headers = append(envelope.Header.Headers, &RelatesTo{Value: relatesTo})
+	}
+	if seq != nil {
+		envelope.Header.Headers = append(envelope.Header.Headers, seq)
+	}
+	envelope.Body.Content = body
+
+	b, err := xml.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// newProbe creates Probe message from the probe options
+func newProbe(opts *probeOptions) *Probe {
+	probe := &Probe{Types: opts.types}
+	if len(opts.scopes) > 0 {
+		probe.Scopes = &Scopes{MatchBy: opts.matchBy, Value: strings.Join(opts.scopes, " ")}
+	}
+	return probe
+}
+
+// newDevice creates Device from the ProbeMatch or ResolveMatch
+func newDevice(m *ProbeMatch) (Device, error) {
+	result := Device{}
+
+	// Get device's ID and clean it
+	deviceID := strings.Replace(m.EndpointReference.Address, "urn:uuid:", "", 1)
+
+	// Get device's name
+	scopes := strings.Fields(m.Scopes.Value)
+	deviceName := ""
+	for _, scope := range scopes {
+		if strings.HasPrefix(scope, "onvif://www.onvif.org/name/") {
+			deviceName = strings.Replace(scope, "onvif://www.onvif.org/name/", "", 1)
+			deviceName = strings.Replace(deviceName, "_", " ", -1)
+			break
+		}
+	}
+
+	// Get device's xAddrs
+	listXAddr := strings.Fields(m.XAddrs)
+	if len(listXAddr) == 0 {
+		return result, errNoXAddr
+	}
+
+	// Finalize result
+	result.ID = deviceID
+	result.Name = deviceName
+	result.XAddr = listXAddr[0]
+	result.XAddrs = listXAddr
+	result.Types = m.Types
+	result.Scopes = scopes
+
+	return result, nil
+}
@@ -0,0 +1,230 @@
+package discovery
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+// Packets captured from devices, the prefixes differ between vendors
+const (
+	helloPacket = `<?xml version="1.0" encoding="UTF-8"?>
+<SOAP-ENV:Envelope xmlns:SOAP-ENV="http://www.w3.org/2003/05/soap-envelope" xmlns:wsa="http://schemas.xmlsoap.org/ws/2004/08/addressing"
+ xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery" xmlns:dn="http://www.onvif.org/ver10/network/wsdl" xmlns:tds="http://www.onvif.org/ver10/device/wsdl">
+<SOAP-ENV:Header><wsa:MessageID>uuid:3e5a6d1c-0000-1000-8000-001a2b3c4d5e</wsa:MessageID>
+<wsa:To SOAP-ENV:mustUnderstand="true">urn:schemas-xmlsoap-org:ws:2005:04:discovery</wsa:To>
+<wsa:Action SOAP-ENV:mustUnderstand="true">http://schemas.xmlsoap.org/ws/2005/04/discovery/Hello</wsa:Action>
+<d:AppSequence InstanceId="1700000000" MessageNumber="3"></d:AppSequence></SOAP-ENV:Header>
+<SOAP-ENV:Body><d:Hello><wsa:EndpointReference><wsa:Address>urn:uuid:4d5e6f70-0000-1000-8000-001a2b3c4d5e</wsa:Address></wsa:EndpointReference>
+<d:Types>dn:NetworkVideoTransmitter tds:Device</d:Types>
+<d:Scopes>onvif://www.onvif.org/type/video_encoder onvif://www.onvif.org/Profile/Streaming onvif://www.onvif.org/name/Front_Door onvif://www.onvif.org/hardware/M3045</d:Scopes>
+<d:XAddrs>http://192.168.0.10/onvif/device_service http://[fe80::21a:2bff:fe3c:4d5e]/onvif/device_service</d:XAddrs>
+<d:MetadataVersion>1</d:MetadataVersion></d:Hello></SOAP-ENV:Body></SOAP-ENV:Envelope>`
+
+	byePacket = `<?xml version="1.0" encoding="UTF-8"?>
+<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:wsd="http://schemas.xmlsoap.org/ws/2005/04/discovery">
+<s:Header><a:MessageID>uuid:8f9a0b1c-0000-1000-8000-001a2b3c4d5e</a:MessageID>
+<a:To>urn:schemas-xmlsoap-org:ws:2005:04:discovery</a:To>
+<a:Action>http://schemas.xmlsoap.org/ws/2005/04/discovery/Bye</a:Action>
+<wsd:AppSequence InstanceId="1700000000" MessageNumber="4"/></s:Header>
+<s:Body><wsd:Bye><a:EndpointReference><a:Address>urn:uuid:4d5e6f70-0000-1000-8000-001a2b3c4d5e</a:Address></a:EndpointReference></wsd:Bye></s:Body></s:Envelope>`
+
+	// the second match is a device without XAddrs, the third uses a vendor type
+	probeMatchesPacket = `<?xml version="1.0" encoding="UTF-8"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:wsadis="http://schemas.xmlsoap.org/ws/2004/08/addressing"
+ xmlns:wsdd="http://schemas.xmlsoap.org/ws/2005/04/discovery" xmlns:ns1="http://www.onvif.org/ver10/network/wsdl" xmlns:vnd="http://www.vendor.com/camera">
+<env:Header><wsadis:MessageID>urn:uuid:a1b2c3d4-0000-1000-8000-001a2b3c4d5e</wsadis:MessageID>
+<wsadis:RelatesTo>uuid:0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0</wsadis:RelatesTo>
+<wsadis:To>http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous</wsadis:To>
+<wsadis:Action>http://schemas.xmlsoap.org/ws/2005/04/discovery/ProbeMatches</wsadis:Action></env:Header>
+<env:Body><wsdd:ProbeMatches>
+<wsdd:ProbeMatch><wsadis:EndpointReference><wsadis:Address>urn:uuid:4d5e6f70-0000-1000-8000-001a2b3c4d5e</wsadis:Address></wsadis:EndpointReference>
+<wsdd:Types>ns1:NetworkVideoTransmitter</wsdd:Types>
+<wsdd:Scopes> onvif://www.onvif.org/name/Front_Door
+ onvif://www.onvif.org/location/city/Boston </wsdd:Scopes>
+<wsdd:XAddrs>http://192.168.0.10/onvif/device_service</wsdd:XAddrs>
+<wsdd:MetadataVersion>10</wsdd:MetadataVersion></wsdd:ProbeMatch>
+<wsdd:ProbeMatch><wsadis:EndpointReference><wsadis:Address>urn:uuid:5e6f7081-0000-1000-8000-001a2b3c4d5f</wsadis:Address></wsadis:EndpointReference>
+<wsdd:Types>ns1:NetworkVideoTransmitter</wsdd:Types>
+<wsdd:Scopes>onvif://www.onvif.org/name/Backyard</wsdd:Scopes>
+<wsdd:MetadataVersion>2</wsdd:MetadataVersion></wsdd:ProbeMatch>
+<wsdd:ProbeMatch><wsadis:EndpointReference><wsadis:Address>urn:uuid:6f708192-0000-1000-8000-001a2b3c4d60</wsadis:Address></wsadis:EndpointReference>
+<wsdd:Types>vnd:Camera dn:NetworkVideoTransmitter</wsdd:Types>
+<wsdd:Scopes>onvif://www.onvif.org/name/Garage</wsdd:Scopes>
+<wsdd:XAddrs>http://192.168.0.12:8080/onvif/device_service http://192.168.1.12:8080/onvif/device_service</wsdd:XAddrs>
+<wsdd:MetadataVersion>1</wsdd:MetadataVersion></wsdd:ProbeMatch>
+</wsdd:ProbeMatches></env:Body></env:Envelope>`
+
+	noXAddrsPacket = `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:wsa="http://schemas.xmlsoap.org/ws/2004/08/addressing"
+ xmlns:wsdd="http://schemas.xmlsoap.org/ws/2005/04/discovery"><env:Header>
+<wsa:Action>http://schemas.xmlsoap.org/ws/2005/04/discovery/ProbeMatches</wsa:Action></env:Header>
+<env:Body><wsdd:ProbeMatches><wsdd:ProbeMatch><wsa:EndpointReference><wsa:Address>urn:uuid:5e6f7081-0000-1000-8000-001a2b3c4d5f</wsa:Address></wsa:EndpointReference>
+<wsdd:MetadataVersion>2</wsdd:MetadataVersion></wsdd:ProbeMatch></wsdd:ProbeMatches></env:Body></env:Envelope>`
+)
+
+var (
+	networkVideoTransmitter = xml.Name{Space: "http://www.onvif.org/ver10/network/wsdl", Local: "NetworkVideoTransmitter"}
+	deviceType              = xml.Name{Space: "http://www.onvif.org/ver10/device/wsdl", Local: "Device"}
+)
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		packet      string
+		action      string
+		messageID   string
+		relatesTo   string
+		sequence    *AppSequence
+		hello, bye  bool
+		probeMatch  int
+		endpoint    string
+		devices     []Device
+		devicesErr  error
+		expectError bool
+	}{
+		{
+			name:      "hello",
+			packet:    helloPacket,
+			action:    ActionHello,
+			messageID: "uuid:3e5a6d1c-0000-1000-8000-001a2b3c4d5e",
+			sequence:  &AppSequence{InstanceID: 1700000000, MessageNumber: 3},
+			hello:     true,
+			endpoint:  "urn:uuid:4d5e6f70-0000-1000-8000-001a2b3c4d5e",
+			devices: []Device{{
+				ID:     "4d5e6f70-0000-1000-8000-001a2b3c4d5e",
+				Name:   "Front Door",
+				XAddr:  "http://192.168.0.10/onvif/device_service",
+				XAddrs: []string{"http://192.168.0.10/onvif/device_service", "http://[fe80::21a:2bff:fe3c:4d5e]/onvif/device_service"},
+				Types:  QNames{networkVideoTransmitter, deviceType},
+				Scopes: []string{"onvif://www.onvif.org/type/video_encoder", "onvif://www.onvif.org/Profile/Streaming",
+					"onvif://www.onvif.org/name/Front_Door", "onvif://www.onvif.org/hardware/M3045"},
+			}},
+		},
+		{
+			name:      "bye",
+			packet:    byePacket,
+			action:    ActionBye,
+			messageID: "uuid:8f9a0b1c-0000-1000-8000-001a2b3c4d5e",
+			sequence:  &AppSequence{InstanceID: 1700000000, MessageNumber: 4},
+			bye:       true,
+			endpoint:  "urn:uuid:4d5e6f70-0000-1000-8000-001a2b3c4d5e",
+			devices:   []Device{},
+		},
+		{
+			name:       "probe matches",
+			packet:     probeMatchesPacket,
+			action:     ActionProbeMatches,
+			messageID:  "urn:uuid:a1b2c3d4-0000-1000-8000-001a2b3c4d5e",
+			relatesTo:  "uuid:0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0",
+			probeMatch: 3,
+			devices: []Device{
+				{
+					ID:     "4d5e6f70-0000-1000-8000-001a2b3c4d5e",
+					Name:   "Front Door",
+					XAddr:  "http://192.168.0.10/onvif/device_service",
+					XAddrs: []string{"http://192.168.0.10/onvif/device_service"},
+					// only well-known prefixes are resolved
+					Types:  QNames{{Space: "ns1", Local: "NetworkVideoTransmitter"}},
+					Scopes: []string{"onvif://www.onvif.org/name/Front_Door", "onvif://www.onvif.org/location/city/Boston"},
+				},
+				{
+					ID:     "6f708192-0000-1000-8000-001a2b3c4d60",
+					Name:   "Garage",
+					XAddr:  "http://192.168.0.12:8080/onvif/device_service",
+					XAddrs: []string{"http://192.168.0.12:8080/onvif/device_service", "http://192.168.1.12:8080/onvif/device_service"},
+					Types:  QNames{{Space: "vnd", Local: "Camera"}, networkVideoTransmitter},
+					Scopes: []string{"onvif://www.onvif.org/name/Garage"},
+				},
+			},
+		},
+		{
+			name:       "match without XAddrs",
+			packet:     noXAddrsPacket,
+			action:     ActionProbeMatches,
+			probeMatch: 1,
+			devices:    []Device{},
+			devicesErr: errNoXAddr,
+		},
+		{
+			name:        "not XML",
+			packet:      "M-SEARCH * HTTP/1.1\r\n",
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := ParseMessage([]byte(test.packet))
+			if test.expectError {
+				if err == nil {
+					t.Error("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			h := &m.Header
+			if h.Action != test.action || h.MessageID != test.messageID || h.RelatesTo != test.relatesTo {
+				t.Errorf("header %+v", h)
+			}
+			if !reflect.DeepEqual(h.AppSequence, test.sequence) {
+				t.Errorf("app sequence %+v, expected %+v", h.AppSequence, test.sequence)
+			}
+			if (m.Body.Hello != nil) != test.hello || (m.Body.Bye != nil) != test.bye {
+				t.Errorf("hello %v bye %v", m.Body.Hello, m.Body.Bye)
+			}
+			if m.Body.Hello != nil && m.Body.Hello.EndpointReference.Address != test.endpoint {
+				t.Errorf("hello endpoint %q", m.Body.Hello.EndpointReference.Address)
+			}
+			if m.Body.Bye != nil && m.Body.Bye.EndpointReference.Address != test.endpoint {
+				t.Errorf("bye endpoint %q", m.Body.Bye.EndpointReference.Address)
+			}
+			if matches := m.Body.ProbeMatches; (matches != nil || test.probeMatch > 0) && (matches == nil || len(matches.ProbeMatch) != test.probeMatch) {
+				t.Errorf("probe matches %+v, expected %d", matches, test.probeMatch)
+			}
+
+			devices, err := m.Devices()
+			if err != test.devicesErr {
+				t.Errorf("devices error %v, expected %v", err, test.devicesErr)
+			}
+			if !reflect.DeepEqual(devices, test.devices) {
+				t.Errorf("devices %+v, expected %+v", devices, test.devices)
+			}
+		})
+	}
+}
+
+func TestParseProbeMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet string
+		ids    []string
+		err    error
+	}{
+		{"probe matches", probeMatchesPacket, []string{"4d5e6f70-0000-1000-8000-001a2b3c4d5e", "6f708192-0000-1000-8000-001a2b3c4d60"}, nil},
+		{"match without XAddrs", noXAddrsPacket, []string{}, errNoXAddr},
+		{"hello", helloPacket, nil, errNotProbeMatches},
+		{"bye", byePacket, nil, errNotProbeMatches},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			devices, err := ParseProbeMatches([]byte(test.packet))
+			if err != test.err {
+				t.Errorf("error %v, expected %v", err, test.err)
+			}
+			var ids []string
+			if devices != nil {
+				ids = []string{}
+			}
+			for _, d := range devices {
+				ids = append(ids, d.ID)
+			}
+			if !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("devices %v, expected %v", ids, test.ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package discovery
+
+import (
+	"encoding/xml"
+	"net/url"
+	"strings"
+)
+
+// Scope matching rules defined by WS-Discovery
+const (
+	MatchByRFC3986 = "http://schemas.xmlsoap.org/ws/2005/04/discovery/rfc3986"
+	MatchByUUID    = "http://schemas.xmlsoap.org/ws/2005/04/discovery/uuid"
+	MatchByLDAP    = "http://schemas.xmlsoap.org/ws/2005/04/discovery/ldap"
+	MatchByStrcmp0 = "http://schemas.xmlsoap.org/ws/2005/04/discovery/strcmp0"
+)
+
+// Well-known ONVIF discovery types
+var (
+	TypeNetworkVideoTransmitter = xml.Name{Space: "http://www.onvif.org/ver10/network/wsdl", Local: "NetworkVideoTransmitter"}
+	TypeNetworkVideoDisplay     = xml.Name{Space: "http://www.onvif.org/ver10/network/wsdl", Local: "NetworkVideoDisplay"}
+	TypeDevice                  = xml.Name{Space: "http://www.onvif.org/ver10/device/wsdl", Local: "Device"}
+)
+
+// well-known namespace prefixes used when building Probe messages
+var knownPrefixes = map[string]string{
+	"http://www.onvif.org/ver10/network/wsdl": "dn",
+	"http://www.onvif.org/ver10/device/wsdl":  "tds",
+}
+
+type probeOptions struct {
+	types   []xml.Name
+	scopes  []string
+	matchBy string
+}
+
+var defaultProbeOptions = probeOptions{
+	types: []xml.Name{TypeNetworkVideoTransmitter},
+}
+
+// A Option sets probe options such as types and scopes
+type Option func(*probeOptions)
+
+// WithTypes is an Option to set the types to probe for.
+// Default is dn:NetworkVideoTransmitter. Calling it without arguments
+// sends a Probe without Types, which every target service matches.
+func WithTypes(types ...xml.Name) Option {
+	return func(o *probeOptions) {
+		o.types = types
+	}
+}
+
+// WithScopes is an Option to set the scopes to probe for and the rule
+// used to match them (one of MatchBy* constants, empty means rfc3986)
+func WithScopes(matchBy string, scopes ...string) Option {
+	return func(o *probeOptions) {
+		o.matchBy = matchBy
+		o.scopes = scopes
+	}
+}
+
+// match checks the device against the probe types and scopes.
+// Devices are free to ignore the scopes in a Probe, so results are filtered
+// on the client side too. Namespaces of the types are compared only
+// when both are resolved, since prefixes declared by peers are not.
+func (o *probeOptions) match(d *Device) bool {
+	for _, t := range o.types {
+		found := false
+		for _, dt := range d.Types {
+			if matchType(t, dt) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, s := range o.scopes {
+		found := false
+		for _, ds := range d.Scopes {
+			if MatchScope(o.matchBy, s, ds) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchType(a, b xml.Name) bool {
+	if a.Local != b.Local {
+		return false
+	}
+	// Namespace URIs always contain a colon, prefixes never do
+	if strings.Contains(a.Space, ":") && strings.Contains(b.Space, ":") {
+		return a.Space == b.Space
+	}
+	return true
+}
+
+// MatchScope reports whether the device scope matches the probe scope using given rule.
+// Empty rule means rfc3986. The ldap rule is not supported and never matches.
+func MatchScope(matchBy, probe, scope string) bool {
+	switch matchBy {
+	case "", MatchByRFC3986:
+		return matchRFC3986(probe, scope)
+	case MatchByStrcmp0:
+		return probe == scope
+	case MatchByUUID:
+		return strings.EqualFold(strings.TrimPrefix(probe, "urn:uuid:"), strings.TrimPrefix(scope, "urn:uuid:"))
+	}
+	return false
+}
+
+// matchRFC3986 implements the rfc3986 rule: scheme and authority are compared
+// case-insensitively and the probe path segments must be a prefix of the scope path
+func matchRFC3986(probe, scope string) bool {
+	p, err := url.Parse(probe)
+	if err != nil {
+		return false
+	}
+	s, err := url.Parse(scope)
+	if err != nil {
+		return false
+	}
+
+	if !strings.EqualFold(p.Scheme, s.Scheme) || !strings.EqualFold(p.Host, s.Host) || p.User.String() != s.User.String() {
+		return false
+	}
+
+	ps := pathSegments(p)
+	ss := pathSegments(s)
+	if len(ps) > len(ss) {
+		return false
+	}
+	for i := range ps {
+		if ps[i] != ss[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func pathSegments(u *url.URL) []string {
+	path := u.EscapedPath()
+	if u.Opaque != "" {
+		path = u.Opaque
+	}
+	segments := []string{}
+	for _, s := range strings.Split(path, "/") {
+		if s != "" && s != "." {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
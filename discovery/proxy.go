@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/videonext/onvif/soap"
+)
+
+var errNoResolveMatch = errors.New("Discovery proxy returned no ResolveMatch")
+
+// Proxy is a client of a WS-Discovery Proxy. It is used in managed mode,
+// when multicast is not available: Probe and Resolve are sent to the proxy
+// over SOAP-over-HTTP and the matches are returned in the HTTP response.
+// Proxy addresses could be received with devicemgmt.GetDPAddresses.
+type Proxy struct {
+	xaddr   string
+	opts    []soap.Option
+	headers []interface{}
+}
+
+// NewProxy creates Proxy instance for the discovery proxy endpoint
+func NewProxy(xaddr string, opt ...soap.Option) *Proxy {
+	return &Proxy{
+		xaddr: xaddr,
+		opts:  opt,
+	}
+}
+
+// AddHeader adds envelope header sent with every request, e.g. soap.WSSSecurityHeader
+func (p *Proxy) AddHeader(header interface{}) {
+	p.headers = append(p.headers, header)
+}
+
+// ProbeContext sends Probe to the discovery proxy and returns matching devices
+func (p *Proxy) ProbeContext(ctx context.Context, opt ...Option) ([]Device, error) {
+	opts := defaultProbeOptions
+	for _, o := range opt {
+		o(&opts)
+	}
+
+	response := new(ProbeMatches)
+	if err := p.call(ctx, ActionProbe, newProbe(&opts), response); err != nil {
+		return nil, err
+	}
+
+	devices := []Device{}
+	for i := range response.ProbeMatch {
+		device, err := newDevice(&response.ProbeMatch[i])
+		if err != nil || !opts.match(&device) {
+			continue
+		}
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
+
+// Probe sends Probe to the discovery proxy and returns matching devices
+func (p *Proxy) Probe(opt ...Option) ([]Device, error) {
+	return p.ProbeContext(context.Background(), opt...)
+}
+
+// ResolveContext sends Resolve for the device ID to the discovery proxy
+func (p *Proxy) ResolveContext(ctx context.Context, id string) (Device, error) {
+	if !strings.Contains(id, ":") {
+		id = "urn:uuid:" + id
+	}
+
+	request := &Resolve{}
+	request.EndpointReference.Address = id
+
+	response := new(ResolveMatches)
+	if err := p.call(ctx, ActionResolve, request, response); err != nil {
+		return Device{}, err
+	}
+	if response.ResolveMatch == nil {
+		return Device{}, errNoResolveMatch
+	}
+
+	return newDevice(response.ResolveMatch)
+}
+
+// Resolve sends Resolve for the device ID to the discovery proxy
+func (p *Proxy) Resolve(id string) (Device, error) {
+	return p.ResolveContext(context.Background(), id)
+}
+
+func (p *Proxy) call(ctx context.Context, action string, request, response interface{}) error {
+	// WS-Addressing headers are unique for every message, so use new client
+	client := soap.NewClient(p.opts...)
+	for _, h := range p.headers {
+		client.AddHeader(h)
+	}
+	client.AddHeader(&MessageID{Value: newMessageID()})
+	client.AddHeader(&To{MustUnderstand: "true", Value: p.xaddr})
+	client.AddHeader(&Action{MustUnderstand: "true", Value: action})
+
+	return client.CallContext(ctx, p.xaddr, action, request, response)
+}
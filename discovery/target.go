@@ -0,0 +1,314 @@
+package discovery
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/videonext/onvif/profiles/devicemgmt"
+)
+
+// maximum delay before answering a multicast Probe (APP_MAX_DELAY)
+const appMaxDelay = 500 * time.Millisecond
+
+var (
+	errTargetStarted    = errors.New("Target is already started")
+	errTargetNotStarted = errors.New("Target is not started")
+)
+
+// Target is a WS-Discovery target service. It makes the application
+// discoverable as an ONVIF device: answers Probe and Resolve, sends Hello
+// on start and Bye on stop. In NonDiscoverable mode multicast messages are
+// ignored and no Hello is sent, as with devicemgmt.SetDiscoveryMode.
+type Target struct {
+	mu sync.Mutex
+
+	id              string
+	types           QNames
+	scopes          []string
+	xaddrs          []string
+	mode            devicemgmt.DiscoveryMode
+	metadataVersion uint32
+	instanceID      uint32
+	messageNumber   uint32
+
+	ifi  *net.Interface
+	conn *net.UDPConn
+	done chan struct{}
+}
+
+// NewTarget creates Target instance. The id is the endpoint reference address,
+// usually urn:uuid:..., and the xaddrs are device service addresses.
+func NewTarget(id string, types []xml.Name, scopes, xaddrs []string) *Target {
+	return &Target{
+		id:              id,
+		types:           types,
+		scopes:          scopes,
+		xaddrs:          xaddrs,
+		mode:            devicemgmt.DiscoveryModeDiscoverable,
+		metadataVersion: 1,
+		instanceID:      uint32(time.Now().Unix()),
+	}
+}
+
+// SetInterface sets network interface to join the multicast group on.
+// Default is chosen by the system. Must be called before Start.
+func (t *Target) SetInterface(ifi *net.Interface) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ifi = ifi
+}
+
+// DiscoveryMode returns current discovery mode
+func (t *Target) DiscoveryMode() devicemgmt.DiscoveryMode {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.mode
+}
+
+// SetDiscoveryMode sets discovery mode. Switching to Discoverable
+// announces the target with Hello, switching to NonDiscoverable sends Bye.
+func (t *Target) SetDiscoveryMode(mode devicemgmt.DiscoveryMode) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.mode == mode {
+		return nil
+	}
+	t.mode = mode
+
+	if t.conn == nil {
+		return nil
+	}
+	if mode == devicemgmt.DiscoveryModeNonDiscoverable {
+		return t.sendBye()
+	}
+	return t.sendHello()
+}
+
+// SetScopes replaces target scopes and announces the change with Hello
+func (t *Target) SetScopes(scopes []string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.scopes = scopes
+	return t.metadataChanged()
+}
+
+// SetXAddrs replaces target addresses and announces the change with Hello
+func (t *Target) SetXAddrs(xaddrs []string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.xaddrs = xaddrs
+	return t.metadataChanged()
+}
+
+// Start joins the multicast group, sends Hello and starts answering
+// Probe and Resolve messages
+func (t *Target) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn != nil {
+		return errTargetStarted
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", t.ifi, multicastAddress)
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	t.done = make(chan struct{})
+
+	go t.serve(conn, t.done)
+
+	if t.mode == devicemgmt.DiscoveryModeNonDiscoverable {
+		return nil
+	}
+	return t.sendHello()
+}
+
+// Stop sends Bye and stops the target
+func (t *Target) Stop() error {
+	t.mu.Lock()
+	if t.conn == nil {
+		t.mu.Unlock()
+		return errTargetNotStarted
+	}
+
+	var err error
+	if t.mode != devicemgmt.DiscoveryModeNonDiscoverable {
+		err = t.sendBye()
+	}
+
+	conn, done := t.conn, t.done
+	t.conn = nil
+	t.mu.Unlock()
+
+	conn.Close()
+	<-done
+
+	return err
+}
+
+// ServeHTTP answers directed Probe sent over SOAP-over-HTTP.
+// Directed probes are answered in both discovery modes.
+func (t *Target) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	buffer, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	msg, err := ParseMessage(buffer)
+	if err != nil || msg.Body.Probe == nil {
+		http.Error(w, "Probe expected", http.StatusBadRequest)
+		return
+	}
+
+	t.mu.Lock()
+	response, err := t.probeMatches(msg)
+	t.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+	w.Write(response)
+}
+
+func (t *Target) serve(conn *net.UDPConn, done chan struct{}) {
+	defer close(done)
+
+	buffer := make([]byte, 64*1024)
+	for {
+		n, addr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+
+		msg, err := ParseMessage(buffer[:n])
+		if err != nil {
+			continue
+		}
+
+		t.mu.Lock()
+		var response []byte
+		if t.mode != devicemgmt.DiscoveryModeNonDiscoverable {
+			switch {
+			case msg.Body.Probe != nil:
+				response, _ = t.probeMatches(msg)
+			case msg.Body.Resolve != nil:
+				response, _ = t.resolveMatches(msg)
+			}
+		}
+		t.mu.Unlock()
+
+		if response == nil {
+			continue
+		}
+
+		// Responses to multicast messages are delayed to avoid bursts
+		delay := time.Duration(rand.Int63n(int64(appMaxDelay)))
+		time.AfterFunc(delay, func() {
+			conn.WriteToUDP(response, addr)
+		})
+	}
+}
+
+// probeMatches returns ProbeMatches answer or nil if the target does not match
+func (t *Target) probeMatches(msg *Message) ([]byte, error) {
+	probe := msg.Body.Probe
+	opts := probeOptions{types: probe.Types}
+	if probe.Scopes != nil {
+		opts.matchBy = strings.TrimSpace(probe.Scopes.MatchBy)
+		opts.scopes = strings.Fields(probe.Scopes.Value)
+	}
+
+	matches := &ProbeMatches{}
+	if opts.match(&Device{Types: t.types, Scopes: t.scopes}) {
+		matches.ProbeMatch = append(matches.ProbeMatch, *t.match())
+	} else if msg.Header.To == ToDiscovery {
+		// Multicast probes are not answered if nothing matches
+		return nil, nil
+	}
+
+	return marshalMessage(newMessageID(), ActionProbeMatches, ToAnonymous, msg.Header.MessageID, t.nextSequence(), matches)
+}
+
+// resolveMatches returns ResolveMatches answer or nil if the address is not ours
+func (t *Target) resolveMatches(msg *Message) ([]byte, error) {
+	if msg.Body.Resolve.EndpointReference.Address != t.id {
+		return nil, nil
+	}
+
+	matches := &ResolveMatches{ResolveMatch: t.match()}
+	return marshalMessage(newMessageID(), ActionResolveMatches, ToAnonymous, msg.Header.MessageID, t.nextSequence(), matches)
+}
+
+func (t *Target) match() *ProbeMatch {
+	m := &ProbeMatch{
+		Types:           t.types,
+		Scopes:          Scopes{Value: strings.Join(t.scopes, " ")},
+		XAddrs:          strings.Join(t.xaddrs, " "),
+		MetadataVersion: t.metadataVersion,
+	}
+	m.EndpointReference.Address = t.id
+	return m
+}
+
+func (t *Target) metadataChanged() error {
+	t.metadataVersion++
+
+	if t.conn == nil || t.mode == devicemgmt.DiscoveryModeNonDiscoverable {
+		return nil
+	}
+	return t.sendHello()
+}
+
+func (t *Target) sendHello() error {
+	hello := &Hello{
+		Types:           t.types,
+		XAddrs:          strings.Join(t.xaddrs, " "),
+		MetadataVersion: t.metadataVersion,
+	}
+	hello.EndpointReference.Address = t.id
+	if len(t.scopes) > 0 {
+		hello.Scopes = &Scopes{Value: strings.Join(t.scopes, " ")}
+	}
+
+	return t.send(ActionHello, hello)
+}
+
+func (t *Target) sendBye() error {
+	bye := &Bye{}
+	bye.EndpointReference.Address = t.id
+
+	return t.send(ActionBye, bye)
+}
+
+func (t *Target) send(action string, body interface{}) error {
+	b, err := marshalMessage(newMessageID(), action, ToDiscovery, "", t.nextSequence(), body)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.conn.WriteToUDP(b, multicastAddress)
+	return err
+}
+
+func (t *Target) nextSequence() *AppSequence {
+	t.messageNumber++
+	return &AppSequence{InstanceID: t.instanceID, MessageNumber: t.messageNumber}
+}
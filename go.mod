@@ -3,7 +3,6 @@ module github.com/videonext/onvif
 go 1.12
 
 require (
-	github.com/clbanning/mxj v1.8.4
 	github.com/kr/pretty v0.1.0
 	github.com/satori/go.uuid v1.2.0
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
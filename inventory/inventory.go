@@ -0,0 +1,334 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/videonext/onvif/discovery"
+	"github.com/videonext/onvif/profiles/devicemgmt"
+	"github.com/videonext/onvif/profiles/media"
+	"github.com/videonext/onvif/soap"
+)
+
+// Steps of the device enrichment, used as keys of Record.Errors
+const (
+	StepSystemDateAndTime = "GetSystemDateAndTime"
+	StepDeviceInformation = "GetDeviceInformation"
+	StepServices          = "GetServices"
+	StepNetworkInterfaces = "GetNetworkInterfaces"
+	StepProfiles          = "GetProfiles"
+)
+
+// Service namespaces
+const (
+	NsDevice = "http://www.onvif.org/ver10/device/wsdl"
+	NsMedia  = "http://www.onvif.org/ver10/media/wsdl"
+	NsMedia2 = "http://www.onvif.org/ver20/media/wsdl"
+	NsEvents = "http://www.onvif.org/ver10/events/wsdl"
+	NsPTZ    = "http://www.onvif.org/ver20/ptz/wsdl"
+)
+
+var errNoCredentials = errors.New("None of the credentials was accepted")
+
+// AuthMethod is the authentication method accepted by the device
+type AuthMethod string
+
+const (
+	// AuthMethodNone means the device answered without authentication
+	AuthMethodNone AuthMethod = "None"
+
+	// AuthMethodUsernameToken means WS-Security UsernameToken with password digest
+	AuthMethodUsernameToken AuthMethod = "UsernameToken"
+
+	// AuthMethodHTTPBasic means HTTP Basic authentication
+	AuthMethodHTTPBasic AuthMethod = "HTTPBasic"
+)
+
+// Credential contains login and password to try on the device
+type Credential struct {
+	Username string
+	Password string
+}
+
+// Service contains service description received with GetServices
+type Service struct {
+	Namespace string
+	XAddr     string
+	Version   string
+}
+
+// MediaProfile contains media profile token and name
+type MediaProfile struct {
+	Token string
+	Name  string
+}
+
+// Record contains collected information about the device.
+// Errors holds the error of every failed step, keyed by Step* constants.
+type Record struct {
+	Device discovery.Device
+
+	Manufacturer    string
+	Model           string
+	FirmwareVersion string
+	SerialNumber    string
+	HardwareID      string
+
+	MACs []string
+
+	Services []Service
+
+	// ONVIF profiles announced in the scopes, e.g. "S", "T", "G"
+	Profiles []string
+
+	MediaProfiles []MediaProfile
+
+	// Credential and authentication method that worked
+	Credential *Credential
+	AuthMethod AuthMethod
+
+	// Device clock minus local clock
+	ClockSkew time.Duration
+
+	Errors map[string]error
+}
+
+// Service returns service with given namespace or nil
+func (r *Record) Service(namespace string) *Service {
+	for i := range r.Services {
+		if r.Services[i].Namespace == namespace {
+			return &r.Services[i]
+		}
+	}
+	return nil
+}
+
+// Collect enriches discovered devices concurrently. Every device is tried without
+// credentials, then with the credentials in order while it rejects them as unauthorized.
+// Credentials are also tried if the device answers anonymously but rejects later requests.
+// Options are passed to the soap client.
+func Collect(ctx context.Context, devices []discovery.Device, credentials []Credential, opt ...soap.Option) []Record {
+	records := make([]Record, len(devices))
+
+	var wg sync.WaitGroup
+	for i := range devices {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			records[i] = CollectDevice(ctx, devices[i], credentials, opt...)
+		}(i)
+	}
+	wg.Wait()
+
+	return records
+}
+
+// CollectDevice enriches single discovered device
+func CollectDevice(ctx context.Context, device discovery.Device, credentials []Credential, opt ...soap.Option) Record {
+	r := Record{
+		Device:   device,
+		Profiles: onvifProfiles(device.Scopes),
+		Errors:   map[string]error{},
+	}
+
+	// GetSystemDateAndTime does not require authentication, its result
+	// is used to create UsernameToken acceptable by the device clock
+	dev := devicemgmt.NewDevice(soap.NewClient(opt...), device.XAddr)
+	if reply, err := dev.GetSystemDateAndTimeContext(ctx, &devicemgmt.GetSystemDateAndTime{}); err != nil {
+		r.Errors[StepSystemDateAndTime] = err
+	} else if t, ok := deviceTime(&reply.SystemDateAndTime); ok {
+		r.ClockSkew = t.Sub(time.Now().UTC()).Round(time.Second)
+	}
+
+	newClient, err := r.authenticate(ctx, credentials, opt)
+	if err != nil {
+		r.Errors[StepDeviceInformation] = err
+		return r
+	}
+
+	// Devices answering GetDeviceInformation anonymously may still require
+	// authentication for other requests, the credentials are tried once
+	// on the first rejection and used for the rest of the steps
+	loginTried := false
+	call := func(step func(client *soap.Client) error) error {
+		err := step(newClient())
+		if !isUnauthorized(err) || r.AuthMethod != AuthMethodNone || len(credentials) == 0 || loginTried {
+			return err
+		}
+		loginTried = true
+		authenticated, err := r.login(credentials, opt, step)
+		if err != nil {
+			return err
+		}
+		newClient = authenticated
+		return nil
+	}
+
+	if err := call(func(client *soap.Client) error {
+		reply, err := devicemgmt.NewDevice(client, device.XAddr).GetServicesContext(ctx, &devicemgmt.GetServices{})
+		if err != nil {
+			return err
+		}
+		for _, s := range reply.Service {
+			r.Services = append(r.Services, Service{
+				Namespace: string(s.Namespace),
+				XAddr:     string(s.XAddr),
+				Version:   fmt.Sprintf("%d.%02d", s.Version.Major, s.Version.Minor),
+			})
+		}
+		return nil
+	}); err != nil {
+		r.Errors[StepServices] = err
+	}
+
+	if err := call(func(client *soap.Client) error {
+		reply, err := devicemgmt.NewDevice(client, device.XAddr).GetNetworkInterfacesContext(ctx, &devicemgmt.GetNetworkInterfaces{})
+		if err != nil {
+			return err
+		}
+		for _, ni := range reply.NetworkInterfaces {
+			if ni.Info.HwAddress != "" {
+				r.MACs = append(r.MACs, strings.ToLower(string(ni.Info.HwAddress)))
+			}
+		}
+		return nil
+	}); err != nil {
+		r.Errors[StepNetworkInterfaces] = err
+	}
+
+	mediaXAddr := device.XAddr
+	if s := r.Service(NsMedia); s != nil {
+		mediaXAddr = s.XAddr
+	}
+	if err := call(func(client *soap.Client) error {
+		reply, err := media.NewMedia(client, mediaXAddr).GetProfilesContext(ctx, &media.GetProfiles{})
+		if err != nil {
+			return err
+		}
+		for _, p := range reply.Profiles {
+			r.MediaProfiles = append(r.MediaProfiles, MediaProfile{Token: string(p.Token), Name: string(p.Name)})
+		}
+		return nil
+	}); err != nil {
+		r.Errors[StepProfiles] = err
+	}
+
+	return r
+}
+
+// authenticate tries the device without credentials, then logs in with the
+// credentials if it was rejected as unauthorized. GetDeviceInformation is used
+// as the probe request. It returns constructor of clients authenticated with
+// the method that worked, every client has its own UsernameToken nonce.
+func (r *Record) authenticate(ctx context.Context, credentials []Credential, opt []soap.Option) (func() *soap.Client, error) {
+	probe := func(client *soap.Client) error {
+		reply, err := devicemgmt.NewDevice(client, r.Device.XAddr).GetDeviceInformationContext(ctx, &devicemgmt.GetDeviceInformation{})
+		if err != nil {
+			return err
+		}
+		r.Manufacturer = reply.Manufacturer
+		r.Model = reply.Model
+		r.FirmwareVersion = reply.FirmwareVersion
+		r.SerialNumber = reply.SerialNumber
+		r.HardwareID = reply.HardwareId
+		return nil
+	}
+
+	anonymous := func() *soap.Client {
+		return soap.NewClient(opt...)
+	}
+	err := probe(anonymous())
+	if err == nil {
+		r.AuthMethod = AuthMethodNone
+		return anonymous, nil
+	}
+	if !isUnauthorized(err) || len(credentials) == 0 {
+		return nil, err
+	}
+
+	return r.login(credentials, opt, probe)
+}
+
+// login tries every credential with all supported methods until the probe request
+// is accepted, the next attempt is made only if the device rejected the previous
+// one as unauthorized. Credential and AuthMethod are set to the ones that worked.
+func (r *Record) login(credentials []Credential, opt []soap.Option, probe func(client *soap.Client) error) (func() *soap.Client, error) {
+	for i := range credentials {
+		c := credentials[i]
+
+		usernameToken := func() *soap.Client {
+			client := soap.NewClient(opt...)
+			client.AddHeader(soap.NewWSSSecurityHeader(c.Username, c.Password, time.Now().UTC().Add(r.ClockSkew)))
+			return client
+		}
+		if err := probe(usernameToken()); err == nil {
+			r.Credential = &c
+			r.AuthMethod = AuthMethodUsernameToken
+			return usernameToken, nil
+		} else if !isUnauthorized(err) {
+			return nil, err
+		}
+
+		httpBasic := func() *soap.Client {
+			return soap.NewClient(append(append([]soap.Option{}, opt...), soap.WithBasicAuth(c.Username, c.Password))...)
+		}
+		if err := probe(httpBasic()); err == nil {
+			r.Credential = &c
+			r.AuthMethod = AuthMethodHTTPBasic
+			return httpBasic, nil
+		} else if !isUnauthorized(err) {
+			return nil, err
+		}
+	}
+
+	return nil, errNoCredentials
+}
+
+// isUnauthorized reports if the request was rejected for missing or wrong
+// credentials, with HTTP 401 or ter:NotAuthorized fault. WS-Security
+// wsse:FailedAuthentication fault is returned by some devices instead.
+func isUnauthorized(err error) bool {
+	switch e := err.(type) {
+	case *soap.HTTPError:
+		return e.StatusCode == http.StatusUnauthorized
+	case *soap.SOAPFault:
+		for _, code := range []string{e.Code.Subcode.Value, e.Code.Value} {
+			code = code[strings.IndexByte(code, ':')+1:]
+			if code == "NotAuthorized" || code == "FailedAuthentication" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deviceTime converts device UTC time
+func deviceTime(dt *devicemgmt.SystemDateTime) (time.Time, bool) {
+	d, t := dt.UTCDateTime.Date, dt.UTCDateTime.Time
+	if d.Year == 0 {
+		return time.Time{}, false
+	}
+	return time.Date(int(d.Year), time.Month(d.Month), int(d.Day), int(t.Hour), int(t.Minute), int(t.Second), 0, time.UTC), true
+}
+
+// onvifProfiles returns ONVIF profiles announced with onvif://www.onvif.org/Profile/ scopes
+func onvifProfiles(scopes []string) []string {
+	profiles := []string{}
+	for _, s := range scopes {
+		if !strings.HasPrefix(s, "onvif://www.onvif.org/Profile/") {
+			continue
+		}
+		p := strings.TrimPrefix(s, "onvif://www.onvif.org/Profile/")
+		// Profile S is announced as Streaming
+		if p == "Streaming" {
+			p = "S"
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
@@ -0,0 +1,177 @@
+package inventory
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/videonext/onvif/discovery"
+)
+
+const (
+	envelope = `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:tds="http://www.onvif.org/ver10/device/wsdl"` +
+		` xmlns:ter="http://www.onvif.org/ver10/error"><s:Body>%s</s:Body></s:Envelope>`
+	deviceInformation = `<tds:GetDeviceInformationResponse><tds:Manufacturer>Vendor</tds:Manufacturer><tds:Model>Camera</tds:Model>` +
+		`<tds:FirmwareVersion>1.0</tds:FirmwareVersion><tds:SerialNumber>123</tds:SerialNumber><tds:HardwareId>1</tds:HardwareId></tds:GetDeviceInformationResponse>`
+	services = `<tds:GetServicesResponse><tds:Service><tds:Namespace>http://www.onvif.org/ver10/media/wsdl</tds:Namespace>` +
+		`<tds:XAddr>http://camera/onvif/media</tds:XAddr><tds:Version><tt:Major xmlns:tt="http://www.onvif.org/ver10/schema">2</tt:Major>` +
+		`<tt:Minor xmlns:tt="http://www.onvif.org/ver10/schema">60</tt:Minor></tds:Version></tds:Service></tds:GetServicesResponse>`
+	notAuthorized = `<s:Fault><s:Code><s:Value>s:Sender</s:Value><s:Subcode><s:Value>ter:NotAuthorized</s:Value></s:Subcode></s:Code>` +
+		`<s:Reason><s:Text>Sender not authorized</s:Text></s:Reason></s:Fault>`
+	receiverFault = `<s:Fault><s:Code><s:Value>s:Receiver</s:Value><s:Subcode><s:Value>ter:Action</s:Value></s:Subcode></s:Code>` +
+		`<s:Reason><s:Text>Internal error</s:Text></s:Reason></s:Fault>`
+)
+
+// device is the stand-in of the device service accepting admin with the auth method
+type device struct {
+	*httptest.Server
+
+	auth AuthMethod
+
+	// fault replied to GetDeviceInformation instead of the information
+	fault string
+
+	// GetServices requires admin UsernameToken whatever the auth method
+	restricted bool
+
+	mu       sync.Mutex
+	attempts int
+}
+
+func newDevice(auth AuthMethod, fault string) *device {
+	d := &device{auth: auth, fault: fault}
+	d.Server = httptest.NewServer(http.HandlerFunc(d.serve))
+	return d
+}
+
+func (d *device) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	reply := func(status int, content string) {
+		w.Header().Set("Content-Type", "application/soap+xml")
+		w.WriteHeader(status)
+		w.Write([]byte(strings.Replace(envelope, "%s", content, 1)))
+	}
+
+	if d.restricted && strings.Contains(string(body), "GetServices") {
+		if !strings.Contains(string(body), ">admin<") {
+			reply(http.StatusBadRequest, notAuthorized)
+			return
+		}
+		reply(http.StatusOK, services)
+		return
+	}
+	if !strings.Contains(string(body), "GetDeviceInformation") {
+		reply(http.StatusInternalServerError, receiverFault)
+		return
+	}
+
+	d.mu.Lock()
+	d.attempts++
+	d.mu.Unlock()
+
+	if d.fault != "" {
+		reply(http.StatusInternalServerError, d.fault)
+		return
+	}
+	switch d.auth {
+	case AuthMethodUsernameToken:
+		if !strings.Contains(string(body), ">admin<") {
+			reply(http.StatusBadRequest, notAuthorized)
+			return
+		}
+	case AuthMethodHTTPBasic:
+		if username, _, ok := r.BasicAuth(); !ok || username != "admin" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="camera"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	reply(http.StatusOK, deviceInformation)
+}
+
+func TestAuthenticate(t *testing.T) {
+	credentials := []Credential{{"guest", "guest"}, {"admin", "secret"}}
+
+	tests := []struct {
+		name        string
+		auth        AuthMethod
+		fault       string
+		credentials []Credential
+		username    string
+		attempts    int
+		err         bool
+	}{
+		{"anonymous", AuthMethodNone, "", credentials, "", 1, false},
+		{"anonymous without credentials", AuthMethodNone, "", nil, "", 1, false},
+		{"UsernameToken", AuthMethodUsernameToken, "", credentials, "admin", 4, false},
+		{"HTTP Basic", AuthMethodHTTPBasic, "", credentials, "admin", 5, false},
+		{"no credentials", AuthMethodUsernameToken, "", nil, "", 1, true},
+		{"wrong credentials", AuthMethodHTTPBasic, "", credentials[:1], "", 3, true},
+		{"other error", AuthMethodUsernameToken, receiverFault, credentials, "", 1, true},
+	}
+
+	for _, test := range tests {
+		d := newDevice(test.auth, test.fault)
+		r := CollectDevice(context.Background(), discovery.Device{XAddr: d.URL}, test.credentials)
+		d.Close()
+
+		err := r.Errors[StepDeviceInformation]
+		if (err != nil) != test.err {
+			t.Errorf("%s: error %v", test.name, err)
+		}
+		if d.attempts != test.attempts {
+			t.Errorf("%s: %d attempts, expected %d", test.name, d.attempts, test.attempts)
+		}
+		if err != nil {
+			continue
+		}
+		if r.AuthMethod != test.auth || r.Model != "Camera" {
+			t.Errorf("%s: auth method %s model %s", test.name, r.AuthMethod, r.Model)
+		}
+		if (r.Credential == nil && test.username != "") || (r.Credential != nil && r.Credential.Username != test.username) {
+			t.Errorf("%s: credential %v, expected %s", test.name, r.Credential, test.username)
+		}
+	}
+}
+
+func TestAuthenticateRestricted(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials []Credential
+		auth        AuthMethod
+		username    string
+		err         bool
+	}{
+		{"credentials", []Credential{{"guest", "guest"}, {"admin", "secret"}}, AuthMethodUsernameToken, "admin", false},
+		{"no credentials", nil, AuthMethodNone, "", true},
+		{"wrong credentials", []Credential{{"guest", "guest"}}, AuthMethodNone, "", true},
+	}
+
+	for _, test := range tests {
+		d := newDevice(AuthMethodNone, "")
+		d.restricted = true
+		r := CollectDevice(context.Background(), discovery.Device{XAddr: d.URL}, test.credentials)
+		d.Close()
+
+		if err := r.Errors[StepDeviceInformation]; err != nil || r.Model != "Camera" {
+			t.Errorf("%s: device information error %v model %s", test.name, err, r.Model)
+		}
+		err := r.Errors[StepServices]
+		if (err != nil) != test.err {
+			t.Errorf("%s: services error %v", test.name, err)
+		}
+		if !test.err && (len(r.Services) != 1 || r.Services[0].Version != "2.60") {
+			t.Errorf("%s: services %+v", test.name, r.Services)
+		}
+		if r.AuthMethod != test.auth {
+			t.Errorf("%s: auth method %s, expected %s", test.name, r.AuthMethod, test.auth)
+		}
+		if (r.Credential == nil && test.username != "") || (r.Credential != nil && r.Credential.Username != test.username) {
+			t.Errorf("%s: credential %v, expected %s", test.name, r.Credential, test.username)
+		}
+	}
+}
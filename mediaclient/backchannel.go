@@ -0,0 +1,242 @@
+package mediaclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/videonext/onvif/rtp"
+	"github.com/videonext/onvif/rtsp"
+)
+
+var (
+	errNoBackchannelProfile   = errors.New("No profile with audio output and decoder configurations")
+	errNoBackchannel          = errors.New("RTSP server offers no backchannel media")
+	errUnsupportedBackchannel = errors.New("Unsupported backchannel encoding")
+)
+
+// Backchannel is the RTSP session sending audio to the device speaker.
+// Half duplex outputs must have SendPrimacy set to the client first.
+type Backchannel struct {
+	// Profile the session is set up for
+	ProfileToken string
+
+	// Encoding and clock rate of the backchannel media, e.g. "PCMU" and 8000
+	Encoding  string
+	ClockRate int
+
+	client     *rtsp.Client
+	track      *rtsp.Track
+	packetizer *rtp.Packetizer
+
+	// AU header of AAC
+	sizeLength  int
+	indexLength int
+}
+
+type backchannelOptions struct {
+	profileToken string
+	host         string
+	rewrite      HostRewrite
+	rtspOptions  []rtsp.Option
+}
+
+// BackchannelOption type
+type BackchannelOption func(*backchannelOptions)
+
+// WithBackchannelProfile is a BackchannelOption to set the profile, by default
+// the first one with audio output and decoder configurations is used
+func WithBackchannelProfile(token string) BackchannelOption {
+	return func(o *backchannelOptions) {
+		o.profileToken = token
+	}
+}
+
+// WithBackchannelHost is a BackchannelOption to set the host the device is reached on
+// and when the host of the stream URI is rewritten, see ResolveStreamURIs
+func WithBackchannelHost(host string, mode HostRewrite) BackchannelOption {
+	return func(o *backchannelOptions) {
+		o.host = host
+		o.rewrite = mode
+	}
+}
+
+// WithRTSPOptions is a BackchannelOption to set options of the RTSP client
+func WithRTSPOptions(opt ...rtsp.Option) BackchannelOption {
+	return func(o *backchannelOptions) {
+		o.rtspOptions = append(o.rtspOptions, opt...)
+	}
+}
+
+// Backchannel sets up the audio backchannel of the profile with RTSP
+// Require: www.onvif.org/ver20/backchannel and starts the session
+func (c *Client) Backchannel(ctx context.Context, opt ...BackchannelOption) (*Backchannel, error) {
+	opts := &backchannelOptions{}
+	if u, err := url.Parse(c.xaddr); err == nil {
+		opts.host = u.Hostname()
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+
+	if opts.profileToken == "" {
+		profiles, err := c.Profiles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range profiles {
+			if p.AudioOutput != nil && p.AudioDecoder != nil {
+				opts.profileToken = p.Token
+				break
+			}
+		}
+		if opts.profileToken == "" {
+			return nil, errNoBackchannelProfile
+		}
+	}
+
+	uri, err := c.StreamURI(ctx, opts.profileToken, ProtocolRtspUnicast)
+	if err != nil {
+		return nil, err
+	}
+	streamURI, _, err := RewriteHost(uri.URI, opts.host, opts.rewrite)
+	if err != nil {
+		return nil, err
+	}
+
+	rtspOptions := []rtsp.Option{rtsp.WithRequire(rtsp.RequireBackchannel)}
+	if c.opts.username != "" {
+		rtspOptions = append(rtspOptions, rtsp.WithCredentials(c.opts.username, c.opts.password))
+	}
+	client, err := rtsp.Dial(ctx, streamURI, append(rtspOptions, opts.rtspOptions...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := newBackchannel(ctx, client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	b.ProfileToken = opts.profileToken
+	return b, nil
+}
+
+func newBackchannel(ctx context.Context, client *rtsp.Client) (*Backchannel, error) {
+	sd, err := client.Describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := sd.Backchannel()
+	if m == nil {
+		return nil, errNoBackchannel
+	}
+
+	b := &Backchannel{
+		Encoding:    strings.ToUpper(m.Encoding),
+		ClockRate:   m.ClockRate,
+		client:      client,
+		packetizer:  rtp.NewPacketizer(uint8(m.PayloadType)),
+		sizeLength:  13,
+		indexLength: 3,
+	}
+	switch b.Encoding {
+	case "PCMU", "PCMA":
+		if b.ClockRate == 0 {
+			b.ClockRate = 8000
+		}
+	case "MPEG4-GENERIC":
+		if n, err := strconv.Atoi(m.FMTP["sizelength"]); err == nil {
+			b.sizeLength = n
+		}
+		if n, err := strconv.Atoi(m.FMTP["indexlength"]); err == nil {
+			b.indexLength = n
+		}
+	default:
+		return nil, errUnsupportedBackchannel
+	}
+
+	if b.track, err = client.Setup(ctx, *m); err != nil {
+		return nil, err
+	}
+	if err := client.Play(ctx); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// WriteFrame sends the frame of the backchannel encoding: G.711 samples, one byte
+// per sample, or the AAC frame without ADTS header
+func (b *Backchannel) WriteFrame(frame []byte) error {
+	samples := uint32(len(frame))
+	payload := frame
+	if b.Encoding == "MPEG4-GENERIC" {
+		samples = 1024
+		payload = rtp.AACPayload(frame, b.sizeLength, b.indexLength)
+	}
+	return b.client.WritePacket(b.track, b.packetizer.Packet(payload, samples).Marshal())
+}
+
+// Stream sends the audio read from r in real time until the end of the input
+// or the context is done. G.711 is read as raw samples sent every 20ms, AAC as ADTS stream.
+func (b *Backchannel) Stream(ctx context.Context, r io.Reader) error {
+	start := time.Now()
+	var samples int64
+
+	var next func() ([]byte, error)
+	if b.Encoding == "MPEG4-GENERIC" {
+		adts := rtp.NewADTSReader(r)
+		next = func() ([]byte, error) {
+			frame, config, err := adts.ReadFrame()
+			if err == nil && b.ClockRate == 0 {
+				b.ClockRate = config.SampleRate
+			}
+			return frame, err
+		}
+	} else {
+		buf := make([]byte, b.ClockRate/50)
+		next = func() ([]byte, error) {
+			n, err := io.ReadFull(r, buf)
+			if err == io.ErrUnexpectedEOF {
+				err = nil
+			}
+			return buf[:n], err
+		}
+	}
+
+	for {
+		frame, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := b.WriteFrame(frame); err != nil {
+			return err
+		}
+
+		if b.Encoding == "MPEG4-GENERIC" {
+			samples += 1024
+		} else {
+			samples += int64(len(frame))
+		}
+		due := start.Add(time.Duration(samples) * time.Second / time.Duration(b.ClockRate))
+		select {
+		case <-time.After(time.Until(due)):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.client.Done():
+			return b.client.Err()
+		}
+	}
+}
+
+// Close tears down the RTSP session
+func (b *Backchannel) Close() error {
+	return b.client.Teardown(context.Background())
+}
@@ -0,0 +1,472 @@
+package mediaclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/videonext/onvif/profiles/media"
+	"github.com/videonext/onvif/profiles/media2"
+)
+
+var (
+	errNoCompatibleConfiguration = errors.New("No compatible configuration")
+	errNoPTZConfiguration        = errors.New("No PTZ configuration of the video source")
+)
+
+// rollbackTimeout bounds restoring the device after a failed change, the context
+// of the change may be done already
+const rollbackTimeout = 30 * time.Second
+
+// Configuration types, named as in media2 AddConfiguration
+const (
+	ConfigurationVideoSource  = "VideoSource"
+	ConfigurationVideoEncoder = "VideoEncoder"
+	ConfigurationAudioSource  = "AudioSource"
+	ConfigurationAudioEncoder = "AudioEncoder"
+	ConfigurationMetadata     = "Metadata"
+	ConfigurationPTZ          = "PTZ"
+)
+
+// ProfileSpec describes the profile created by CreateProfile. Configurations with
+// empty tokens are chosen from the compatible ones, preferring configurations not
+// used by other profiles. Zero encoder values keep the configured ones, the others
+// are changed to the nearest values supported by the encoder.
+type ProfileSpec struct {
+	Name string
+
+	// Token of the new profile, media service only
+	Token string
+
+	// Video source configuration or video source token
+	VideoSource string
+
+	// Video encoding, e.g. EncodingH264, no video encoder is added if empty
+	Encoding string
+
+	// Video encoder configuration token
+	VideoEncoder string
+
+	Width     int
+	Height    int
+	FrameRate float64
+	GovLength int
+
+	// Bitrate in kbps
+	Bitrate int
+
+	// Codec profile, e.g. "Main" or "High"
+	EncoderProfile string
+
+	// Audio source and encoder are added. The encoder configuration is changed
+	// to AudioEncoding if set and other, e.g. EncodingPCMU.
+	Audio         bool
+	AudioSource   string
+	AudioEncoder  string
+	AudioEncoding string
+
+	// Metadata configuration is added
+	Metadata              bool
+	MetadataConfiguration string
+
+	// PTZ configuration is added, by default the one used by other profiles of the video source
+	PTZ              bool
+	PTZConfiguration string
+}
+
+// compatibleConfig is the configuration usable in the profile
+type compatibleConfig struct {
+	Config
+
+	// Source of the video and audio source configurations
+	SourceToken string
+
+	// Encoding of the audio encoder configurations
+	Encoding string
+
+	VideoEncoder *VideoEncoderConfig
+	AudioEncoder *AudioEncoderConfig
+}
+
+// RollbackError is returned if the changes of the failed operation are not fully restored
+type RollbackError struct {
+	// Error of the operation
+	Err error
+
+	// Errors of restoring the changes
+	UndoErrors []error
+}
+
+func (e *RollbackError) Error() string {
+	s := make([]string, len(e.UndoErrors))
+	for i, err := range e.UndoErrors {
+		s[i] = err.Error()
+	}
+	return fmt.Sprintf("%v, restoring the changes failed: %s", e.Err, strings.Join(s, "; "))
+}
+
+// rollback undoes the changes in reverse order and returns err, or *RollbackError
+// with err if any change is not undone
+func rollback(undo []func(ctx context.Context) error, err error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	var undoErrors []error
+	for i := len(undo) - 1; i >= 0; i-- {
+		if uerr := undo[i](ctx); uerr != nil {
+			undoErrors = append(undoErrors, uerr)
+		}
+	}
+	if len(undoErrors) == 0 {
+		return err
+	}
+	return &RollbackError{Err: err, UndoErrors: undoErrors}
+}
+
+// CreateProfile creates the profile with the configurations of the spec. On failure
+// the created profile is deleted and changed configurations are restored, the error
+// is *RollbackError if that fails too.
+func (c *Client) CreateProfile(ctx context.Context, spec *ProfileSpec) (profile *Profile, err error) {
+	var undo []func(ctx context.Context) error
+	defer func() {
+		if err != nil {
+			err = rollback(undo, err)
+		}
+	}()
+
+	existing, err := c.Profiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := c.createProfile(ctx, spec.Name, spec.Token)
+	if err != nil {
+		return nil, err
+	}
+	undo = append(undo, func(ctx context.Context) error {
+		return c.DeleteProfile(ctx, token)
+	})
+
+	source, err := c.addCompatible(ctx, token, ConfigurationVideoSource, spec.VideoSource, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.Encoding != "" {
+		encoder, err := c.addCompatible(ctx, token, ConfigurationVideoEncoder, spec.VideoEncoder, spec.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		options, err := c.VideoEncoderOptions(ctx, encoder.Token, token)
+		if err != nil {
+			return nil, err
+		}
+
+		original := *encoder.VideoEncoder
+		config := original
+		if err := fitVideoEncoder(&config, spec, options); err != nil {
+			return nil, err
+		}
+		if config != original {
+			if err := c.SetVideoEncoderConfiguration(ctx, &config); err != nil {
+				return nil, err
+			}
+			undo = append(undo, func(ctx context.Context) error {
+				return c.SetVideoEncoderConfiguration(ctx, &original)
+			})
+		}
+	}
+
+	if spec.Audio {
+		if _, err := c.addCompatible(ctx, token, ConfigurationAudioSource, spec.AudioSource, ""); err != nil {
+			return nil, err
+		}
+		encoder, err := c.addCompatible(ctx, token, ConfigurationAudioEncoder, spec.AudioEncoder, spec.AudioEncoding)
+		if err != nil {
+			return nil, err
+		}
+		if spec.AudioEncoding != "" && encoder.Encoding != spec.AudioEncoding {
+			original := *encoder.AudioEncoder
+			config := original
+			config.Encoding = spec.AudioEncoding
+			if err := c.SetAudioEncoderConfiguration(ctx, &config); err != nil {
+				return nil, fmt.Errorf("Encoding %s is not supported by audio encoder configuration %s: %v", spec.AudioEncoding, config.Token, err)
+			}
+			undo = append(undo, func(ctx context.Context) error {
+				return c.SetAudioEncoderConfiguration(ctx, &original)
+			})
+		}
+	}
+
+	if spec.Metadata {
+		if _, err := c.addCompatible(ctx, token, ConfigurationMetadata, spec.MetadataConfiguration, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if spec.PTZ {
+		ptz := spec.PTZConfiguration
+		for _, p := range existing {
+			if ptz == "" && p.VideoSource != nil && p.VideoSource.SourceToken == source.SourceToken {
+				ptz = p.PTZConfigurationToken
+			}
+		}
+		if ptz == "" {
+			return nil, errNoPTZConfiguration
+		}
+		if err := c.addConfiguration(ctx, token, ConfigurationPTZ, ptz); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.Profile(ctx, token)
+}
+
+// DeleteProfile deletes the profile
+func (c *Client) DeleteProfile(ctx context.Context, token string) error {
+	if c.version == VersionMedia2 {
+		_, err := c.media2().DeleteProfileContext(ctx, &media2.DeleteProfile{Token: media2.ReferenceToken(token)})
+		return err
+	}
+
+	_, err := c.media().DeleteProfileContext(ctx, &media.DeleteProfile{ProfileToken: media.ReferenceToken(token)})
+	return err
+}
+
+// createProfile creates the empty profile and returns its token
+func (c *Client) createProfile(ctx context.Context, name, token string) (string, error) {
+	if c.version == VersionMedia2 {
+		reply, err := c.media2().CreateProfileContext(ctx, &media2.CreateProfile{Name: media2.Name(name)})
+		if err != nil {
+			return "", err
+		}
+		return string(reply.Token), nil
+	}
+
+	reply, err := c.media().CreateProfileContext(ctx, &media.CreateProfile{
+		Name:  media.Name(name),
+		Token: media.ReferenceToken(token),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(reply.Profile.Token), nil
+}
+
+// addCompatible adds the configuration of the kind to the profile, the one with the
+// token if set or the best compatible one otherwise
+func (c *Client) addCompatible(ctx context.Context, profileToken, kind, token, encoding string) (*compatibleConfig, error) {
+	configs, err := c.compatibleConfigurations(ctx, profileToken, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *compatibleConfig
+	bestScore := -1
+	for i := range configs {
+		config := &configs[i]
+		if token != "" && config.Token != token && config.SourceToken != token {
+			continue
+		}
+		score := 0
+		if config.UseCount == 0 {
+			score += 2
+		}
+		if encoding != "" && config.Encoding == encoding {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = config, score
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%s: %v", kind, errNoCompatibleConfiguration)
+	}
+
+	if err := c.addConfiguration(ctx, profileToken, kind, best.Token); err != nil {
+		return nil, err
+	}
+	return best, nil
+}
+
+// addConfiguration adds the configuration of the kind to the profile
+func (c *Client) addConfiguration(ctx context.Context, profileToken, kind, token string) error {
+	if c.version == VersionMedia2 {
+		_, err := c.media2().AddConfigurationContext(ctx, &media2.AddConfiguration{
+			ProfileToken:  media2.ReferenceToken(profileToken),
+			Configuration: []media2.ConfigurationRef{{Type: kind, Token: media2.ReferenceToken(token)}},
+		})
+		return err
+	}
+
+	p, t := media.ReferenceToken(profileToken), media.ReferenceToken(token)
+	var err error
+	switch kind {
+	case ConfigurationVideoSource:
+		_, err = c.media().AddVideoSourceConfigurationContext(ctx, &media.AddVideoSourceConfiguration{ProfileToken: p, ConfigurationToken: t})
+	case ConfigurationVideoEncoder:
+		_, err = c.media().AddVideoEncoderConfigurationContext(ctx, &media.AddVideoEncoderConfiguration{ProfileToken: p, ConfigurationToken: t})
+	case ConfigurationAudioSource:
+		_, err = c.media().AddAudioSourceConfigurationContext(ctx, &media.AddAudioSourceConfiguration{ProfileToken: p, ConfigurationToken: t})
+	case ConfigurationAudioEncoder:
+		_, err = c.media().AddAudioEncoderConfigurationContext(ctx, &media.AddAudioEncoderConfiguration{ProfileToken: p, ConfigurationToken: t})
+	case ConfigurationMetadata:
+		_, err = c.media().AddMetadataConfigurationContext(ctx, &media.AddMetadataConfiguration{ProfileToken: p, ConfigurationToken: t})
+	case ConfigurationPTZ:
+		_, err = c.media().AddPTZConfigurationContext(ctx, &media.AddPTZConfiguration{ProfileToken: p, ConfigurationToken: t})
+	}
+	return err
+}
+
+// compatibleConfigurations returns configurations of the kind compatible with the profile
+func (c *Client) compatibleConfigurations(ctx context.Context, profileToken, kind string) ([]compatibleConfig, error) {
+	if c.version == VersionMedia2 {
+		return c.compatibleConfigurations2(ctx, profileToken, kind)
+	}
+
+	configs := []compatibleConfig{}
+	p := media.ReferenceToken(profileToken)
+	switch kind {
+	case ConfigurationVideoSource:
+		reply, err := c.media().GetCompatibleVideoSourceConfigurationsContext(ctx, &media.GetCompatibleVideoSourceConfigurations{ProfileToken: p})
+		if err != nil {
+			return nil, err
+		}
+		for i := range reply.Configurations {
+			v := fromVideoSource(&reply.Configurations[i])
+			configs = append(configs, compatibleConfig{Config: v.Config, SourceToken: v.SourceToken})
+		}
+	case ConfigurationVideoEncoder:
+		reply, err := c.media().GetCompatibleVideoEncoderConfigurationsContext(ctx, &media.GetCompatibleVideoEncoderConfigurations{ProfileToken: p})
+		if err != nil {
+			return nil, err
+		}
+		for i := range reply.Configurations {
+			v := fromVideoEncoder(&reply.Configurations[i])
+			configs = append(configs, compatibleConfig{Config: v.Config, Encoding: v.Encoding, VideoEncoder: &v})
+		}
+	case ConfigurationAudioSource:
+		reply, err := c.media().GetCompatibleAudioSourceConfigurationsContext(ctx, &media.GetCompatibleAudioSourceConfigurations{ProfileToken: p})
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range reply.Configurations {
+			if a.ConfigurationEntity != nil {
+				configs = append(configs, compatibleConfig{Config: fromEntity(a.ConfigurationEntity), SourceToken: string(a.SourceToken)})
+			}
+		}
+	case ConfigurationAudioEncoder:
+		reply, err := c.media().GetCompatibleAudioEncoderConfigurationsContext(ctx, &media.GetCompatibleAudioEncoderConfigurations{ProfileToken: p})
+		if err != nil {
+			return nil, err
+		}
+		for i := range reply.Configurations {
+			a := fromAudioEncoder(&reply.Configurations[i])
+			configs = append(configs, compatibleConfig{Config: a.Config, Encoding: a.Encoding, AudioEncoder: &a})
+		}
+	case ConfigurationMetadata:
+		reply, err := c.media().GetCompatibleMetadataConfigurationsContext(ctx, &media.GetCompatibleMetadataConfigurations{ProfileToken: p})
+		if err != nil {
+			return nil, err
+		}
+		for i := range reply.Configurations {
+			configs = append(configs, compatibleConfig{Config: fromMetadata(&reply.Configurations[i]).Config})
+		}
+	}
+	return configs, nil
+}
+
+func (c *Client) compatibleConfigurations2(ctx context.Context, profileToken, kind string) ([]compatibleConfig, error) {
+	configs := []compatibleConfig{}
+	operation := "Get" + kind + "Configurations"
+	switch kind {
+	case ConfigurationVideoSource:
+		reply := &media2.GetVideoSourceConfigurationsResponse{}
+		if err := c.getConfiguration2(ctx, operation, "", profileToken, reply); err != nil {
+			return nil, err
+		}
+		for i := range reply.Configurations {
+			v := fromVideoSource2(&reply.Configurations[i])
+			configs = append(configs, compatibleConfig{Config: v.Config, SourceToken: v.SourceToken})
+		}
+	case ConfigurationVideoEncoder:
+		reply := &media2.GetVideoEncoderConfigurationsResponse{}
+		if err := c.getConfiguration2(ctx, operation, "", profileToken, reply); err != nil {
+			return nil, err
+		}
+		for i := range reply.Configurations {
+			v := fromVideoEncoder2(&reply.Configurations[i])
+			configs = append(configs, compatibleConfig{Config: v.Config, Encoding: v.Encoding, VideoEncoder: &v})
+		}
+	case ConfigurationAudioSource:
+		reply := &media2.GetAudioSourceConfigurationsResponse{}
+		if err := c.getConfiguration2(ctx, operation, "", profileToken, reply); err != nil {
+			return nil, err
+		}
+		for _, a := range reply.Configurations {
+			if a.ConfigurationEntity != nil {
+				configs = append(configs, compatibleConfig{Config: fromEntity2(a.ConfigurationEntity), SourceToken: string(a.SourceToken)})
+			}
+		}
+	case ConfigurationAudioEncoder:
+		reply := &media2.GetAudioEncoderConfigurationsResponse{}
+		if err := c.getConfiguration2(ctx, operation, "", profileToken, reply); err != nil {
+			return nil, err
+		}
+		for i := range reply.Configurations {
+			a := fromAudioEncoder2(&reply.Configurations[i])
+			configs = append(configs, compatibleConfig{Config: a.Config, Encoding: a.Encoding, AudioEncoder: &a})
+		}
+	case ConfigurationMetadata:
+		reply := &media2.GetMetadataConfigurationsResponse{}
+		if err := c.getConfiguration2(ctx, operation, "", profileToken, reply); err != nil {
+			return nil, err
+		}
+		for i := range reply.Configurations {
+			configs = append(configs, compatibleConfig{Config: fromMetadata2(&reply.Configurations[i]).Config})
+		}
+	}
+	return configs, nil
+}
+
+// fitVideoEncoder applies the spec to the encoder configuration and changes the
+// values to the nearest ones supported by the options of the spec encoding
+func fitVideoEncoder(e *VideoEncoderConfig, spec *ProfileSpec, options []VideoEncoderOptions) error {
+	o := encodingOptions(options, spec.Encoding)
+	if o == nil {
+		return fmt.Errorf("Encoding %s is not supported by video encoder configuration %s", spec.Encoding, e.Token)
+	}
+
+	if e.Encoding != spec.Encoding {
+		// settings of other encoding may be out of the options
+		e.Encoding = spec.Encoding
+		e.Profile = ""
+	}
+	if spec.Width != 0 && spec.Height != 0 {
+		e.Width, e.Height = spec.Width, spec.Height
+	}
+	if spec.FrameRate != 0 {
+		e.FrameRateLimit = spec.FrameRate
+	}
+	if spec.Bitrate != 0 {
+		e.BitrateLimit = spec.Bitrate
+	}
+	if spec.GovLength != 0 {
+		e.GovLength = spec.GovLength
+	}
+	if spec.EncoderProfile != "" {
+		e.Profile = spec.EncoderProfile
+	}
+	if e.Profile == "" && len(o.Profiles) > 0 {
+		e.Profile = o.Profiles[len(o.Profiles)-1]
+	}
+	if e.GovLength == 0 && o.GovLengthRange.Max != 0 {
+		// one second, not the minimum of I-frames only
+		e.GovLength = clamp(int(e.FrameRateLimit+0.5), o.GovLengthRange)
+	}
+
+	*e, _ = ValidateVideoEncoder(e, options)
+	return nil
+}
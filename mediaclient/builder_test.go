@@ -0,0 +1,81 @@
+package mediaclient
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// testProfileResponses are the responses of the media service creating a profile with audio
+var testProfileResponses = map[string]string{
+	"GetProfiles":   `<trt:GetProfilesResponse/>`,
+	"CreateProfile": `<trt:CreateProfileResponse><trt:Profile token="profile1" fixed="false"><tt:Name>new</tt:Name></trt:Profile></trt:CreateProfileResponse>`,
+	"GetCompatibleVideoSourceConfigurations": `<trt:GetCompatibleVideoSourceConfigurationsResponse>` +
+		`<trt:Configurations token="vsc1"><tt:Name>vsc</tt:Name><tt:UseCount>1</tt:UseCount><tt:SourceToken>vs1</tt:SourceToken>` +
+		`<tt:Bounds x="0" y="0" width="1920" height="1080"/></trt:Configurations></trt:GetCompatibleVideoSourceConfigurationsResponse>`,
+	"AddVideoSourceConfiguration": `<trt:AddVideoSourceConfigurationResponse/>`,
+	"GetCompatibleAudioSourceConfigurations": `<trt:GetCompatibleAudioSourceConfigurationsResponse>` +
+		`<trt:Configurations token="asc1"><tt:Name>asc</tt:Name><tt:UseCount>1</tt:UseCount><tt:SourceToken>as1</tt:SourceToken></trt:Configurations>` +
+		`</trt:GetCompatibleAudioSourceConfigurationsResponse>`,
+	"AddAudioSourceConfiguration": `<trt:AddAudioSourceConfigurationResponse/>`,
+	"GetCompatibleAudioEncoderConfigurations": `<trt:GetCompatibleAudioEncoderConfigurationsResponse>` +
+		`<trt:Configurations token="aec1"><tt:Name>aec</tt:Name><tt:UseCount>0</tt:UseCount><tt:Encoding>AAC</tt:Encoding>` +
+		`<tt:Bitrate>64</tt:Bitrate><tt:SampleRate>16</tt:SampleRate><tt:SessionTimeout>PT60S</tt:SessionTimeout></trt:Configurations>` +
+		`</trt:GetCompatibleAudioEncoderConfigurationsResponse>`,
+	"AddAudioEncoderConfiguration": `<trt:AddAudioEncoderConfigurationResponse/>`,
+	"SetAudioEncoderConfiguration": `<trt:SetAudioEncoderConfigurationResponse/>`,
+	"DeleteProfile":                `<trt:DeleteProfileResponse/>`,
+	"GetProfile":                   `<trt:GetProfileResponse><trt:Profile token="profile1" fixed="false"><tt:Name>new</tt:Name></trt:Profile></trt:GetProfileResponse>`,
+}
+
+func TestCreateProfileAudioEncoding(t *testing.T) {
+	d := newDevice(testProfileResponses)
+	defer d.Close()
+
+	spec := &ProfileSpec{Name: "new", Audio: true, AudioEncoding: EncodingPCMU}
+	if _, err := d.client(VersionMedia).CreateProfile(context.Background(), spec); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := d.requested("SetAudioEncoderConfiguration")
+	if len(requests) != 1 || !strings.Contains(requests[0], ">G711<") {
+		t.Errorf("audio encoder is not changed to G711: %v", requests)
+	}
+	if len(d.requested("DeleteProfile")) != 0 {
+		t.Error("profile deleted")
+	}
+}
+
+func TestCreateProfileRollback(t *testing.T) {
+	d := newDevice(testProfileResponses)
+	defer d.Close()
+	d.fail["GetProfile"] = true
+
+	// the audio encoder is restored, the profile deletion fails
+	d.fail["DeleteProfile"] = true
+	spec := &ProfileSpec{Name: "new", Audio: true, AudioEncoding: EncodingPCMU}
+	_, err := d.client(VersionMedia).CreateProfile(context.Background(), spec)
+	rerr, ok := err.(*RollbackError)
+	if !ok {
+		t.Fatalf("error %v is not *RollbackError", err)
+	}
+	if rerr.Err == nil || len(rerr.UndoErrors) != 1 {
+		t.Errorf("unexpected error %v", rerr)
+	}
+
+	requests := d.requested("SetAudioEncoderConfiguration")
+	if len(requests) != 2 || !strings.Contains(requests[0], ">G711<") || !strings.Contains(requests[1], ">AAC<") {
+		t.Errorf("audio encoder is not restored: %v", requests)
+	}
+	if len(d.requested("DeleteProfile")) != 1 {
+		t.Error("profile not deleted")
+	}
+
+	// all changes are restored
+	d.fail["DeleteProfile"] = false
+	if _, err := d.client(VersionMedia).CreateProfile(context.Background(), spec); err == nil {
+		t.Error("no error")
+	} else if _, ok := err.(*RollbackError); ok {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,459 @@
+package mediaclient
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"time"
+
+	"github.com/videonext/onvif/profiles/devicemgmt"
+	"github.com/videonext/onvif/profiles/media"
+	"github.com/videonext/onvif/profiles/media2"
+	"github.com/videonext/onvif/soap"
+)
+
+// Service namespaces
+const (
+	NsMedia  = "http://www.onvif.org/ver10/media/wsdl"
+	NsMedia2 = "http://www.onvif.org/ver20/media/wsdl"
+)
+
+var (
+	errNoMediaService      = errors.New("Device does not provide media service")
+	errUnsupportedProtocol = errors.New("Protocol is not supported by the media service")
+	errProfileNotFound     = errors.New("Profile not found")
+)
+
+// Version is the version of the media service
+type Version string
+
+const (
+	// VersionMedia is the media service, ver10
+	VersionMedia Version = "ver10"
+
+	// VersionMedia2 is the media2 service, ver20
+	VersionMedia2 Version = "ver20"
+)
+
+// Protocol is the streaming protocol of StreamURI, named as in media2
+type Protocol string
+
+const (
+	// ProtocolRtspUnicast is RTSP with RTP over UDP or RTSP
+	ProtocolRtspUnicast Protocol = "RtspUnicast"
+
+	// ProtocolRtspMulticast is RTSP with RTP multicast
+	ProtocolRtspMulticast Protocol = "RtspMulticast"
+
+	// ProtocolRtspOverHttp is RTSP tunneled over HTTP
+	ProtocolRtspOverHttp Protocol = "RtspOverHttp"
+
+	// ProtocolRtspsUnicast is RTSP over TLS, media2 only
+	ProtocolRtspsUnicast Protocol = "RtspsUnicast"
+
+	// ProtocolRtspsMulticast is RTSP over TLS with multicast RTP, media2 only
+	ProtocolRtspsMulticast Protocol = "RtspsMulticast"
+
+	// ProtocolRtpUnicastUdp is RTP-Unicast/UDP stream setup, media only
+	ProtocolRtpUnicastUdp Protocol = "RtpUnicastUdp"
+
+	// ProtocolRtpUnicastTcp is RTP-Unicast/TCP stream setup, RTP over TCP
+	// without RTSP interleaving, media only
+	ProtocolRtpUnicastTcp Protocol = "RtpUnicastTcp"
+)
+
+type options struct {
+	soapOptions []soap.Option
+	username    string
+	password    string
+	version     Version
+}
+
+// Option type
+type Option func(*options)
+
+// WithSOAPOptions is an Option to set options of the soap clients
+func WithSOAPOptions(opt ...soap.Option) Option {
+	return func(o *options) {
+		o.soapOptions = opt
+	}
+}
+
+// WithCredentials is an Option to authenticate with WS-Security UsernameToken
+func WithCredentials(username, password string) Option {
+	return func(o *options) {
+		o.username = username
+		o.password = password
+	}
+}
+
+// WithVersion is an Option to use given media service version even if media2 is available
+func WithVersion(v Version) Option {
+	return func(o *options) {
+		o.version = v
+	}
+}
+
+// Client is the media service client hiding differences between media and media2.
+// Media2 is used when the device provides it, media otherwise.
+type Client struct {
+	opts    options
+	version Version
+	xaddr   string
+
+	// device clock minus local clock
+	clockOffset time.Duration
+}
+
+// New creates Client for the device with the device service address. Media services
+// are looked up with GetServices, or GetCapabilities for devices not supporting it.
+func New(ctx context.Context, deviceXAddr string, opt ...Option) (*Client, error) {
+	c := &Client{}
+	for _, o := range opt {
+		o(&c.opts)
+	}
+
+	// GetSystemDateAndTime does not require authentication, its result
+	// is used to create UsernameToken acceptable by the device clock
+	dev := devicemgmt.NewDevice(soap.NewClient(c.opts.soapOptions...), deviceXAddr)
+	if reply, err := dev.GetSystemDateAndTimeContext(ctx, &devicemgmt.GetSystemDateAndTime{}); err == nil {
+		d, t := reply.SystemDateAndTime.UTCDateTime.Date, reply.SystemDateAndTime.UTCDateTime.Time
+		if d.Year != 0 {
+			deviceTime := time.Date(int(d.Year), time.Month(d.Month), int(d.Day), int(t.Hour), int(t.Minute), int(t.Second), 0, time.UTC)
+			c.clockOffset = deviceTime.Sub(time.Now().UTC()).Round(time.Second)
+		}
+	}
+
+	services := map[string]string{}
+	dev = devicemgmt.NewDevice(c.newClient(), deviceXAddr)
+	if reply, err := dev.GetServicesContext(ctx, &devicemgmt.GetServices{}); err == nil {
+		for _, s := range reply.Service {
+			services[string(s.Namespace)] = string(s.XAddr)
+		}
+	} else {
+		dev = devicemgmt.NewDevice(c.newClient(), deviceXAddr)
+		caps, err := dev.GetCapabilitiesContext(ctx, &devicemgmt.GetCapabilities{
+			Category: []devicemgmt.CapabilityCategory{devicemgmt.CapabilityCategoryMedia},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if xaddr := string(caps.Capabilities.Media.XAddr); xaddr != "" {
+			services[NsMedia] = xaddr
+		}
+	}
+
+	switch {
+	case c.opts.version != VersionMedia && services[NsMedia2] != "":
+		c.version, c.xaddr = VersionMedia2, services[NsMedia2]
+	case c.opts.version != VersionMedia2 && services[NsMedia] != "":
+		c.version, c.xaddr = VersionMedia, services[NsMedia]
+	default:
+		return nil, errNoMediaService
+	}
+	return c, nil
+}
+
+// NewWithService creates Client for known media service version and address
+func NewWithService(v Version, xaddr string, opt ...Option) *Client {
+	c := &Client{version: v, xaddr: xaddr}
+	for _, o := range opt {
+		o(&c.opts)
+	}
+	return c
+}
+
+// Version returns version of the used media service
+func (c *Client) Version() Version {
+	return c.version
+}
+
+// XAddr returns address of the used media service
+func (c *Client) XAddr() string {
+	return c.xaddr
+}
+
+// Profiles returns media profiles with their configurations
+func (c *Client) Profiles(ctx context.Context) ([]Profile, error) {
+	profiles := []Profile{}
+
+	if c.version == VersionMedia2 {
+		reply, err := c.media2().GetProfilesContext(ctx, &media2.GetProfiles{Type: []string{"All"}})
+		if err != nil {
+			return nil, err
+		}
+		for i := range reply.Profiles {
+			profiles = append(profiles, fromProfile2(&reply.Profiles[i]))
+		}
+		return profiles, nil
+	}
+
+	reply, err := c.media().GetProfilesContext(ctx, &media.GetProfiles{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range reply.Profiles {
+		profiles = append(profiles, fromProfile(&reply.Profiles[i]))
+	}
+	return profiles, nil
+}
+
+// Profile returns media profile with the token
+func (c *Client) Profile(ctx context.Context, token string) (*Profile, error) {
+	if c.version == VersionMedia2 {
+		reply, err := c.media2().GetProfilesContext(ctx, &media2.GetProfiles{
+			Token: media2.ReferenceToken(token),
+			Type:  []string{"All"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(reply.Profiles) == 0 {
+			return nil, errProfileNotFound
+		}
+		p := fromProfile2(&reply.Profiles[0])
+		return &p, nil
+	}
+
+	reply, err := c.media().GetProfileContext(ctx, &media.GetProfile{ProfileToken: media.ReferenceToken(token)})
+	if err != nil {
+		return nil, err
+	}
+	p := fromProfile(&reply.Profile)
+	return &p, nil
+}
+
+// VideoSourceConfigurations returns all video source configurations
+func (c *Client) VideoSourceConfigurations(ctx context.Context) ([]VideoSourceConfig, error) {
+	configs := []VideoSourceConfig{}
+
+	if c.version == VersionMedia2 {
+		reply := &media2.GetVideoSourceConfigurationsResponse{}
+		if err := c.getConfigurations2(ctx, "GetVideoSourceConfigurations", reply); err != nil {
+			return nil, err
+		}
+		for i := range reply.Configurations {
+			configs = append(configs, fromVideoSource2(&reply.Configurations[i]))
+		}
+		return configs, nil
+	}
+
+	reply, err := c.media().GetVideoSourceConfigurationsContext(ctx, &media.GetVideoSourceConfigurations{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range reply.Configurations {
+		configs = append(configs, fromVideoSource(&reply.Configurations[i]))
+	}
+	return configs, nil
+}
+
+// VideoEncoderConfigurations returns all video encoder configurations
+func (c *Client) VideoEncoderConfigurations(ctx context.Context) ([]VideoEncoderConfig, error) {
+	configs := []VideoEncoderConfig{}
+
+	if c.version == VersionMedia2 {
+		reply := &media2.GetVideoEncoderConfigurationsResponse{}
+		if err := c.getConfigurations2(ctx, "GetVideoEncoderConfigurations", reply); err != nil {
+			return nil, err
+		}
+		for i := range reply.Configurations {
+			configs = append(configs, fromVideoEncoder2(&reply.Configurations[i]))
+		}
+		return configs, nil
+	}
+
+	reply, err := c.media().GetVideoEncoderConfigurationsContext(ctx, &media.GetVideoEncoderConfigurations{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range reply.Configurations {
+		configs = append(configs, fromVideoEncoder(&reply.Configurations[i]))
+	}
+	return configs, nil
+}
+
+// AudioEncoderConfigurations returns all audio encoder configurations
+func (c *Client) AudioEncoderConfigurations(ctx context.Context) ([]AudioEncoderConfig, error) {
+	configs := []AudioEncoderConfig{}
+
+	if c.version == VersionMedia2 {
+		reply := &media2.GetAudioEncoderConfigurationsResponse{}
+		if err := c.getConfigurations2(ctx, "GetAudioEncoderConfigurations", reply); err != nil {
+			return nil, err
+		}
+		for i := range reply.Configurations {
+			configs = append(configs, fromAudioEncoder2(&reply.Configurations[i]))
+		}
+		return configs, nil
+	}
+
+	reply, err := c.media().GetAudioEncoderConfigurationsContext(ctx, &media.GetAudioEncoderConfigurations{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range reply.Configurations {
+		configs = append(configs, fromAudioEncoder(&reply.Configurations[i]))
+	}
+	return configs, nil
+}
+
+// MetadataConfigurations returns all metadata configurations
+func (c *Client) MetadataConfigurations(ctx context.Context) ([]MetadataConfig, error) {
+	configs := []MetadataConfig{}
+
+	if c.version == VersionMedia2 {
+		reply := &media2.GetMetadataConfigurationsResponse{}
+		if err := c.getConfigurations2(ctx, "GetMetadataConfigurations", reply); err != nil {
+			return nil, err
+		}
+		for i := range reply.Configurations {
+			configs = append(configs, fromMetadata2(&reply.Configurations[i]))
+		}
+		return configs, nil
+	}
+
+	reply, err := c.media().GetMetadataConfigurationsContext(ctx, &media.GetMetadataConfigurations{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range reply.Configurations {
+		configs = append(configs, fromMetadata(&reply.Configurations[i]))
+	}
+	return configs, nil
+}
+
+// OSDs returns OSDs of the video source configuration, all OSDs if the token is empty
+func (c *Client) OSDs(ctx context.Context, videoSourceConfigurationToken string) ([]OSD, error) {
+	osds := []OSD{}
+
+	if c.version == VersionMedia2 {
+		reply, err := c.media2().GetOSDsContext(ctx, &media2.GetOSDs{
+			ConfigurationToken: media2.ReferenceToken(videoSourceConfigurationToken),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range reply.OSDs {
+			osds = append(osds, fromOSD2(&reply.OSDs[i]))
+		}
+		return osds, nil
+	}
+
+	reply, err := c.media().GetOSDsContext(ctx, &media.GetOSDs{
+		ConfigurationToken: media.ReferenceToken(videoSourceConfigurationToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range reply.OSDs {
+		osds = append(osds, fromOSD(&reply.OSDs[i]))
+	}
+	return osds, nil
+}
+
+// StreamURI returns stream URI of the profile for the protocol
+func (c *Client) StreamURI(ctx context.Context, profileToken string, protocol Protocol) (*MediaURI, error) {
+	if c.version == VersionMedia2 {
+		if protocol == ProtocolRtpUnicastUdp || protocol == ProtocolRtpUnicastTcp {
+			return nil, errUnsupportedProtocol
+		}
+		reply, err := c.media2().GetStreamUriContext(ctx, &media2.GetStreamUri{
+			Protocol:     string(protocol),
+			ProfileToken: media2.ReferenceToken(profileToken),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &MediaURI{URI: string(reply.Uri)}, nil
+	}
+
+	setup := media.StreamSetup{Stream: media.StreamTypeRTPUnicast}
+	switch protocol {
+	case ProtocolRtspUnicast:
+		setup.Transport.Protocol = media.TransportProtocolRTSP
+	case ProtocolRtspMulticast:
+		setup.Stream = media.StreamTypeRTPMulticast
+		setup.Transport.Protocol = media.TransportProtocolUDP
+	case ProtocolRtspOverHttp:
+		setup.Transport.Protocol = media.TransportProtocolHTTP
+	case ProtocolRtpUnicastUdp:
+		setup.Transport.Protocol = media.TransportProtocolUDP
+	case ProtocolRtpUnicastTcp:
+		setup.Transport.Protocol = media.TransportProtocolTCP
+	default:
+		return nil, errUnsupportedProtocol
+	}
+
+	reply, err := c.media().GetStreamUriContext(ctx, &media.GetStreamUri{
+		StreamSetup:  setup,
+		ProfileToken: media.ReferenceToken(profileToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromMediaURI(&reply.MediaUri), nil
+}
+
+// SnapshotURI returns JPEG snapshot URI of the profile
+func (c *Client) SnapshotURI(ctx context.Context, profileToken string) (*MediaURI, error) {
+	if c.version == VersionMedia2 {
+		reply, err := c.media2().GetSnapshotUriContext(ctx, &media2.GetSnapshotUri{
+			ProfileToken: media2.ReferenceToken(profileToken),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &MediaURI{URI: string(reply.Uri)}, nil
+	}
+
+	reply, err := c.media().GetSnapshotUriContext(ctx, &media.GetSnapshotUri{ProfileToken: media.ReferenceToken(profileToken)})
+	if err != nil {
+		return nil, err
+	}
+	return fromMediaURI(&reply.MediaUri), nil
+}
+
+// media returns the media port with new UsernameToken
+func (c *Client) media() media.Media {
+	return media.NewMedia(c.newClient(), c.xaddr)
+}
+
+// media2 returns the media2 port with new UsernameToken
+func (c *Client) media2() media2.Media2 {
+	return media2.NewMedia2(c.newClient(), c.xaddr)
+}
+
+// getConfiguration is the media2 GetConfiguration request. The generated request
+// types share the GetVideoEncoderConfigurations element name, so the name is set here.
+type getConfiguration struct {
+	XMLName xml.Name
+
+	ConfigurationToken string `xml:"http://www.onvif.org/ver20/media/wsdl ConfigurationToken,omitempty"`
+	ProfileToken       string `xml:"http://www.onvif.org/ver20/media/wsdl ProfileToken,omitempty"`
+}
+
+// getConfigurations2 calls media2 operation returning all configurations of a kind
+func (c *Client) getConfigurations2(ctx context.Context, operation string, response interface{}) error {
+	return c.getConfiguration2(ctx, operation, "", "", response)
+}
+
+// getConfiguration2 calls media2 operation with GetConfiguration request limited to the
+// configuration or to the configurations compatible with the profile
+func (c *Client) getConfiguration2(ctx context.Context, operation, configurationToken, profileToken string, response interface{}) error {
+	request := &getConfiguration{
+		XMLName:            xml.Name{Space: NsMedia2, Local: operation},
+		ConfigurationToken: configurationToken,
+		ProfileToken:       profileToken,
+	}
+	return c.newClient().CallContext(ctx, c.xaddr, NsMedia2+"/"+operation, request, response)
+}
+
+// newClient creates soap client with new UsernameToken
+func (c *Client) newClient() *soap.Client {
+	client := soap.NewClient(c.opts.soapOptions...)
+	if c.opts.username != "" {
+		client.AddHeader(soap.NewWSSSecurityHeader(c.opts.username, c.opts.password, time.Now().UTC().Add(c.clockOffset)))
+	}
+	return client
+}
@@ -0,0 +1,420 @@
+package mediaclient
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/videonext/onvif/profiles/event"
+	"github.com/videonext/onvif/profiles/media"
+	"github.com/videonext/onvif/soap"
+)
+
+var errConfigurationNotFound = errors.New("Configuration not found")
+
+// SetVideoEncoderConfiguration changes the video encoder configuration. Devices reject
+// unsupported values with bare InvalidArgVal, see ValidateVideoEncoderConfiguration.
+func (c *Client) SetVideoEncoderConfiguration(ctx context.Context, config *VideoEncoderConfig) error {
+	if c.version == VersionMedia2 {
+		request := &setVideoEncoderConfiguration2{Configuration: toVideoEncoder2(config)}
+		return c.newClient().CallContext(ctx, c.xaddr, NsMedia2+"/SetVideoEncoderConfiguration", request, &struct{}{})
+	}
+
+	request := &setVideoEncoderConfiguration{Configuration: toVideoEncoder(config), ForcePersistence: true}
+	return c.newClient().CallContext(ctx, c.xaddr, NsMedia+"/SetVideoEncoderConfiguration", request, &struct{}{})
+}
+
+// SetAudioEncoderConfiguration changes the audio encoder configuration
+func (c *Client) SetAudioEncoderConfiguration(ctx context.Context, config *AudioEncoderConfig) error {
+	if c.version == VersionMedia2 {
+		request := &setAudioEncoderConfiguration2{Configuration: toAudioEncoder2(config)}
+		return c.newClient().CallContext(ctx, c.xaddr, NsMedia2+"/SetAudioEncoderConfiguration", request, &struct{}{})
+	}
+
+	request := &setAudioEncoderConfiguration{Configuration: toAudioEncoder(config), ForcePersistence: true}
+	return c.newClient().CallContext(ctx, c.xaddr, NsMedia+"/SetAudioEncoderConfiguration", request, &struct{}{})
+}
+
+// SetMetadataConfiguration changes the metadata configuration. The event filter,
+// with the namespaces of its prefixes, and the elements not in MetadataConfig,
+// e.g. AnalyticsEngineConfiguration, of the configuration on the device are kept.
+func (c *Client) SetMetadataConfiguration(ctx context.Context, config *MetadataConfig) error {
+	current, err := c.metadataConfiguration(ctx, config.Token)
+	if err != nil {
+		return err
+	}
+	m := toMetadata(config)
+	m.Events = current.Events
+	m.Other = current.Other
+	if m.Events != nil && m.Events.Filter != nil {
+		for i := range m.Events.Filter.TopicExpression {
+			t := &m.Events.Filter.TopicExpression[i]
+			t.Namespaces = usedNamespaces(t.Value, t.Namespaces)
+		}
+		for i := range m.Events.Filter.MessageContent {
+			q := &m.Events.Filter.MessageContent[i]
+			q.Namespaces = usedNamespaces(q.Value, q.Namespaces)
+		}
+	}
+
+	if c.version == VersionMedia2 {
+		request := &setMetadataConfiguration2{Configuration: m}
+		return c.newClient().CallContext(ctx, c.xaddr, NsMedia2+"/SetMetadataConfiguration", request, &struct{}{})
+	}
+
+	request := &setMetadataConfiguration{Configuration: m, ForcePersistence: true}
+	return c.newClient().CallContext(ctx, c.xaddr, NsMedia+"/SetMetadataConfiguration", request, &struct{}{})
+}
+
+// metadataConfiguration returns the metadata configuration with the event filter
+func (c *Client) metadataConfiguration(ctx context.Context, token string) (*metadataConfiguration, error) {
+	if c.version == VersionMedia2 {
+		reply := &struct {
+			Configurations []metadataConfiguration `xml:"Configurations"`
+		}{}
+		if err := c.getConfiguration2(ctx, "GetMetadataConfigurations", token, "", reply); err != nil {
+			return nil, err
+		}
+		if len(reply.Configurations) == 0 {
+			return nil, errConfigurationNotFound
+		}
+		return &reply.Configurations[0], nil
+	}
+
+	reply := &struct {
+		Configuration metadataConfiguration `xml:"Configuration"`
+	}{}
+	request := &media.GetMetadataConfiguration{ConfigurationToken: media.ReferenceToken(token)}
+	if err := c.newClient().CallContext(ctx, c.xaddr, NsMedia+"/GetMetadataConfiguration", request, reply); err != nil {
+		return nil, err
+	}
+	return &reply.Configuration, nil
+}
+
+// usedNamespaces adds the well-known namespaces of the prefixes used in the filter
+// expression to the declarations of the response, if the device omitted them
+func usedNamespaces(expression string, namespaces map[string]string) map[string]string {
+	if namespaces == nil {
+		namespaces = map[string]string{}
+	}
+	for prefix, namespace := range map[string]string{
+		"tns1": "http://www.onvif.org/ver10/topics",
+		"tt":   "http://www.onvif.org/ver10/schema",
+	} {
+		if _, ok := namespaces[prefix]; !ok && strings.Contains(expression, prefix+":") {
+			namespaces[prefix] = namespace
+		}
+	}
+	return namespaces
+}
+
+// The requests changing configurations are declared here, the generated
+// configuration types place Name, Resolution and AutoStart in the wsdl namespace.
+
+type setVideoEncoderConfiguration struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver10/media/wsdl SetVideoEncoderConfiguration"`
+
+	Configuration    videoEncoderConfiguration `xml:"http://www.onvif.org/ver10/media/wsdl Configuration"`
+	ForcePersistence bool                      `xml:"http://www.onvif.org/ver10/media/wsdl ForcePersistence"`
+}
+
+type videoEncoderConfiguration struct {
+	Token               string `xml:"token,attr"`
+	GuaranteedFrameRate bool   `xml:"GuaranteedFrameRate,attr,omitempty"`
+
+	Name           string                 `xml:"http://www.onvif.org/ver10/schema Name"`
+	UseCount       int                    `xml:"http://www.onvif.org/ver10/schema UseCount"`
+	Encoding       string                 `xml:"http://www.onvif.org/ver10/schema Encoding"`
+	Resolution     videoResolution        `xml:"http://www.onvif.org/ver10/schema Resolution"`
+	Quality        float64                `xml:"http://www.onvif.org/ver10/schema Quality"`
+	RateControl    *videoRateControl      `xml:"http://www.onvif.org/ver10/schema RateControl,omitempty"`
+	MPEG4          *mpeg4Configuration    `xml:"http://www.onvif.org/ver10/schema MPEG4,omitempty"`
+	H264           *h264Configuration     `xml:"http://www.onvif.org/ver10/schema H264,omitempty"`
+	Multicast      multicastConfiguration `xml:"http://www.onvif.org/ver10/schema Multicast"`
+	SessionTimeout string                 `xml:"http://www.onvif.org/ver10/schema SessionTimeout"`
+}
+
+type videoResolution struct {
+	Width  int `xml:"http://www.onvif.org/ver10/schema Width"`
+	Height int `xml:"http://www.onvif.org/ver10/schema Height"`
+}
+
+type videoRateControl struct {
+	FrameRateLimit   int `xml:"http://www.onvif.org/ver10/schema FrameRateLimit"`
+	EncodingInterval int `xml:"http://www.onvif.org/ver10/schema EncodingInterval"`
+	BitrateLimit     int `xml:"http://www.onvif.org/ver10/schema BitrateLimit"`
+}
+
+type mpeg4Configuration struct {
+	GovLength    int    `xml:"http://www.onvif.org/ver10/schema GovLength"`
+	Mpeg4Profile string `xml:"http://www.onvif.org/ver10/schema Mpeg4Profile"`
+}
+
+type h264Configuration struct {
+	GovLength   int    `xml:"http://www.onvif.org/ver10/schema GovLength"`
+	H264Profile string `xml:"http://www.onvif.org/ver10/schema H264Profile"`
+}
+
+type multicastConfiguration struct {
+	Address   ipAddress `xml:"http://www.onvif.org/ver10/schema Address"`
+	Port      int       `xml:"http://www.onvif.org/ver10/schema Port"`
+	TTL       int       `xml:"http://www.onvif.org/ver10/schema TTL"`
+	AutoStart bool      `xml:"http://www.onvif.org/ver10/schema AutoStart"`
+}
+
+type ipAddress struct {
+	Type        string `xml:"http://www.onvif.org/ver10/schema Type"`
+	IPv4Address string `xml:"http://www.onvif.org/ver10/schema IPv4Address,omitempty"`
+	IPv6Address string `xml:"http://www.onvif.org/ver10/schema IPv6Address,omitempty"`
+}
+
+type setVideoEncoderConfiguration2 struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver20/media/wsdl SetVideoEncoderConfiguration"`
+
+	Configuration videoEncoder2Configuration `xml:"http://www.onvif.org/ver20/media/wsdl Configuration"`
+}
+
+type videoEncoder2Configuration struct {
+	Token               string `xml:"token,attr"`
+	GovLength           int    `xml:"GovLength,attr,omitempty"`
+	Profile             string `xml:"Profile,attr,omitempty"`
+	GuaranteedFrameRate bool   `xml:"GuaranteedFrameRate,attr,omitempty"`
+
+	Name        string                  `xml:"http://www.onvif.org/ver10/schema Name"`
+	UseCount    int                     `xml:"http://www.onvif.org/ver10/schema UseCount"`
+	Encoding    string                  `xml:"http://www.onvif.org/ver10/schema Encoding"`
+	Resolution  videoResolution         `xml:"http://www.onvif.org/ver10/schema Resolution"`
+	RateControl *videoRateControl2      `xml:"http://www.onvif.org/ver10/schema RateControl,omitempty"`
+	Multicast   *multicastConfiguration `xml:"http://www.onvif.org/ver10/schema Multicast,omitempty"`
+	Quality     float64                 `xml:"http://www.onvif.org/ver10/schema Quality"`
+}
+
+type videoRateControl2 struct {
+	ConstantBitRate bool `xml:"ConstantBitRate,attr,omitempty"`
+
+	FrameRateLimit float64 `xml:"http://www.onvif.org/ver10/schema FrameRateLimit"`
+	BitrateLimit   int     `xml:"http://www.onvif.org/ver10/schema BitrateLimit"`
+}
+
+type setAudioEncoderConfiguration struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver10/media/wsdl SetAudioEncoderConfiguration"`
+
+	Configuration    audioEncoderConfiguration `xml:"http://www.onvif.org/ver10/media/wsdl Configuration"`
+	ForcePersistence bool                      `xml:"http://www.onvif.org/ver10/media/wsdl ForcePersistence"`
+}
+
+type audioEncoderConfiguration struct {
+	Token string `xml:"token,attr"`
+
+	Name           string                 `xml:"http://www.onvif.org/ver10/schema Name"`
+	UseCount       int                    `xml:"http://www.onvif.org/ver10/schema UseCount"`
+	Encoding       string                 `xml:"http://www.onvif.org/ver10/schema Encoding"`
+	Bitrate        int                    `xml:"http://www.onvif.org/ver10/schema Bitrate"`
+	SampleRate     int                    `xml:"http://www.onvif.org/ver10/schema SampleRate"`
+	Multicast      multicastConfiguration `xml:"http://www.onvif.org/ver10/schema Multicast"`
+	SessionTimeout string                 `xml:"http://www.onvif.org/ver10/schema SessionTimeout"`
+}
+
+type setAudioEncoderConfiguration2 struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver20/media/wsdl SetAudioEncoderConfiguration"`
+
+	Configuration audioEncoder2Configuration `xml:"http://www.onvif.org/ver20/media/wsdl Configuration"`
+}
+
+type audioEncoder2Configuration struct {
+	Token string `xml:"token,attr"`
+
+	Name       string                  `xml:"http://www.onvif.org/ver10/schema Name"`
+	UseCount   int                     `xml:"http://www.onvif.org/ver10/schema UseCount"`
+	Encoding   string                  `xml:"http://www.onvif.org/ver10/schema Encoding"`
+	Multicast  *multicastConfiguration `xml:"http://www.onvif.org/ver10/schema Multicast,omitempty"`
+	Bitrate    int                     `xml:"http://www.onvif.org/ver10/schema Bitrate"`
+	SampleRate int                     `xml:"http://www.onvif.org/ver10/schema SampleRate"`
+}
+
+type setMetadataConfiguration struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver10/media/wsdl SetMetadataConfiguration"`
+
+	Configuration    *metadataConfiguration `xml:"http://www.onvif.org/ver10/media/wsdl Configuration"`
+	ForcePersistence bool                   `xml:"http://www.onvif.org/ver10/media/wsdl ForcePersistence"`
+}
+
+type setMetadataConfiguration2 struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver20/media/wsdl SetMetadataConfiguration"`
+
+	Configuration *metadataConfiguration `xml:"http://www.onvif.org/ver20/media/wsdl Configuration"`
+}
+
+// metadataConfiguration is tt:MetadataConfiguration of both versions, decoded
+// from the responses to keep the event filter and analytics
+type metadataConfiguration struct {
+	Token           string `xml:"token,attr"`
+	CompressionType string `xml:"CompressionType,attr,omitempty"`
+	GeoLocation     bool   `xml:"GeoLocation,attr,omitempty"`
+
+	Name      string     `xml:"http://www.onvif.org/ver10/schema Name"`
+	UseCount  int        `xml:"http://www.onvif.org/ver10/schema UseCount"`
+	PTZStatus *ptzFilter `xml:"http://www.onvif.org/ver10/schema PTZStatus,omitempty"`
+	Events    *struct {
+		Filter *event.FilterType `xml:"http://www.onvif.org/ver10/schema Filter,omitempty"`
+	} `xml:"http://www.onvif.org/ver10/schema Events,omitempty"`
+	Analytics      bool                   `xml:"http://www.onvif.org/ver10/schema Analytics"`
+	Multicast      multicastConfiguration `xml:"http://www.onvif.org/ver10/schema Multicast"`
+	SessionTimeout string                 `xml:"http://www.onvif.org/ver10/schema SessionTimeout"`
+
+	// AnalyticsEngineConfiguration, Extension and vendor elements sent back as received
+	Other []soap.Element `xml:",any"`
+}
+
+type ptzFilter struct {
+	Status   bool `xml:"http://www.onvif.org/ver10/schema Status"`
+	Position bool `xml:"http://www.onvif.org/ver10/schema Position"`
+}
+
+// videoEncodingNames maps encodings to the media service encodings
+var videoEncodingNames = map[string]string{
+	EncodingJPEG:  "JPEG",
+	EncodingMPEG4: "MPEG4",
+	EncodingH264:  "H264",
+}
+
+func toVideoEncoder(e *VideoEncoderConfig) videoEncoderConfiguration {
+	c := videoEncoderConfiguration{
+		Token:               e.Token,
+		GuaranteedFrameRate: e.GuaranteedFrameRate,
+		Name:                e.Name,
+		UseCount:            e.UseCount,
+		Encoding:            e.Encoding,
+		Resolution:          videoResolution{e.Width, e.Height},
+		Quality:             e.Quality,
+		Multicast:           toMulticast(&e.Multicast),
+		SessionTimeout:      formatDuration(e.SessionTimeout),
+	}
+	if encoding, ok := videoEncodingNames[e.Encoding]; ok {
+		c.Encoding = encoding
+	}
+	if e.FrameRateLimit != 0 || e.BitrateLimit != 0 || e.EncodingInterval != 0 {
+		interval := e.EncodingInterval
+		if interval == 0 {
+			interval = 1
+		}
+		c.RateControl = &videoRateControl{
+			FrameRateLimit:   int(e.FrameRateLimit + 0.5),
+			EncodingInterval: interval,
+			BitrateLimit:     e.BitrateLimit,
+		}
+	}
+	switch c.Encoding {
+	case "MPEG4":
+		c.MPEG4 = &mpeg4Configuration{GovLength: e.GovLength, Mpeg4Profile: e.Profile}
+	case "H264":
+		c.H264 = &h264Configuration{GovLength: e.GovLength, H264Profile: e.Profile}
+	}
+	return c
+}
+
+func toVideoEncoder2(e *VideoEncoderConfig) videoEncoder2Configuration {
+	c := videoEncoder2Configuration{
+		Token:               e.Token,
+		GovLength:           e.GovLength,
+		Profile:             e.Profile,
+		GuaranteedFrameRate: e.GuaranteedFrameRate,
+		Name:                e.Name,
+		UseCount:            e.UseCount,
+		Encoding:            e.Encoding,
+		Resolution:          videoResolution{e.Width, e.Height},
+		Quality:             e.Quality,
+	}
+	if e.FrameRateLimit != 0 || e.BitrateLimit != 0 {
+		c.RateControl = &videoRateControl2{
+			ConstantBitRate: e.ConstantBitRate,
+			FrameRateLimit:  e.FrameRateLimit,
+			BitrateLimit:    e.BitrateLimit,
+		}
+	}
+	if e.Multicast.Address != "" {
+		m := toMulticast(&e.Multicast)
+		c.Multicast = &m
+	}
+	return c
+}
+
+// audioEncodingNames maps encodings to the media service encodings
+var audioEncodingNames = map[string]string{
+	EncodingPCMU: "G711",
+	EncodingG726: "G726",
+	EncodingAAC:  "AAC",
+}
+
+func toAudioEncoder(e *AudioEncoderConfig) audioEncoderConfiguration {
+	c := audioEncoderConfiguration{
+		Token:          e.Token,
+		Name:           e.Name,
+		UseCount:       e.UseCount,
+		Encoding:       e.Encoding,
+		Bitrate:        e.Bitrate,
+		SampleRate:     e.SampleRate,
+		Multicast:      toMulticast(&e.Multicast),
+		SessionTimeout: formatDuration(e.SessionTimeout),
+	}
+	if encoding, ok := audioEncodingNames[e.Encoding]; ok {
+		c.Encoding = encoding
+	}
+	return c
+}
+
+func toAudioEncoder2(e *AudioEncoderConfig) audioEncoder2Configuration {
+	c := audioEncoder2Configuration{
+		Token:      e.Token,
+		Name:       e.Name,
+		UseCount:   e.UseCount,
+		Encoding:   e.Encoding,
+		Bitrate:    e.Bitrate,
+		SampleRate: e.SampleRate,
+	}
+	if e.Multicast.Address != "" {
+		m := toMulticast(&e.Multicast)
+		c.Multicast = &m
+	}
+	return c
+}
+
+func toMetadata(e *MetadataConfig) *metadataConfiguration {
+	c := &metadataConfiguration{
+		Token:           e.Token,
+		CompressionType: e.CompressionType,
+		GeoLocation:     e.GeoLocation,
+		Name:            e.Name,
+		UseCount:        e.UseCount,
+		Analytics:       e.Analytics,
+		Multicast:       toMulticast(&e.Multicast),
+		SessionTimeout:  formatDuration(e.SessionTimeout),
+	}
+	if e.PTZStatus || e.PTZPosition {
+		c.PTZStatus = &ptzFilter{Status: e.PTZStatus, Position: e.PTZPosition}
+	}
+	return c
+}
+
+func toMulticast(m *Multicast) multicastConfiguration {
+	c := multicastConfiguration{Port: m.Port, TTL: m.TTL, AutoStart: m.AutoStart}
+	if ip := net.ParseIP(m.Address); ip != nil && ip.To4() == nil {
+		c.Address = ipAddress{Type: "IPv6", IPv6Address: m.Address}
+	} else {
+		address := m.Address
+		if address == "" {
+			address = "0.0.0.0"
+		}
+		c.Address = ipAddress{Type: "IPv4", IPv4Address: address}
+	}
+	return c
+}
+
+// formatDuration formats xs:duration in seconds, e.g. PT60S
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("PT%gS", d.Seconds())
+}
@@ -0,0 +1,50 @@
+package mediaclient
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const testMetadataConfiguration = `<trt:GetMetadataConfigurationResponse xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2" xmlns:tnsaxis="http://www.axis.com/2009/event/topics">` +
+	`<trt:Configuration token="meta1"><tt:Name>meta</tt:Name><tt:UseCount>1</tt:UseCount>` +
+	`<tt:Events><tt:Filter><wsnt:TopicExpression Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet">tns1:VideoSource//.|tnsaxis:CameraApplicationPlatform//.</wsnt:TopicExpression></tt:Filter></tt:Events>` +
+	`<tt:Analytics>true</tt:Analytics>` +
+	`<tt:Multicast><tt:Address><tt:Type>IPv4</tt:Type><tt:IPv4Address>0.0.0.0</tt:IPv4Address></tt:Address><tt:Port>0</tt:Port><tt:TTL>1</tt:TTL><tt:AutoStart>false</tt:AutoStart></tt:Multicast>` +
+	`<tt:SessionTimeout>PT60S</tt:SessionTimeout>` +
+	`<tt:AnalyticsEngineConfiguration><tt:AnalyticsModule Name="motion" Type="tt:CellMotionEngine"><tt:Parameters><tt:SimpleItem Name="Sensitivity" Value="50"/></tt:Parameters></tt:AnalyticsModule></tt:AnalyticsEngineConfiguration>` +
+	`</trt:Configuration></trt:GetMetadataConfigurationResponse>`
+
+func TestSetMetadataConfiguration(t *testing.T) {
+	d := newDevice(map[string]string{
+		"GetMetadataConfiguration": testMetadataConfiguration,
+		"SetMetadataConfiguration": `<trt:SetMetadataConfigurationResponse/>`,
+	})
+	defer d.Close()
+
+	config := &MetadataConfig{Config: Config{Token: "meta1", Name: "meta"}, Analytics: true, Multicast: Multicast{Address: "239.0.0.1", Port: 40000, TTL: 1}}
+	if err := d.client(VersionMedia).SetMetadataConfiguration(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := d.requested("SetMetadataConfiguration")
+	if len(requests) != 1 {
+		t.Fatalf("%d requests", len(requests))
+	}
+	for _, s := range []string{
+		`xmlns:tnsaxis="http://www.axis.com/2009/event/topics"`,
+		`xmlns:tns1="http://www.onvif.org/ver10/topics"`,
+		`tns1:VideoSource//.|tnsaxis:CameraApplicationPlatform//.`,
+		`AnalyticsEngineConfiguration`,
+		`Type="tt:CellMotionEngine" xmlns:tt="http://www.onvif.org/ver10/schema"`,
+		`Name="Sensitivity" Value="50"`,
+		`239.0.0.1`,
+	} {
+		if !strings.Contains(requests[0], s) {
+			t.Errorf("%s not found in %s", s, requests[0])
+		}
+	}
+	if strings.Contains(requests[0], `xmlns:s=`) {
+		t.Errorf("unused prefix declared in %s", requests[0])
+	}
+}
@@ -0,0 +1,62 @@
+package mediaclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// device is the stand-in of the media service replying to the operations with
+// the bodies of the responses, faults are replied to the operations in fail.
+// The caller closes it.
+type device struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses map[string]string
+	fail      map[string]bool
+	requests  []string
+	bodies    map[string][]string
+}
+
+func newDevice(responses map[string]string) *device {
+	d := &device{responses: responses, fail: map[string]bool{}, bodies: map[string][]string{}}
+	d.Server = httptest.NewServer(http.HandlerFunc(d.serve))
+	return d
+}
+
+// client returns the client of the media service version
+func (d *device) client(version Version) *Client {
+	return &Client{version: version, xaddr: d.URL}
+}
+
+func (d *device) serve(w http.ResponseWriter, r *http.Request) {
+	action := strings.Trim(r.Header.Get("Soapaction"), `"`)
+	operation := action[strings.LastIndex(action, "/")+1:]
+	body, _ := ioutil.ReadAll(r.Body)
+
+	d.mu.Lock()
+	d.requests = append(d.requests, operation)
+	d.bodies[operation] = append(d.bodies[operation], string(body))
+	response, ok := d.responses[operation]
+	fail := d.fail[operation]
+	d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/soap+xml")
+	if fail || !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		response = `<s:Fault><s:Code><s:Value>s:Receiver</s:Value></s:Code><s:Reason><s:Text>failed</s:Text></s:Reason></s:Fault>`
+	}
+	w.Write([]byte(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"` +
+		` xmlns:tt="http://www.onvif.org/ver10/schema" xmlns:trt="http://www.onvif.org/ver10/media/wsdl"` +
+		` xmlns:tr2="http://www.onvif.org/ver20/media/wsdl"><s:Body>` + response + `</s:Body></s:Envelope>`))
+}
+
+// requested returns the bodies of the requests of the operation
+func (d *device) requested(operation string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.bodies[operation]
+}
@@ -0,0 +1,455 @@
+package mediaclient
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+var (
+	errNotMulticastNetwork = errors.New("Network is not IPv4 multicast")
+	errNoMulticastGroup    = errors.New("No free multicast group address and ports")
+	errNoMulticastURI      = errors.New("Device returned no multicast stream URI")
+)
+
+// StartMulticastStreaming starts multicast streaming of the profile
+func (c *Client) StartMulticastStreaming(ctx context.Context, profileToken string) error {
+	return c.startStopMulticastStreaming(ctx, "StartMulticastStreaming", profileToken)
+}
+
+// StopMulticastStreaming stops multicast streaming of the profile
+func (c *Client) StopMulticastStreaming(ctx context.Context, profileToken string) error {
+	return c.startStopMulticastStreaming(ctx, "StopMulticastStreaming", profileToken)
+}
+
+// startStopMulticastStreaming is the request of both operations. The generated media2
+// request names the element StartMulticastStreaming for both, so the name is set here.
+type startStopMulticastStreaming struct {
+	XMLName xml.Name
+
+	ProfileToken string `xml:"ProfileToken"`
+}
+
+func (c *Client) startStopMulticastStreaming(ctx context.Context, operation, profileToken string) error {
+	ns := NsMedia
+	if c.version == VersionMedia2 {
+		ns = NsMedia2
+	}
+	request := &startStopMulticastStreaming{XMLName: xml.Name{Space: ns, Local: operation}, ProfileToken: profileToken}
+	return c.newClient().CallContext(ctx, c.xaddr, ns+"/"+operation, request, &struct{}{})
+}
+
+type multicastOptions struct {
+	minPort int
+	maxPort int
+	ttl     int
+}
+
+// MulticastOption type
+type MulticastOption func(*multicastOptions)
+
+// WithPortRange is a MulticastOption to set the range of the RTP ports, by default 40000-49999
+func WithPortRange(min, max int) MulticastOption {
+	return func(o *multicastOptions) {
+		o.minPort = min
+		o.maxPort = max
+	}
+}
+
+// WithTTL is a MulticastOption to set TTL of the streams, 1 by default
+func WithTTL(ttl int) MulticastOption {
+	return func(o *multicastOptions) {
+		o.ttl = ttl
+	}
+}
+
+// endpoint is the multicast group address and RTP port
+type endpoint struct {
+	address string
+	port    int
+}
+
+// MulticastPlanner allocates multicast group addresses and ports not used by other
+// streams of the fleet. Streams of a profile share the group address and use
+// consecutive RTP ports, even ones followed by the RTCP port.
+type MulticastPlanner struct {
+	opts    multicastOptions
+	network *net.IPNet
+
+	mu sync.Mutex
+
+	// owners of the endpoints and endpoints of the owners
+	used  map[endpoint]string
+	owned map[string]endpoint
+}
+
+// NewMulticastPlanner creates MulticastPlanner allocating group addresses of the
+// IPv4 multicast network, e.g. "239.192.0.0/16"
+func NewMulticastPlanner(network string, opt ...MulticastOption) (*MulticastPlanner, error) {
+	_, ipnet, err := net.ParseCIDR(network)
+	if err != nil {
+		return nil, err
+	}
+	if ipnet.IP.To4() == nil || !ipnet.IP.IsMulticast() {
+		return nil, errNotMulticastNetwork
+	}
+
+	p := &MulticastPlanner{
+		opts:    multicastOptions{minPort: 40000, maxPort: 49999, ttl: 1},
+		network: ipnet,
+		used:    map[endpoint]string{},
+		owned:   map[string]endpoint{},
+	}
+	for _, o := range opt {
+		o(&p.opts)
+	}
+	if p.opts.minPort%2 != 0 {
+		p.opts.minPort++
+	}
+	return p, nil
+}
+
+// MulticastConflict is the configuration of the device using the group address
+// and port reserved by other owner
+type MulticastConflict struct {
+	// Configuration type, e.g. ConfigurationVideoEncoder
+	Kind  string
+	Token string
+
+	Multicast Multicast
+
+	// Owner of the group address and port
+	Owner string
+}
+
+// Reserve marks the group address and port of the stream as used by the owner, e.g.
+// by a device not managed by the planner. False is returned if used by other owner.
+func (p *MulticastPlanner) Reserve(owner string, m Multicast) bool {
+	_, ok := p.reserve(owner, m)
+	return ok
+}
+
+// ReserveDevice reserves multicast addresses and ports of all video encoder, audio
+// encoder and metadata configurations of the device. Configurations using group
+// addresses and ports reserved by other owners are returned, these are not reserved.
+func (p *MulticastPlanner) ReserveDevice(ctx context.Context, c *Client) ([]MulticastConflict, error) {
+	videoEncoders, err := c.VideoEncoderConfigurations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	audioEncoders, err := c.AudioEncoderConfigurations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := c.MetadataConfigurations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []MulticastConflict
+	reserve := func(kind, token string, m Multicast) {
+		if owner, ok := p.reserve(multicastOwner(c, kind, token), m); !ok {
+			conflicts = append(conflicts, MulticastConflict{Kind: kind, Token: token, Multicast: m, Owner: owner})
+		}
+	}
+	for _, e := range videoEncoders {
+		reserve(ConfigurationVideoEncoder, e.Token, e.Multicast)
+	}
+	for _, e := range audioEncoders {
+		reserve(ConfigurationAudioEncoder, e.Token, e.Multicast)
+	}
+	for _, m := range metadata {
+		reserve(ConfigurationMetadata, m.Token, m.Multicast)
+	}
+	return conflicts, nil
+}
+
+// reserve returns the other owner of the group address and port and false if used
+func (p *MulticastPlanner) reserve(owner string, m Multicast) (string, bool) {
+	if ip := net.ParseIP(m.Address); ip == nil || ip.IsUnspecified() || m.Port == 0 {
+		return "", true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e := endpoint{m.Address, m.Port &^ 1}
+	if o, ok := p.used[e]; ok && o != owner {
+		return o, false
+	}
+	p.release(owner)
+	p.used[e] = owner
+	p.owned[owner] = e
+	return "", true
+}
+
+// Allocate returns the group address and port of the owner, allocated if the owner
+// has none in the network and port range of the planner
+func (p *MulticastPlanner) Allocate(owner string) (Multicast, error) {
+	ms, err := p.allocate([]string{owner})
+	if err != nil {
+		return Multicast{}, err
+	}
+	return ms[0], nil
+}
+
+// Release frees the group address and port of the owner
+func (p *MulticastPlanner) Release(owner string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.release(owner)
+}
+
+// Apply sets multicast group address, ports and TTL of the video encoder, audio encoder
+// and metadata configurations of the profile. On failure the previous multicast settings
+// are restored and the endpoints are released, the error is *RollbackError if restoring fails.
+func (p *MulticastPlanner) Apply(ctx context.Context, c *Client, profileToken string) (result *Profile, err error) {
+	profile, err := c.Profile(ctx, profileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	streams := multicastStreams(c, profile)
+	if len(streams) == 0 {
+		return profile, nil
+	}
+	owners := make([]string, len(streams))
+	for i, s := range streams {
+		owners[i] = s.owner
+	}
+
+	previous := p.owners(owners)
+	ms, err := p.allocate(owners)
+	if err != nil {
+		return nil, err
+	}
+
+	var undo []func(ctx context.Context) error
+	defer func() {
+		if err != nil {
+			err = rollback(undo, err)
+			p.restore(owners, previous)
+		}
+	}()
+
+	i := 0
+	if e := profile.VideoEncoder; e != nil {
+		original := *e
+		e.Multicast = p.multicast(e.Multicast, ms[i])
+		if err := c.SetVideoEncoderConfiguration(ctx, e); err != nil {
+			return nil, err
+		}
+		undo = append(undo, func(ctx context.Context) error {
+			return c.SetVideoEncoderConfiguration(ctx, &original)
+		})
+		i++
+	}
+	if e := profile.AudioEncoder; e != nil {
+		original := *e
+		e.Multicast = p.multicast(e.Multicast, ms[i])
+		if err := c.SetAudioEncoderConfiguration(ctx, e); err != nil {
+			return nil, err
+		}
+		undo = append(undo, func(ctx context.Context) error {
+			return c.SetAudioEncoderConfiguration(ctx, &original)
+		})
+		i++
+	}
+	if m := profile.Metadata; m != nil {
+		original := *m
+		m.Multicast = p.multicast(m.Multicast, ms[i])
+		if err := c.SetMetadataConfiguration(ctx, m); err != nil {
+			return nil, err
+		}
+		undo = append(undo, func(ctx context.Context) error {
+			return c.SetMetadataConfiguration(ctx, &original)
+		})
+	}
+
+	return c.Profile(ctx, profileToken)
+}
+
+// Verify checks that configurations of the profile use the allocated group addresses
+// and ports and returns the RTP multicast stream URI of the profile
+func (p *MulticastPlanner) Verify(ctx context.Context, c *Client, profileToken string) (*MediaURI, error) {
+	profile, err := c.Profile(ctx, profileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	for _, s := range multicastStreams(c, profile) {
+		e, ok := p.owned[s.owner]
+		if !ok || e.address != s.multicast.Address || e.port != s.multicast.Port || p.opts.ttl != s.multicast.TTL {
+			p.mu.Unlock()
+			return nil, fmt.Errorf("%s configuration %s multicast is %s:%d TTL %d, planned %s:%d TTL %d",
+				s.kind, s.token, s.multicast.Address, s.multicast.Port, s.multicast.TTL, e.address, e.port, p.opts.ttl)
+		}
+	}
+	p.mu.Unlock()
+
+	uri, err := c.StreamURI(ctx, profileToken, ProtocolRtspMulticast)
+	if err != nil {
+		return nil, err
+	}
+	if uri.URI == "" {
+		return nil, errNoMulticastURI
+	}
+	return uri, nil
+}
+
+// multicast returns the configuration with the allocated address, port and TTL
+func (p *MulticastPlanner) multicast(current, allocated Multicast) Multicast {
+	allocated.AutoStart = current.AutoStart
+	return allocated
+}
+
+// allocate returns endpoints of the owners on one group address. Endpoints the owners
+// have already are kept if they share the address and are in the planner range.
+func (p *MulticastPlanner) allocate(owners []string) ([]Multicast, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ms := make([]Multicast, len(owners))
+	keep := true
+	for i, owner := range owners {
+		e, ok := p.owned[owner]
+		if !ok || !p.contains(e) || e.address != p.owned[owners[0]].address {
+			keep = false
+			break
+		}
+		ms[i] = Multicast{Address: e.address, Port: e.port, TTL: p.opts.ttl}
+	}
+	if keep {
+		return ms, nil
+	}
+
+	released := map[string]endpoint{}
+	for _, owner := range owners {
+		if e, ok := p.owned[owner]; ok {
+			released[owner] = e
+			p.release(owner)
+		}
+	}
+
+	// groups of other streams are used only if all are taken, receivers
+	// joining the group get the packets of all its streams
+	groups := map[string]bool{}
+	for e := range p.used {
+		groups[e.address] = true
+	}
+
+	base := binary.BigEndian.Uint32(p.network.IP.To4())
+	size := ^binary.BigEndian.Uint32(net.IP(p.network.Mask).To4()) + 1
+	ip := make(net.IP, 4)
+	for n := uint32(0); n < 2*size; n++ {
+		binary.BigEndian.PutUint32(ip, base+n%size)
+		address := ip.String()
+		if n < size && groups[address] {
+			continue
+		}
+		for port := p.opts.minPort; port+2*len(owners)-1 <= p.opts.maxPort; port += 2 {
+			free := true
+			for i := range owners {
+				if _, ok := p.used[endpoint{address, port + 2*i}]; ok {
+					free = false
+					break
+				}
+			}
+			if !free {
+				continue
+			}
+			for i, owner := range owners {
+				e := endpoint{address, port + 2*i}
+				p.used[e] = owner
+				p.owned[owner] = e
+				ms[i] = Multicast{Address: address, Port: e.port, TTL: p.opts.ttl}
+			}
+			return ms, nil
+		}
+	}
+
+	// keep the previous endpoints
+	for owner, e := range released {
+		p.used[e] = owner
+		p.owned[owner] = e
+	}
+	return nil, errNoMulticastGroup
+}
+
+// contains reports if the endpoint is in the network and port range of the planner
+func (p *MulticastPlanner) contains(e endpoint) bool {
+	return p.network.Contains(net.ParseIP(e.address)) && e.port >= p.opts.minPort && e.port+1 <= p.opts.maxPort
+}
+
+// owners returns the endpoints of the owners
+func (p *MulticastPlanner) owners(owners []string) map[string]endpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	endpoints := map[string]endpoint{}
+	for _, owner := range owners {
+		if e, ok := p.owned[owner]; ok {
+			endpoints[owner] = e
+		}
+	}
+	return endpoints
+}
+
+// restore releases the endpoints of the owners and reserves the previous ones
+func (p *MulticastPlanner) restore(owners []string, previous map[string]endpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, owner := range owners {
+		p.release(owner)
+	}
+	for owner, e := range previous {
+		if _, ok := p.used[e]; !ok {
+			p.used[e] = owner
+			p.owned[owner] = e
+		}
+	}
+}
+
+func (p *MulticastPlanner) release(owner string) {
+	if e, ok := p.owned[owner]; ok {
+		delete(p.used, e)
+		delete(p.owned, owner)
+	}
+}
+
+// multicastStream is the configuration of the profile streamed over multicast
+type multicastStream struct {
+	kind      string
+	token     string
+	owner     string
+	multicast Multicast
+}
+
+// multicastStreams returns video encoder, audio encoder and metadata configurations of the profile
+func multicastStreams(c *Client, profile *Profile) []multicastStream {
+	var streams []multicastStream
+	add := func(kind, token string, m Multicast) {
+		streams = append(streams, multicastStream{kind, token, multicastOwner(c, kind, token), m})
+	}
+	if e := profile.VideoEncoder; e != nil {
+		add(ConfigurationVideoEncoder, e.Token, e.Multicast)
+	}
+	if e := profile.AudioEncoder; e != nil {
+		add(ConfigurationAudioEncoder, e.Token, e.Multicast)
+	}
+	if m := profile.Metadata; m != nil {
+		add(ConfigurationMetadata, m.Token, m.Multicast)
+	}
+	return streams
+}
+
+// multicastOwner identifies the configuration of the device in the planner
+func multicastOwner(c *Client, kind, token string) string {
+	return c.XAddr() + " " + kind + " " + token
+}
@@ -0,0 +1,91 @@
+package mediaclient
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// multicastXML returns tt:Multicast of the address and port
+func multicastXML(address, port string) string {
+	return `<tt:Multicast><tt:Address><tt:Type>IPv4</tt:Type><tt:IPv4Address>` + address + `</tt:IPv4Address></tt:Address>` +
+		`<tt:Port>` + port + `</tt:Port><tt:TTL>1</tt:TTL><tt:AutoStart>false</tt:AutoStart></tt:Multicast>`
+}
+
+func TestReserveDevice(t *testing.T) {
+	d := newDevice(map[string]string{
+		"GetVideoEncoderConfigurations": `<trt:GetVideoEncoderConfigurationsResponse>` +
+			`<trt:Configurations token="vec1"><tt:Name>vec1</tt:Name><tt:UseCount>1</tt:UseCount><tt:Encoding>H264</tt:Encoding>` + multicastXML("239.0.0.1", "40000") + `</trt:Configurations>` +
+			`<trt:Configurations token="vec2"><tt:Name>vec2</tt:Name><tt:UseCount>1</tt:UseCount><tt:Encoding>H264</tt:Encoding>` + multicastXML("239.0.0.2", "40000") + `</trt:Configurations>` +
+			`</trt:GetVideoEncoderConfigurationsResponse>`,
+		"GetAudioEncoderConfigurations": `<trt:GetAudioEncoderConfigurationsResponse>` +
+			`<trt:Configurations token="aec1"><tt:Name>aec1</tt:Name><tt:UseCount>1</tt:UseCount><tt:Encoding>G711</tt:Encoding>` + multicastXML("0.0.0.0", "0") + `</trt:Configurations>` +
+			`</trt:GetAudioEncoderConfigurationsResponse>`,
+		"GetMetadataConfigurations": `<trt:GetMetadataConfigurationsResponse/>`,
+	})
+	defer d.Close()
+
+	p, err := NewMulticastPlanner("239.0.0.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Reserve("other device", Multicast{Address: "239.0.0.2", Port: 40000}) {
+		t.Fatal("not reserved")
+	}
+
+	c := d.client(VersionMedia)
+	conflicts, err := p.ReserveDevice(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("conflicts %+v", conflicts)
+	}
+	if conflict := conflicts[0]; conflict.Kind != ConfigurationVideoEncoder || conflict.Token != "vec2" || conflict.Owner != "other device" {
+		t.Errorf("conflict %+v", conflict)
+	}
+	if p.Reserve("third device", Multicast{Address: "239.0.0.1", Port: 40000}) {
+		t.Error("endpoint of vec1 not reserved")
+	}
+}
+
+func TestApplyRollback(t *testing.T) {
+	d := newDevice(map[string]string{
+		"GetProfile": `<trt:GetProfileResponse><trt:Profile token="profile1" fixed="false"><tt:Name>profile1</tt:Name>` +
+			`<tt:VideoEncoderConfiguration token="vec1"><tt:Name>vec1</tt:Name><tt:UseCount>1</tt:UseCount><tt:Encoding>H264</tt:Encoding>` +
+			`<tt:Resolution><tt:Width>1920</tt:Width><tt:Height>1080</tt:Height></tt:Resolution><tt:Quality>5</tt:Quality>` +
+			multicastXML("239.1.0.1", "50000") + `<tt:SessionTimeout>PT60S</tt:SessionTimeout></tt:VideoEncoderConfiguration>` +
+			`<tt:AudioEncoderConfiguration token="aec1"><tt:Name>aec1</tt:Name><tt:UseCount>1</tt:UseCount><tt:Encoding>G711</tt:Encoding>` +
+			`<tt:Bitrate>64</tt:Bitrate><tt:SampleRate>8</tt:SampleRate>` + multicastXML("0.0.0.0", "0") + `<tt:SessionTimeout>PT60S</tt:SessionTimeout></tt:AudioEncoderConfiguration>` +
+			`</trt:Profile></trt:GetProfileResponse>`,
+		"SetVideoEncoderConfiguration": `<trt:SetVideoEncoderConfigurationResponse/>`,
+		"SetAudioEncoderConfiguration": `<trt:SetAudioEncoderConfigurationResponse/>`,
+	})
+	defer d.Close()
+	d.fail["SetAudioEncoderConfiguration"] = true
+
+	p, err := NewMulticastPlanner("239.0.0.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := d.client(VersionMedia)
+	if _, err := p.Apply(context.Background(), c, "profile1"); err == nil {
+		t.Fatal("no error")
+	} else if _, ok := err.(*RollbackError); ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	requests := d.requested("SetVideoEncoderConfiguration")
+	if len(requests) != 2 || !strings.Contains(requests[0], ">239.0.0.0<") || !strings.Contains(requests[1], ">239.1.0.1<") {
+		t.Errorf("video encoder multicast is not restored: %v", requests)
+	}
+
+	// the endpoints are released
+	m, err := p.Allocate("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Address != "239.0.0.0" || m.Port != 40000 {
+		t.Errorf("allocated %+v", m)
+	}
+}
@@ -0,0 +1,208 @@
+package mediaclient
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/videonext/onvif/profiles/media"
+)
+
+// Resolution type
+type Resolution struct {
+	Width  int
+	Height int
+}
+
+// IntRange is the range of integer values, both zero if not reported
+type IntRange struct {
+	Min int
+	Max int
+}
+
+// FloatRange is the range of float values, both zero if not reported
+type FloatRange struct {
+	Min float64
+	Max float64
+}
+
+// VideoEncoderOptions are the values supported by the video encoder configuration
+// for the encoding. Values not reported by the device are zero or nil.
+type VideoEncoderOptions struct {
+	// Encoding, e.g. EncodingH264
+	Encoding string
+
+	Resolutions []Resolution
+
+	QualityRange FloatRange
+
+	// Supported frame rates of media2, FrameRateRange of the media service
+	FrameRates     []float64
+	FrameRateRange FloatRange
+
+	// Range of EncodingInterval, media service only
+	EncodingIntervalRange IntRange
+
+	GovLengthRange IntRange
+
+	// Bitrate range in kbps
+	BitrateRange IntRange
+
+	// Codec profiles, e.g. "Main" or "High"
+	Profiles []string
+
+	ConstantBitRateSupported     bool
+	GuaranteedFrameRateSupported bool
+}
+
+// VideoEncoderOptions returns the options of the video encoder configuration, one per
+// supported encoding. With the profile token the options are limited to the ones
+// usable in the profile.
+func (c *Client) VideoEncoderOptions(ctx context.Context, configurationToken, profileToken string) ([]VideoEncoderOptions, error) {
+	if c.version == VersionMedia2 {
+		reply := &getVideoEncoderConfigurationOptionsResponse{}
+		err := c.getConfiguration2(ctx, "GetVideoEncoderConfigurationOptions", configurationToken, profileToken, reply)
+		if err != nil {
+			return nil, err
+		}
+		options := []VideoEncoderOptions{}
+		for i := range reply.Options {
+			options = append(options, fromVideoEncoderOptions2(&reply.Options[i]))
+		}
+		return options, nil
+	}
+
+	reply, err := c.media().GetVideoEncoderConfigurationOptionsContext(ctx, &media.GetVideoEncoderConfigurationOptions{
+		ConfigurationToken: media.ReferenceToken(configurationToken),
+		ProfileToken:       media.ReferenceToken(profileToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromVideoEncoderOptions(&reply.Options), nil
+}
+
+func fromVideoEncoderOptions(o *media.VideoEncoderConfigurationOptions) []VideoEncoderOptions {
+	quality := FloatRange{float64(o.QualityRange.Min), float64(o.QualityRange.Max)}
+	options := []VideoEncoderOptions{}
+
+	if len(o.JPEG.ResolutionsAvailable) > 0 {
+		options = append(options, VideoEncoderOptions{
+			Encoding:                     EncodingJPEG,
+			Resolutions:                  fromResolutions(o.JPEG.ResolutionsAvailable),
+			QualityRange:                 quality,
+			FrameRateRange:               FloatRange{float64(o.JPEG.FrameRateRange.Min), float64(o.JPEG.FrameRateRange.Max)},
+			EncodingIntervalRange:        fromIntRange(&o.JPEG.EncodingIntervalRange),
+			BitrateRange:                 fromIntRange(&o.Extension.JPEG.BitrateRange),
+			GuaranteedFrameRateSupported: o.GuaranteedFrameRateSupported,
+		})
+	}
+	if len(o.MPEG4.ResolutionsAvailable) > 0 {
+		e := VideoEncoderOptions{
+			Encoding:                     EncodingMPEG4,
+			Resolutions:                  fromResolutions(o.MPEG4.ResolutionsAvailable),
+			QualityRange:                 quality,
+			FrameRateRange:               FloatRange{float64(o.MPEG4.FrameRateRange.Min), float64(o.MPEG4.FrameRateRange.Max)},
+			EncodingIntervalRange:        fromIntRange(&o.MPEG4.EncodingIntervalRange),
+			GovLengthRange:               fromIntRange(&o.MPEG4.GovLengthRange),
+			BitrateRange:                 fromIntRange(&o.Extension.MPEG4.BitrateRange),
+			GuaranteedFrameRateSupported: o.GuaranteedFrameRateSupported,
+		}
+		for _, p := range o.MPEG4.Mpeg4ProfilesSupported {
+			e.Profiles = append(e.Profiles, string(p))
+		}
+		options = append(options, e)
+	}
+	if len(o.H264.ResolutionsAvailable) > 0 {
+		e := VideoEncoderOptions{
+			Encoding:                     EncodingH264,
+			Resolutions:                  fromResolutions(o.H264.ResolutionsAvailable),
+			QualityRange:                 quality,
+			FrameRateRange:               FloatRange{float64(o.H264.FrameRateRange.Min), float64(o.H264.FrameRateRange.Max)},
+			EncodingIntervalRange:        fromIntRange(&o.H264.EncodingIntervalRange),
+			GovLengthRange:               fromIntRange(&o.H264.GovLengthRange),
+			BitrateRange:                 fromIntRange(&o.Extension.H264.BitrateRange),
+			GuaranteedFrameRateSupported: o.GuaranteedFrameRateSupported,
+		}
+		for _, p := range o.H264.H264ProfilesSupported {
+			e.Profiles = append(e.Profiles, string(p))
+		}
+		options = append(options, e)
+	}
+	return options
+}
+
+func fromResolutions(rs []media.VideoResolution) []Resolution {
+	resolutions := []Resolution{}
+	for _, r := range rs {
+		resolutions = append(resolutions, Resolution{int(r.Width), int(r.Height)})
+	}
+	return resolutions
+}
+
+func fromIntRange(r *media.IntRange) IntRange {
+	return IntRange{int(r.Min), int(r.Max)}
+}
+
+// getVideoEncoderConfigurationOptionsResponse is the media2 response. The generated
+// options declare the attribute lists as slices, which the decoder does not split.
+type getVideoEncoderConfigurationOptionsResponse struct {
+	Options []videoEncoder2ConfigurationOptions `xml:"Options"`
+}
+
+type videoEncoder2ConfigurationOptions struct {
+	Encoding string `xml:"Encoding"`
+
+	QualityRange struct {
+		Min float64 `xml:"Min"`
+		Max float64 `xml:"Max"`
+	} `xml:"QualityRange"`
+
+	ResolutionsAvailable []struct {
+		Width  int `xml:"Width"`
+		Height int `xml:"Height"`
+	} `xml:"ResolutionsAvailable"`
+
+	BitrateRange struct {
+		Min int `xml:"Min"`
+		Max int `xml:"Max"`
+	} `xml:"BitrateRange"`
+
+	GovLengthRange               string `xml:"GovLengthRange,attr"`
+	FrameRatesSupported          string `xml:"FrameRatesSupported,attr"`
+	ProfilesSupported            string `xml:"ProfilesSupported,attr"`
+	ConstantBitRateSupported     bool   `xml:"ConstantBitRateSupported,attr"`
+	GuaranteedFrameRateSupported bool   `xml:"GuaranteedFrameRateSupported,attr"`
+}
+
+func fromVideoEncoderOptions2(o *videoEncoder2ConfigurationOptions) VideoEncoderOptions {
+	e := VideoEncoderOptions{
+		Encoding:                     o.Encoding,
+		QualityRange:                 FloatRange{o.QualityRange.Min, o.QualityRange.Max},
+		BitrateRange:                 IntRange{o.BitrateRange.Min, o.BitrateRange.Max},
+		Profiles:                     strings.Fields(o.ProfilesSupported),
+		ConstantBitRateSupported:     o.ConstantBitRateSupported,
+		GuaranteedFrameRateSupported: o.GuaranteedFrameRateSupported,
+	}
+	for _, r := range o.ResolutionsAvailable {
+		e.Resolutions = append(e.Resolutions, Resolution{r.Width, r.Height})
+	}
+
+	// GovLengthRange is the list of the min and max values
+	var gov []int
+	for _, s := range strings.Fields(o.GovLengthRange) {
+		if n, err := strconv.Atoi(s); err == nil {
+			gov = append(gov, n)
+		}
+	}
+	if len(gov) == 2 {
+		e.GovLengthRange = IntRange{gov[0], gov[1]}
+	}
+
+	for _, s := range strings.Fields(o.FrameRatesSupported) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			e.FrameRates = append(e.FrameRates, f)
+		}
+	}
+	return e
+}
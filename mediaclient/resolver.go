@@ -0,0 +1,283 @@
+package mediaclient
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/videonext/onvif/profiles/media"
+	"github.com/videonext/onvif/profiles/media2"
+)
+
+// HostRewrite selects when the host of the URIs returned by the device is replaced
+// with the host the device was reached on
+type HostRewrite int
+
+const (
+	// RewriteUnreachable replaces unspecified, loopback and link-local hosts, and
+	// private addresses if the device was reached on a public address or name
+	RewriteUnreachable HostRewrite = iota
+
+	// RewriteAlways replaces every host, e.g. for devices behind NAT
+	RewriteAlways
+
+	// RewriteNever keeps the URIs as returned
+	RewriteNever
+)
+
+// Protocols tried by ResolveStreamURIs by default. RTSPS is not listed
+// since devices do not advertise it, use WithProtocols to try it.
+// RTP-Unicast/UDP and RTP-Unicast/TCP are tried with the media service only.
+var defaultProtocols = []Protocol{
+	ProtocolRtspUnicast, ProtocolRtspMulticast, ProtocolRtspOverHttp,
+	ProtocolRtpUnicastUdp, ProtocolRtpUnicastTcp,
+}
+
+// ResolvedURI is the stream URI of the profile and protocol
+type ResolvedURI struct {
+	ProfileToken string
+	ProfileName  string
+	Protocol     Protocol
+
+	// URI to use, with the host rewritten and credentials embedded if requested
+	URI string
+
+	// URI as returned by the device
+	DeviceURI string
+
+	// Rewritten is set if the host was replaced
+	Rewritten bool
+
+	// Validity reported by the media service, media2 does not report it
+	InvalidAfterConnect bool
+	InvalidAfterReboot  bool
+	Timeout             time.Duration
+
+	// Time the URI expires, zero if it does not
+	ValidUntil time.Time
+
+	// Error of GetStreamUri, other fields except the profile and protocol are empty
+	Err error
+}
+
+type resolveOptions struct {
+	host        string
+	rewrite     HostRewrite
+	protocols   []Protocol
+	username    string
+	password    string
+	credentials bool
+}
+
+// ResolveOption type
+type ResolveOption func(*resolveOptions)
+
+// WithHost is a ResolveOption to set the host the device is reached on,
+// by default the host of the media service address
+func WithHost(host string) ResolveOption {
+	return func(o *resolveOptions) {
+		o.host = host
+	}
+}
+
+// WithHostRewrite is a ResolveOption to set when the hosts are rewritten
+func WithHostRewrite(mode HostRewrite) ResolveOption {
+	return func(o *resolveOptions) {
+		o.rewrite = mode
+	}
+}
+
+// WithProtocols is a ResolveOption to set the protocols to try. They are tried
+// even if the device does not advertise them.
+func WithProtocols(protocols ...Protocol) ResolveOption {
+	return func(o *resolveOptions) {
+		o.protocols = protocols
+	}
+}
+
+// WithURICredentials is a ResolveOption to embed user name and password into the URIs
+func WithURICredentials(username, password string) ResolveOption {
+	return func(o *resolveOptions) {
+		o.username = username
+		o.password = password
+		o.credentials = true
+	}
+}
+
+// ResolveStreamURIs returns stream URIs of all profiles for all protocols supported
+// by the device. Failed requests are reported with ResolvedURI.Err, the error is
+// returned only if the profiles or capabilities could not be retrieved.
+func (c *Client) ResolveStreamURIs(ctx context.Context, opt ...ResolveOption) ([]ResolvedURI, error) {
+	o := resolveOptions{}
+	for _, f := range opt {
+		f(&o)
+	}
+	if o.host == "" {
+		if u, err := url.Parse(c.xaddr); err == nil {
+			o.host = u.Hostname()
+		}
+	}
+
+	protocols := o.protocols
+	if protocols == nil {
+		var err error
+		if protocols, err = c.streamingProtocols(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	profiles, err := c.Profiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uris := []ResolvedURI{}
+	for _, p := range profiles {
+		for _, protocol := range protocols {
+			r := ResolvedURI{ProfileToken: p.Token, ProfileName: p.Name, Protocol: protocol}
+
+			u, err := c.StreamURI(ctx, p.Token, protocol)
+			if err != nil {
+				r.Err = err
+				uris = append(uris, r)
+				continue
+			}
+
+			r.DeviceURI = u.URI
+			r.InvalidAfterConnect = u.InvalidAfterConnect
+			r.InvalidAfterReboot = u.InvalidAfterReboot
+			r.Timeout = u.Timeout
+			if u.Timeout > 0 {
+				r.ValidUntil = time.Now().Add(u.Timeout)
+			}
+
+			r.URI, r.Rewritten, r.Err = RewriteHost(u.URI, o.host, o.rewrite)
+			if r.Err == nil && o.credentials {
+				r.URI, r.Err = EmbedCredentials(r.URI, o.username, o.password)
+			}
+			uris = append(uris, r)
+		}
+	}
+	return uris, nil
+}
+
+// streamingProtocols returns default protocols advertised by the media service
+func (c *Client) streamingProtocols(ctx context.Context) ([]Protocol, error) {
+	protocols := []Protocol{}
+
+	if c.version == VersionMedia2 {
+		reply, err := c.media2().GetServiceCapabilitiesContext(ctx, &media2.GetServiceCapabilities{})
+		if err != nil {
+			return nil, err
+		}
+		caps := &reply.Capabilities.StreamingCapabilities
+		for _, p := range defaultProtocols {
+			switch {
+			case p == ProtocolRtspMulticast && !caps.RTPMulticast:
+			case p == ProtocolRtpUnicastUdp || p == ProtocolRtpUnicastTcp:
+			default:
+				protocols = append(protocols, p)
+			}
+		}
+		return protocols, nil
+	}
+
+	reply, err := c.media().GetServiceCapabilitiesContext(ctx, &media.GetServiceCapabilities{})
+	if err != nil {
+		return nil, err
+	}
+	caps := &reply.Capabilities.StreamingCapabilities
+	if caps.NoRTSPStreaming {
+		return protocols, nil
+	}
+	// RTSP over HTTP tunnelling is mandatory, RTP_TCP is about RTP over TCP only
+	for _, p := range defaultProtocols {
+		switch {
+		case p == ProtocolRtspMulticast && !caps.RTPMulticast:
+		case p == ProtocolRtpUnicastTcp && !caps.RTP_TCP:
+		default:
+			protocols = append(protocols, p)
+		}
+	}
+	return protocols, nil
+}
+
+// RewriteHost replaces host of the URI with given host according to the mode, the port
+// is kept. It reports if the host was replaced.
+func RewriteHost(uri, host string, mode HostRewrite) (string, bool, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri, false, err
+	}
+	if host == "" || mode == RewriteNever || strings.EqualFold(u.Hostname(), host) {
+		return uri, false, nil
+	}
+	if mode == RewriteUnreachable && !unreachable(u.Hostname(), host) {
+		return uri, false, nil
+	}
+
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+	return u.String(), true, nil
+}
+
+// unreachable reports if the URI host is not usable from the side the device was reached on
+func unreachable(uriHost, reachedHost string) bool {
+	if uriHost == "" || strings.EqualFold(uriHost, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(uriHost)
+	if ip == nil {
+		return false
+	}
+	if ip.IsUnspecified() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return true
+	}
+	if !isPrivate(ip) {
+		return false
+	}
+
+	reached := net.ParseIP(reachedHost)
+	return reached == nil || !isPrivate(reached)
+}
+
+// privateNetworks are RFC 1918 and RFC 4193 networks
+var privateNetworks = func() []*net.IPNet {
+	networks := []*net.IPNet{}
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		networks = append(networks, n)
+	}
+	return networks
+}()
+
+func isPrivate(ip net.IP) bool {
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// EmbedCredentials sets user name and password of the URI, e.g. for players
+// not supporting separate credentials
+func EmbedCredentials(uri, username, password string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri, err
+	}
+	if username == "" {
+		u.User = nil
+	} else {
+		u.User = url.UserPassword(username, password)
+	}
+	return u.String(), nil
+}
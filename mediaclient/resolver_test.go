@@ -0,0 +1,78 @@
+package mediaclient
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestResolveStreamURIsMedia(t *testing.T) {
+	d := newDevice(map[string]string{
+		"GetServiceCapabilities": `<trt:GetServiceCapabilitiesResponse><trt:Capabilities>` +
+			`<trt:StreamingCapabilities RTPMulticast="false" RTP_TCP="true" RTP_RTSP_TCP="true"/></trt:Capabilities></trt:GetServiceCapabilitiesResponse>`,
+		"GetProfiles": `<trt:GetProfilesResponse><trt:Profiles token="profile1" fixed="true"><tt:Name>main</tt:Name></trt:Profiles></trt:GetProfilesResponse>`,
+		"GetStreamUri": `<trt:GetStreamUriResponse><trt:MediaUri><tt:Uri>rtsp://192.168.0.10/stream1</tt:Uri>` +
+			`<tt:InvalidAfterConnect>false</tt:InvalidAfterConnect><tt:InvalidAfterReboot>false</tt:InvalidAfterReboot><tt:Timeout>PT0S</tt:Timeout></trt:MediaUri></trt:GetStreamUriResponse>`,
+	})
+	defer d.Close()
+
+	uris, err := d.client(VersionMedia).ResolveStreamURIs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocols := []Protocol{ProtocolRtspUnicast, ProtocolRtspOverHttp, ProtocolRtpUnicastUdp, ProtocolRtpUnicastTcp}
+	if len(uris) != len(protocols) {
+		t.Fatalf("%d URIs, expected %d", len(uris), len(protocols))
+	}
+	for i, u := range uris {
+		if u.Protocol != protocols[i] || u.Err != nil || u.DeviceURI != "rtsp://192.168.0.10/stream1" {
+			t.Errorf("URI %d: %+v", i, u)
+		}
+	}
+
+	// stream setups of the protocols
+	requests := d.requested("GetStreamUri")
+	for i, setup := range []string{"RTP-Unicast RTSP", "RTP-Unicast HTTP", "RTP-Unicast UDP", "RTP-Unicast TCP"} {
+		fields := strings.Fields(setup)
+		if i >= len(requests) || !strings.Contains(requests[i], ">"+fields[0]+"<") || !strings.Contains(requests[i], ">"+fields[1]+"<") {
+			t.Errorf("request %d is not %s", i, setup)
+		}
+	}
+}
+
+func TestResolveStreamURIsMediaNoTCP(t *testing.T) {
+	d := newDevice(map[string]string{
+		"GetServiceCapabilities": `<trt:GetServiceCapabilitiesResponse><trt:Capabilities>` +
+			`<trt:StreamingCapabilities RTPMulticast="false" RTP_TCP="false" RTP_RTSP_TCP="true"/></trt:Capabilities></trt:GetServiceCapabilitiesResponse>`,
+		"GetProfiles": `<trt:GetProfilesResponse><trt:Profiles token="profile1" fixed="true"><tt:Name>main</tt:Name></trt:Profiles></trt:GetProfilesResponse>`,
+		"GetStreamUri": `<trt:GetStreamUriResponse><trt:MediaUri><tt:Uri>rtsp://192.168.0.10/stream1</tt:Uri>` +
+			`<tt:InvalidAfterConnect>false</tt:InvalidAfterConnect><tt:InvalidAfterReboot>false</tt:InvalidAfterReboot><tt:Timeout>PT0S</tt:Timeout></trt:MediaUri></trt:GetStreamUriResponse>`,
+	})
+	defer d.Close()
+
+	uris, err := d.client(VersionMedia).ResolveStreamURIs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// RTSP over HTTP does not depend on RTP_TCP
+	protocols := []Protocol{ProtocolRtspUnicast, ProtocolRtspOverHttp, ProtocolRtpUnicastUdp}
+	if len(uris) != len(protocols) {
+		t.Fatalf("%d URIs, expected %d", len(uris), len(protocols))
+	}
+	for i, u := range uris {
+		if u.Protocol != protocols[i] || u.Err != nil {
+			t.Errorf("URI %d: %+v", i, u)
+		}
+	}
+}
+
+func TestStreamURIMedia2(t *testing.T) {
+	d := newDevice(map[string]string{})
+	defer d.Close()
+
+	for _, protocol := range []Protocol{ProtocolRtpUnicastUdp, ProtocolRtpUnicastTcp} {
+		if _, err := d.client(VersionMedia2).StreamURI(context.Background(), "profile1", protocol); err != errUnsupportedProtocol {
+			t.Errorf("%s: error %v", protocol, err)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package mediaclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/videonext/onvif/auth"
+)
+
+// defaultCaptureInterval is used by Capture if neither interval nor the minimum
+// interval is positive
+const defaultCaptureInterval = time.Second
+
+var (
+	errNotJPEG          = errors.New("Snapshot is not a JPEG image")
+	errSnapshotTooLarge = errors.New("Snapshot exceeds the maximum size")
+)
+
+// Snapshot is the JPEG image of the profile
+type Snapshot struct {
+	ProfileToken string
+	ContentType  string
+	Data         []byte
+
+	// Time the image was downloaded
+	Time time.Time
+
+	// Error of the periodic capture, other fields except the profile are empty
+	Err error
+}
+
+// Snapshotter downloads snapshots of the profiles. Snapshot URIs are cached per
+// profile unless the device reports them invalid after connect or timed out.
+type Snapshotter struct {
+	client *Client
+
+	httpClient  *http.Client
+	username    string
+	password    string
+	host        string
+	rewrite     HostRewrite
+	maxSize     int64
+	minInterval time.Duration
+
+	mu        sync.Mutex
+	uris      map[string]*cachedURI
+	last      map[string]*Snapshot
+	challenge *auth.Challenge
+}
+
+type cachedURI struct {
+	uri        string
+	reusable   bool
+	validUntil time.Time
+}
+
+// SnapshotOption type
+type SnapshotOption func(*Snapshotter)
+
+// WithHTTPCredentials is a SnapshotOption to set HTTP credentials of the snapshot URIs,
+// by default the credentials of the Client are used
+func WithHTTPCredentials(username, password string) SnapshotOption {
+	return func(s *Snapshotter) {
+		s.username = username
+		s.password = password
+	}
+}
+
+// WithHTTPClient is a SnapshotOption to set the HTTP client
+func WithHTTPClient(c *http.Client) SnapshotOption {
+	return func(s *Snapshotter) {
+		s.httpClient = c
+	}
+}
+
+// WithSnapshotHost is a SnapshotOption to set the host the device is reached on
+// and when the host of the URIs is rewritten, see ResolveStreamURIs
+func WithSnapshotHost(host string, mode HostRewrite) SnapshotOption {
+	return func(s *Snapshotter) {
+		s.host = host
+		s.rewrite = mode
+	}
+}
+
+// WithMaxSize is a SnapshotOption to set the maximum image size in bytes
+func WithMaxSize(n int64) SnapshotOption {
+	return func(s *Snapshotter) {
+		s.maxSize = n
+	}
+}
+
+// WithMinInterval is a SnapshotOption to limit the rate of downloads per profile,
+// the last snapshot is returned if it is more recent than the interval
+func WithMinInterval(d time.Duration) SnapshotOption {
+	return func(s *Snapshotter) {
+		s.minInterval = d
+	}
+}
+
+// NewSnapshotter creates Snapshotter using the media client
+func (c *Client) NewSnapshotter(opt ...SnapshotOption) *Snapshotter {
+	s := &Snapshotter{
+		client:     c,
+		httpClient: http.DefaultClient,
+		username:   c.opts.username,
+		password:   c.opts.password,
+		maxSize:    10 << 20,
+		uris:       map[string]*cachedURI{},
+		last:       map[string]*Snapshot{},
+	}
+	if u, err := url.Parse(c.xaddr); err == nil {
+		s.host = u.Hostname()
+	}
+	for _, o := range opt {
+		o(s)
+	}
+	return s
+}
+
+// Snapshot downloads the snapshot of the profile. The URI is resolved again
+// if the download with the cached one fails.
+func (s *Snapshotter) Snapshot(ctx context.Context, profileToken string) (*Snapshot, error) {
+	s.mu.Lock()
+	last := s.last[profileToken]
+	s.mu.Unlock()
+	if last != nil && time.Since(last.Time) < s.minInterval {
+		return last, nil
+	}
+
+	uri, cached, err := s.uri(ctx, profileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := s.download(ctx, uri)
+	if err != nil && cached {
+		s.invalidate(profileToken)
+		if uri, _, err = s.uri(ctx, profileToken); err != nil {
+			return nil, err
+		}
+		snapshot, err = s.download(ctx, uri)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.ProfileToken = profileToken
+	s.mu.Lock()
+	s.last[profileToken] = snapshot
+	s.mu.Unlock()
+	return snapshot, nil
+}
+
+// Capture downloads snapshots of the profiles every interval until the context is
+// done, e.g. for thumbnails. Snapshots not received in time are dropped. The interval
+// is at least the minimum interval, one second if neither is positive.
+func (s *Snapshotter) Capture(ctx context.Context, interval time.Duration, profileTokens ...string) <-chan Snapshot {
+	if interval < s.minInterval {
+		interval = s.minInterval
+	}
+	if interval <= 0 {
+		interval = defaultCaptureInterval
+	}
+
+	snapshots := make(chan Snapshot, len(profileTokens))
+	go func() {
+		defer close(snapshots)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			for _, token := range profileTokens {
+				snapshot, err := s.Snapshot(ctx, token)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					snapshot = &Snapshot{ProfileToken: token, Err: err}
+				}
+				select {
+				case snapshots <- *snapshot:
+				default:
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return snapshots
+}
+
+// uri returns snapshot URI of the profile and reports if it was cached
+func (s *Snapshotter) uri(ctx context.Context, profileToken string) (string, bool, error) {
+	s.mu.Lock()
+	c := s.uris[profileToken]
+	s.mu.Unlock()
+	if c != nil && c.reusable && (c.validUntil.IsZero() || time.Now().Before(c.validUntil)) {
+		return c.uri, true, nil
+	}
+
+	u, err := s.client.SnapshotURI(ctx, profileToken)
+	if err != nil {
+		return "", false, err
+	}
+	uri, _, err := RewriteHost(u.URI, s.host, s.rewrite)
+	if err != nil {
+		return "", false, err
+	}
+
+	c = &cachedURI{uri: uri, reusable: !u.InvalidAfterConnect}
+	if u.Timeout > 0 {
+		c.validUntil = time.Now().Add(u.Timeout)
+	}
+	s.mu.Lock()
+	s.uris[profileToken] = c
+	s.mu.Unlock()
+	return uri, false, nil
+}
+
+func (s *Snapshotter) invalidate(profileToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uris, profileToken)
+}
+
+// download gets the image, authenticating with the challenge of the previous
+// response or the one received with 401 status
+func (s *Snapshotter) download(ctx context.Context, uri string) (*Snapshot, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password := s.username, s.password
+	if u.User != nil {
+		if username == "" {
+			username = u.User.Username()
+			password, _ = u.User.Password()
+		}
+		u.User = nil
+	}
+
+	s.mu.Lock()
+	challenge := s.challenge
+	s.mu.Unlock()
+
+	resp, err := s.get(ctx, u, challenge, username, password)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && username != "" {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		challenge, err = auth.SelectChallenge(resp.Header["Www-Authenticate"])
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		s.challenge = challenge
+		s.mu.Unlock()
+
+		if resp, err = s.get(ctx, u, challenge, username, password); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, fmt.Errorf("Snapshot request failed with status %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, s.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.maxSize {
+		return nil, errSnapshotTooLarge
+	}
+
+	// Some devices send no or generic content type, the data is checked then
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(strings.ToLower(contentType), "image/jpeg") {
+		if http.DetectContentType(data) != "image/jpeg" {
+			return nil, errNotJPEG
+		}
+		contentType = "image/jpeg"
+	}
+
+	return &Snapshot{ContentType: contentType, Data: data, Time: time.Now()}, nil
+}
+
+func (s *Snapshotter) get(ctx context.Context, u *url.URL, challenge *auth.Challenge, username, password string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if challenge != nil && username != "" {
+		req.Header.Set("Authorization", challenge.Authorization(username, password, "GET", u.RequestURI()))
+	}
+	return s.httpClient.Do(req)
+}
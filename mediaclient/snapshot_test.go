@@ -0,0 +1,23 @@
+package mediaclient
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCaptureZeroInterval(t *testing.T) {
+	d := newDevice(map[string]string{})
+	defer d.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	snapshots := d.client(VersionMedia).NewSnapshotter().Capture(ctx, 0, "profile1")
+
+	snapshot, ok := <-snapshots
+	if !ok || snapshot.ProfileToken != "profile1" || snapshot.Err == nil {
+		t.Errorf("snapshot %+v, expected GetSnapshotUri error", snapshot)
+	}
+
+	cancel()
+	for range snapshots {
+	}
+}
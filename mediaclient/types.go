@@ -0,0 +1,547 @@
+package mediaclient
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/videonext/onvif/profiles/media"
+	"github.com/videonext/onvif/profiles/media2"
+)
+
+// Encoding names, media2 MIME subtypes. Encodings of the media service
+// are converted, e.g. G711 to PCMU.
+const (
+	EncodingJPEG  = "JPEG"
+	EncodingMPEG4 = "MPV4-ES"
+	EncodingH264  = "H264"
+	EncodingH265  = "H265"
+
+	EncodingPCMU = "PCMU"
+	EncodingG726 = "G726"
+	EncodingAAC  = "MP4A-LATM"
+)
+
+// Profile is the media profile with its configurations, nil if not assigned
+type Profile struct {
+	Token string
+	Name  string
+	Fixed bool
+
+	VideoSource  *VideoSourceConfig
+	VideoEncoder *VideoEncoderConfig
+	AudioSource  *AudioSourceConfig
+	AudioEncoder *AudioEncoderConfig
+	Metadata     *MetadataConfig
+
+	// Audio output and decoder of the backchannel
+	AudioOutput  *AudioOutputConfig
+	AudioDecoder *Config
+
+	// Tokens of the PTZ and analytics configurations, empty if not assigned
+	PTZConfigurationToken       string
+	AnalyticsConfigurationToken string
+}
+
+// Config contains the fields common to all configurations
+type Config struct {
+	Token string
+	Name  string
+
+	// Number of profiles using the configuration
+	UseCount int
+}
+
+// Rectangle type
+type Rectangle struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// Multicast is the multicast configuration of the stream
+type Multicast struct {
+	Address   string
+	Port      int
+	TTL       int
+	AutoStart bool
+}
+
+// VideoSourceConfig is the video source configuration
+type VideoSourceConfig struct {
+	Config
+
+	SourceToken string
+	Bounds      Rectangle
+}
+
+// VideoEncoderConfig is the video encoder configuration
+type VideoEncoderConfig struct {
+	Config
+
+	// Encoding, e.g. EncodingH264
+	Encoding string
+
+	Width  int
+	Height int
+
+	Quality float64
+
+	// Frames per second, zero if not limited
+	FrameRateLimit float64
+
+	// Interval at which images are encoded, media service only
+	EncodingInterval int
+
+	// Bitrate in kbps, zero if not limited
+	BitrateLimit int
+
+	ConstantBitRate bool
+
+	// Group of pictures length
+	GovLength int
+
+	// Codec profile, e.g. "Main" or "High"
+	Profile string
+
+	GuaranteedFrameRate bool
+
+	Multicast Multicast
+
+	// Session timeout of the media service, zero for media2
+	SessionTimeout time.Duration
+}
+
+// AudioSourceConfig is the audio source configuration
+type AudioSourceConfig struct {
+	Config
+
+	SourceToken string
+}
+
+// AudioOutputConfig is the audio output configuration
+type AudioOutputConfig struct {
+	Config
+
+	OutputToken string
+
+	// Active direction of half duplex outputs, e.g. "www.onvif.org/ver20/HalfDuplex/Server"
+	SendPrimacy string
+
+	OutputLevel int
+}
+
+// AudioEncoderConfig is the audio encoder configuration
+type AudioEncoderConfig struct {
+	Config
+
+	// Encoding, e.g. EncodingPCMU
+	Encoding string
+
+	// Bitrate in kbps and sample rate in kHz
+	Bitrate    int
+	SampleRate int
+
+	Multicast Multicast
+
+	// Session timeout of the media service, zero for media2
+	SessionTimeout time.Duration
+}
+
+// MetadataConfig is the metadata stream configuration
+type MetadataConfig struct {
+	Config
+
+	// Analytics events are included
+	Analytics bool
+
+	// PTZ status and position are included
+	PTZStatus   bool
+	PTZPosition bool
+
+	// Compression of the metadata, e.g. "None" or "GZIP"
+	CompressionType string
+
+	GeoLocation bool
+
+	Multicast Multicast
+
+	SessionTimeout time.Duration
+}
+
+// OSD is the on-screen display configuration
+type OSD struct {
+	Token string
+
+	VideoSourceConfigurationToken string
+
+	// Type is "Text", "Image" or "Extended"
+	Type string
+
+	// Position type, e.g. "UpperLeft" or "Custom" with X and Y in [-1, 1]
+	Position string
+	X        float64
+	Y        float64
+
+	// Text type, e.g. "Plain", "Date" or "DateAndTime"
+	TextType   string
+	PlainText  string
+	DateFormat string
+	TimeFormat string
+	FontSize   int
+
+	// Image path of the Image OSD
+	ImagePath string
+}
+
+// MediaURI is the stream or snapshot URI
+type MediaURI struct {
+	URI string
+
+	// Validity reported by the media service, media2 does not report it
+	InvalidAfterConnect bool
+	InvalidAfterReboot  bool
+	Timeout             time.Duration
+}
+
+// videoEncodings maps media service encodings
+var videoEncodings = map[media.VideoEncoding]string{
+	media.VideoEncodingJPEG:  EncodingJPEG,
+	media.VideoEncodingMPEG4: EncodingMPEG4,
+	media.VideoEncodingH264:  EncodingH264,
+}
+
+// audioEncodings maps media service encodings
+var audioEncodings = map[media.AudioEncoding]string{
+	media.AudioEncodingG711: EncodingPCMU,
+	media.AudioEncodingG726: EncodingG726,
+	media.AudioEncodingAAC:  EncodingAAC,
+}
+
+func fromProfile(p *media.Profile) Profile {
+	profile := Profile{Token: string(p.Token), Name: string(p.Name), Fixed: p.Fixed}
+	if p.VideoSourceConfiguration.ConfigurationEntity != nil {
+		c := fromVideoSource(&p.VideoSourceConfiguration)
+		profile.VideoSource = &c
+	}
+	if p.VideoEncoderConfiguration.ConfigurationEntity != nil {
+		c := fromVideoEncoder(&p.VideoEncoderConfiguration)
+		profile.VideoEncoder = &c
+	}
+	if p.AudioSourceConfiguration.ConfigurationEntity != nil {
+		c := AudioSourceConfig{
+			Config:      fromEntity(p.AudioSourceConfiguration.ConfigurationEntity),
+			SourceToken: string(p.AudioSourceConfiguration.SourceToken),
+		}
+		profile.AudioSource = &c
+	}
+	if p.AudioEncoderConfiguration.ConfigurationEntity != nil {
+		c := fromAudioEncoder(&p.AudioEncoderConfiguration)
+		profile.AudioEncoder = &c
+	}
+	if p.MetadataConfiguration.ConfigurationEntity != nil {
+		c := fromMetadata(&p.MetadataConfiguration)
+		profile.Metadata = &c
+	}
+	if e := &p.Extension; e.AudioOutputConfiguration.ConfigurationEntity != nil {
+		profile.AudioOutput = &AudioOutputConfig{
+			Config:      fromEntity(e.AudioOutputConfiguration.ConfigurationEntity),
+			OutputToken: string(e.AudioOutputConfiguration.OutputToken),
+			SendPrimacy: string(e.AudioOutputConfiguration.SendPrimacy),
+			OutputLevel: int(e.AudioOutputConfiguration.OutputLevel),
+		}
+	}
+	if e := &p.Extension; e.AudioDecoderConfiguration.ConfigurationEntity != nil {
+		c := fromEntity(e.AudioDecoderConfiguration.ConfigurationEntity)
+		profile.AudioDecoder = &c
+	}
+	if p.PTZConfiguration.ConfigurationEntity != nil {
+		profile.PTZConfigurationToken = string(p.PTZConfiguration.Token)
+	}
+	if p.VideoAnalyticsConfiguration.ConfigurationEntity != nil {
+		profile.AnalyticsConfigurationToken = string(p.VideoAnalyticsConfiguration.Token)
+	}
+	return profile
+}
+
+func fromEntity(e *media.ConfigurationEntity) Config {
+	return Config{Token: string(e.Token), Name: string(e.Name), UseCount: int(e.UseCount)}
+}
+
+func fromVideoSource(c *media.VideoSourceConfiguration) VideoSourceConfig {
+	return VideoSourceConfig{
+		Config:      fromEntity(c.ConfigurationEntity),
+		SourceToken: string(c.SourceToken),
+		Bounds:      Rectangle{int(c.Bounds.X), int(c.Bounds.Y), int(c.Bounds.Width), int(c.Bounds.Height)},
+	}
+}
+
+func fromVideoEncoder(c *media.VideoEncoderConfiguration) VideoEncoderConfig {
+	e := VideoEncoderConfig{
+		Config:              fromEntity(c.ConfigurationEntity),
+		Encoding:            string(c.Encoding),
+		Width:               int(c.Resolution.Width),
+		Height:              int(c.Resolution.Height),
+		Quality:             float64(c.Quality),
+		FrameRateLimit:      float64(c.RateControl.FrameRateLimit),
+		EncodingInterval:    int(c.RateControl.EncodingInterval),
+		BitrateLimit:        int(c.RateControl.BitrateLimit),
+		GuaranteedFrameRate: c.GuaranteedFrameRate,
+		Multicast:           fromMulticast(&c.Multicast),
+		SessionTimeout:      parseDuration(string(c.SessionTimeout)),
+	}
+	if encoding, ok := videoEncodings[c.Encoding]; ok {
+		e.Encoding = encoding
+	}
+	switch c.Encoding {
+	case media.VideoEncodingH264:
+		e.GovLength = int(c.H264.GovLength)
+		e.Profile = string(c.H264.H264Profile)
+	case media.VideoEncodingMPEG4:
+		e.GovLength = int(c.MPEG4.GovLength)
+		e.Profile = string(c.MPEG4.Mpeg4Profile)
+	}
+	return e
+}
+
+func fromAudioEncoder(c *media.AudioEncoderConfiguration) AudioEncoderConfig {
+	e := AudioEncoderConfig{
+		Config:         fromEntity(c.ConfigurationEntity),
+		Encoding:       string(c.Encoding),
+		Bitrate:        int(c.Bitrate),
+		SampleRate:     int(c.SampleRate),
+		Multicast:      fromMulticast(&c.Multicast),
+		SessionTimeout: parseDuration(string(c.SessionTimeout)),
+	}
+	if encoding, ok := audioEncodings[c.Encoding]; ok {
+		e.Encoding = encoding
+	}
+	return e
+}
+
+func fromMetadata(c *media.MetadataConfiguration) MetadataConfig {
+	return MetadataConfig{
+		Config:          fromEntity(c.ConfigurationEntity),
+		Analytics:       c.Analytics,
+		PTZStatus:       c.PTZStatus.Status,
+		PTZPosition:     c.PTZStatus.Position,
+		CompressionType: c.CompressionType,
+		GeoLocation:     c.GeoLocation,
+		Multicast:       fromMulticast(&c.Multicast),
+		SessionTimeout:  parseDuration(string(c.SessionTimeout)),
+	}
+}
+
+func fromMulticast(m *media.MulticastConfiguration) Multicast {
+	address := string(m.Address.IPv4Address)
+	if address == "" {
+		address = string(m.Address.IPv6Address)
+	}
+	return Multicast{Address: address, Port: int(m.Port), TTL: int(m.TTL), AutoStart: m.AutoStart}
+}
+
+func fromOSD(o *media.OSDConfiguration) OSD {
+	osd := OSD{
+		VideoSourceConfigurationToken: string(o.VideoSourceConfigurationToken.Value),
+		Type:                          string(o.Type),
+		Position:                      o.Position.Type,
+		X:                             float64(o.Position.Pos.X),
+		Y:                             float64(o.Position.Pos.Y),
+		TextType:                      o.TextString.Type,
+		PlainText:                     o.TextString.PlainText,
+		DateFormat:                    o.TextString.DateFormat,
+		TimeFormat:                    o.TextString.TimeFormat,
+		FontSize:                      int(o.TextString.FontSize),
+		ImagePath:                     string(o.Image.ImgPath),
+	}
+	if o.DeviceEntity != nil {
+		osd.Token = string(o.DeviceEntity.Token)
+	}
+	return osd
+}
+
+func fromMediaURI(u *media.MediaUri) *MediaURI {
+	return &MediaURI{
+		URI:                 strings.TrimSpace(string(u.Uri)),
+		InvalidAfterConnect: u.InvalidAfterConnect,
+		InvalidAfterReboot:  u.InvalidAfterReboot,
+		Timeout:             parseDuration(string(u.Timeout)),
+	}
+}
+
+func fromProfile2(p *media2.MediaProfile) Profile {
+	profile := Profile{Token: string(p.Token), Name: string(p.Name), Fixed: p.Fixed}
+	cs := &p.Configurations
+	if cs.VideoSource.ConfigurationEntity != nil {
+		c := fromVideoSource2(&cs.VideoSource)
+		profile.VideoSource = &c
+	}
+	if cs.VideoEncoder.ConfigurationEntity != nil {
+		c := fromVideoEncoder2(&cs.VideoEncoder)
+		profile.VideoEncoder = &c
+	}
+	if cs.AudioSource.ConfigurationEntity != nil {
+		c := AudioSourceConfig{
+			Config:      fromEntity2(cs.AudioSource.ConfigurationEntity),
+			SourceToken: string(cs.AudioSource.SourceToken),
+		}
+		profile.AudioSource = &c
+	}
+	if cs.AudioEncoder.ConfigurationEntity != nil {
+		c := fromAudioEncoder2(&cs.AudioEncoder)
+		profile.AudioEncoder = &c
+	}
+	if cs.Metadata.ConfigurationEntity != nil {
+		c := fromMetadata2(&cs.Metadata)
+		profile.Metadata = &c
+	}
+	if cs.AudioOutput.ConfigurationEntity != nil {
+		profile.AudioOutput = &AudioOutputConfig{
+			Config:      fromEntity2(cs.AudioOutput.ConfigurationEntity),
+			OutputToken: string(cs.AudioOutput.OutputToken),
+			SendPrimacy: string(cs.AudioOutput.SendPrimacy),
+			OutputLevel: int(cs.AudioOutput.OutputLevel),
+		}
+	}
+	if cs.AudioDecoder.ConfigurationEntity != nil {
+		c := fromEntity2(cs.AudioDecoder.ConfigurationEntity)
+		profile.AudioDecoder = &c
+	}
+	if cs.PTZ.ConfigurationEntity != nil {
+		profile.PTZConfigurationToken = string(cs.PTZ.Token)
+	}
+	if cs.Analytics.ConfigurationEntity != nil {
+		profile.AnalyticsConfigurationToken = string(cs.Analytics.Token)
+	}
+	return profile
+}
+
+func fromEntity2(e *media2.ConfigurationEntity) Config {
+	return Config{Token: string(e.Token), Name: string(e.Name), UseCount: int(e.UseCount)}
+}
+
+func fromVideoSource2(c *media2.VideoSourceConfiguration) VideoSourceConfig {
+	return VideoSourceConfig{
+		Config:      fromEntity2(c.ConfigurationEntity),
+		SourceToken: string(c.SourceToken),
+		Bounds:      Rectangle{int(c.Bounds.X), int(c.Bounds.Y), int(c.Bounds.Width), int(c.Bounds.Height)},
+	}
+}
+
+func fromVideoEncoder2(c *media2.VideoEncoder2Configuration) VideoEncoderConfig {
+	return VideoEncoderConfig{
+		Config:              fromEntity2(c.ConfigurationEntity),
+		Encoding:            c.Encoding,
+		Width:               int(c.Resolution.Width),
+		Height:              int(c.Resolution.Height),
+		Quality:             float64(c.Quality),
+		FrameRateLimit:      float64(c.RateControl.FrameRateLimit),
+		BitrateLimit:        int(c.RateControl.BitrateLimit),
+		ConstantBitRate:     c.RateControl.ConstantBitRate,
+		GovLength:           int(c.GovLength),
+		Profile:             c.Profile,
+		GuaranteedFrameRate: c.GuaranteedFrameRate,
+		Multicast:           fromMulticast2(&c.Multicast),
+	}
+}
+
+func fromAudioEncoder2(c *media2.AudioEncoder2Configuration) AudioEncoderConfig {
+	return AudioEncoderConfig{
+		Config:     fromEntity2(c.ConfigurationEntity),
+		Encoding:   c.Encoding,
+		Bitrate:    int(c.Bitrate),
+		SampleRate: int(c.SampleRate),
+		Multicast:  fromMulticast2(&c.Multicast),
+	}
+}
+
+func fromMetadata2(c *media2.MetadataConfiguration) MetadataConfig {
+	return MetadataConfig{
+		Config:          fromEntity2(c.ConfigurationEntity),
+		Analytics:       c.Analytics,
+		PTZStatus:       c.PTZStatus.Status,
+		PTZPosition:     c.PTZStatus.Position,
+		CompressionType: c.CompressionType,
+		GeoLocation:     c.GeoLocation,
+		Multicast:       fromMulticast2(&c.Multicast),
+		SessionTimeout:  parseDuration(string(c.SessionTimeout)),
+	}
+}
+
+func fromMulticast2(m *media2.MulticastConfiguration) Multicast {
+	address := string(m.Address.IPv4Address)
+	if address == "" {
+		address = string(m.Address.IPv6Address)
+	}
+	return Multicast{Address: address, Port: int(m.Port), TTL: int(m.TTL), AutoStart: m.AutoStart}
+}
+
+func fromOSD2(o *media2.OSDConfiguration) OSD {
+	osd := OSD{
+		VideoSourceConfigurationToken: string(o.VideoSourceConfigurationToken.Value),
+		Type:                          string(o.Type),
+		Position:                      o.Position.Type,
+		X:                             float64(o.Position.Pos.X),
+		Y:                             float64(o.Position.Pos.Y),
+		TextType:                      o.TextString.Type,
+		PlainText:                     o.TextString.PlainText,
+		DateFormat:                    o.TextString.DateFormat,
+		TimeFormat:                    o.TextString.TimeFormat,
+		FontSize:                      int(o.TextString.FontSize),
+		ImagePath:                     string(o.Image.ImgPath),
+	}
+	if o.DeviceEntity != nil {
+		osd.Token = string(o.DeviceEntity.Token)
+	}
+	return osd
+}
+
+// parseDuration parses xs:duration like PT1H30M or P1DT10S, zero if invalid.
+// Years and months are not supported.
+func parseDuration(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if !strings.HasPrefix(s, "P") {
+		return 0
+	}
+
+	var d time.Duration
+	inTime := false
+	number := ""
+	for _, r := range s[1:] {
+		switch {
+		case r == 'T':
+			inTime = true
+		case r >= '0' && r <= '9' || r == '.':
+			number += string(r)
+		default:
+			v, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0
+			}
+			number = ""
+
+			var unit time.Duration
+			switch {
+			case r == 'D' && !inTime:
+				unit = 24 * time.Hour
+			case r == 'H' && inTime:
+				unit = time.Hour
+			case r == 'M' && inTime:
+				unit = time.Minute
+			case r == 'S' && inTime:
+				unit = time.Second
+			default:
+				return 0
+			}
+			d += time.Duration(v * float64(unit))
+		}
+	}
+
+	if negative {
+		return -d
+	}
+	return d
+}
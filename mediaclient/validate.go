@@ -0,0 +1,235 @@
+package mediaclient
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Violation is the value of the configuration not supported by the encoder options
+type Violation struct {
+	// Field of VideoEncoderConfig, e.g. "FrameRateLimit"
+	Field string
+
+	Value     string
+	Supported string
+
+	// Nearest supported value
+	Snapped string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %s is not supported (supported %s), nearest is %s", v.Field, v.Value, v.Supported, v.Snapped)
+}
+
+// ValidationError is returned for the configuration out of the encoder options
+type ValidationError struct {
+	Violations []Violation
+
+	// Configuration with the nearest supported values
+	Snapped VideoEncoderConfig
+}
+
+func (e *ValidationError) Error() string {
+	s := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		s[i] = v.String()
+	}
+	return "Invalid video encoder configuration: " + strings.Join(s, "; ")
+}
+
+// ValidateVideoEncoderConfiguration checks the configuration against the options returned by
+// the device before it is set, the options are limited to the profile if the token is set.
+// The error is *ValidationError if any value is not supported.
+func (c *Client) ValidateVideoEncoderConfiguration(ctx context.Context, config *VideoEncoderConfig, profileToken string) error {
+	options, err := c.VideoEncoderOptions(ctx, config.Token, profileToken)
+	if err != nil {
+		return err
+	}
+	snapped, violations := ValidateVideoEncoder(config, options)
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations, Snapped: snapped}
+	}
+	return nil
+}
+
+// ValidateVideoEncoder checks the configuration against the options and returns the
+// configuration with the nearest supported values and the violations. Zero frame rate,
+// bitrate, GOV length and encoding interval are not limited and not checked.
+func ValidateVideoEncoder(config *VideoEncoderConfig, options []VideoEncoderOptions) (VideoEncoderConfig, []Violation) {
+	e := *config
+	var violations []Violation
+	violate := func(field, value, supported, snapped string) {
+		violations = append(violations, Violation{field, value, supported, snapped})
+	}
+
+	o := encodingOptions(options, e.Encoding)
+	if o == nil {
+		if len(options) == 0 {
+			return e, violations
+		}
+		o = &options[0]
+		encodings := make([]string, len(options))
+		for i := range options {
+			encodings[i] = options[i].Encoding
+		}
+		violate("Encoding", e.Encoding, strings.Join(encodings, ", "), o.Encoding)
+		e.Encoding = o.Encoding
+		e.Profile = ""
+	}
+
+	if len(o.Resolutions) > 0 {
+		nearest := o.Resolutions[0]
+		for _, r := range o.Resolutions {
+			if abs(r.Width-e.Width)+abs(r.Height-e.Height) < abs(nearest.Width-e.Width)+abs(nearest.Height-e.Height) {
+				nearest = r
+			}
+		}
+		if nearest.Width != e.Width || nearest.Height != e.Height {
+			resolutions := make([]string, len(o.Resolutions))
+			for i, r := range o.Resolutions {
+				resolutions[i] = formatResolution(r.Width, r.Height)
+			}
+			violate("Resolution", formatResolution(e.Width, e.Height), strings.Join(resolutions, ", "), formatResolution(nearest.Width, nearest.Height))
+			e.Width, e.Height = nearest.Width, nearest.Height
+		}
+	}
+
+	if e.FrameRateLimit != 0 {
+		if len(o.FrameRates) > 0 {
+			nearest := o.FrameRates[0]
+			for _, f := range o.FrameRates {
+				if absFloat(f-e.FrameRateLimit) < absFloat(nearest-e.FrameRateLimit) {
+					nearest = f
+				}
+			}
+			if absFloat(nearest-e.FrameRateLimit) > 0.01 {
+				rates := make([]string, len(o.FrameRates))
+				for i, f := range o.FrameRates {
+					rates[i] = formatFloat(f)
+				}
+				violate("FrameRateLimit", formatFloat(e.FrameRateLimit), strings.Join(rates, ", "), formatFloat(nearest))
+				e.FrameRateLimit = nearest
+			}
+		} else if o.FrameRateRange.Max != 0 {
+			if v := clampFloat(e.FrameRateLimit, o.FrameRateRange); v != e.FrameRateLimit {
+				violate("FrameRateLimit", formatFloat(e.FrameRateLimit), formatFloatRange(o.FrameRateRange), formatFloat(v))
+				e.FrameRateLimit = v
+			}
+		}
+	}
+
+	if o.QualityRange.Max != 0 {
+		if v := clampFloat(e.Quality, o.QualityRange); v != e.Quality {
+			violate("Quality", formatFloat(e.Quality), formatFloatRange(o.QualityRange), formatFloat(v))
+			e.Quality = v
+		}
+	}
+
+	ranges := []struct {
+		field string
+		value *int
+		r     IntRange
+	}{
+		{"EncodingInterval", &e.EncodingInterval, o.EncodingIntervalRange},
+		{"BitrateLimit", &e.BitrateLimit, o.BitrateRange},
+		{"GovLength", &e.GovLength, o.GovLengthRange},
+	}
+	for _, r := range ranges {
+		if *r.value == 0 || r.r.Max == 0 {
+			continue
+		}
+		if v := clamp(*r.value, r.r); v != *r.value {
+			violate(r.field, strconv.Itoa(*r.value), formatIntRange(r.r), strconv.Itoa(v))
+			*r.value = v
+		}
+	}
+
+	if len(o.Profiles) > 0 && e.Profile != "" && !contains(o.Profiles, e.Profile) {
+		// profiles are listed from the lowest, e.g. Baseline Main High
+		nearest := o.Profiles[len(o.Profiles)-1]
+		violate("Profile", e.Profile, strings.Join(o.Profiles, ", "), nearest)
+		e.Profile = nearest
+	}
+
+	if e.ConstantBitRate && !o.ConstantBitRateSupported {
+		violate("ConstantBitRate", "true", "false", "false")
+		e.ConstantBitRate = false
+	}
+	if e.GuaranteedFrameRate && !o.GuaranteedFrameRateSupported {
+		violate("GuaranteedFrameRate", "true", "false", "false")
+		e.GuaranteedFrameRate = false
+	}
+
+	return e, violations
+}
+
+// encodingOptions returns options of the encoding or nil
+func encodingOptions(options []VideoEncoderOptions, encoding string) *VideoEncoderOptions {
+	for i := range options {
+		if options[i].Encoding == encoding {
+			return &options[i]
+		}
+	}
+	return nil
+}
+
+func formatResolution(width, height int) string {
+	return strconv.Itoa(width) + "x" + strconv.Itoa(height)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatIntRange(r IntRange) string {
+	return strconv.Itoa(r.Min) + "-" + strconv.Itoa(r.Max)
+}
+
+func formatFloatRange(r FloatRange) string {
+	return formatFloat(r.Min) + "-" + formatFloat(r.Max)
+}
+
+func clamp(v int, r IntRange) int {
+	if v < r.Min {
+		return r.Min
+	}
+	if v > r.Max {
+		return r.Max
+	}
+	return v
+}
+
+func clampFloat(v float64, r FloatRange) float64 {
+	if v < r.Min {
+		return r.Min
+	}
+	if v > r.Max {
+		return r.Max
+	}
+	return v
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func absFloat(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package mediaclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateVideoEncoder(t *testing.T) {
+	// H264 options as returned by media2, JPEG as by the media service
+	options := []VideoEncoderOptions{
+		{
+			Encoding:       EncodingH264,
+			Resolutions:    []Resolution{{1920, 1080}, {1280, 720}, {640, 360}},
+			QualityRange:   FloatRange{1, 10},
+			FrameRates:     []float64{5, 12.5, 25},
+			GovLengthRange: IntRange{1, 100},
+			BitrateRange:   IntRange{64, 8192},
+			Profiles:       []string{"Baseline", "Main"},
+		},
+		{
+			Encoding:                     EncodingJPEG,
+			Resolutions:                  []Resolution{{640, 480}},
+			QualityRange:                 FloatRange{1, 10},
+			FrameRateRange:               FloatRange{1, 30},
+			EncodingIntervalRange:        IntRange{1, 10},
+			BitrateRange:                 IntRange{64, 8192},
+			ConstantBitRateSupported:     true,
+			GuaranteedFrameRateSupported: true,
+		},
+	}
+
+	valid := VideoEncoderConfig{
+		Config:         Config{Token: "encoder1"},
+		Encoding:       EncodingH264,
+		Width:          1280,
+		Height:         720,
+		Quality:        5,
+		FrameRateLimit: 25,
+		BitrateLimit:   4096,
+		GovLength:      50,
+		Profile:        "Main",
+	}
+	with := func(modify func(e *VideoEncoderConfig)) VideoEncoderConfig {
+		e := valid
+		modify(&e)
+		return e
+	}
+	jpeg := func(e *VideoEncoderConfig) {
+		e.Encoding, e.Width, e.Height, e.GovLength, e.Profile = EncodingJPEG, 640, 480, 0, ""
+	}
+
+	tests := []struct {
+		name       string
+		config     VideoEncoderConfig
+		options    []VideoEncoderOptions
+		snapped    VideoEncoderConfig
+		violations []Violation
+	}{
+		{
+			name:    "valid",
+			config:  valid,
+			options: options,
+			snapped: valid,
+		},
+		{
+			name:       "nearest resolution",
+			config:     with(func(e *VideoEncoderConfig) { e.Width, e.Height = 1024, 600 }),
+			options:    options,
+			snapped:    valid,
+			violations: []Violation{{"Resolution", "1024x600", "1920x1080, 1280x720, 640x360", "1280x720"}},
+		},
+		{
+			name:       "frame rate list",
+			config:     with(func(e *VideoEncoderConfig) { e.FrameRateLimit = 10 }),
+			options:    options,
+			snapped:    with(func(e *VideoEncoderConfig) { e.FrameRateLimit = 12.5 }),
+			violations: []Violation{{"FrameRateLimit", "10", "5, 12.5, 25", "12.5"}},
+		},
+		{
+			name:       "frame rate range",
+			config:     with(func(e *VideoEncoderConfig) { jpeg(e); e.FrameRateLimit = 60 }),
+			options:    options,
+			snapped:    with(func(e *VideoEncoderConfig) { jpeg(e); e.FrameRateLimit = 30 }),
+			violations: []Violation{{"FrameRateLimit", "60", "1-30", "30"}},
+		},
+		{
+			name: "zero not limited",
+			config: with(func(e *VideoEncoderConfig) {
+				jpeg(e)
+				e.FrameRateLimit, e.BitrateLimit, e.EncodingInterval = 0, 0, 0
+			}),
+			options: options,
+			snapped: with(func(e *VideoEncoderConfig) {
+				jpeg(e)
+				e.FrameRateLimit, e.BitrateLimit, e.EncodingInterval = 0, 0, 0
+			}),
+		},
+		{
+			name: "clamped",
+			config: with(func(e *VideoEncoderConfig) {
+				e.Quality, e.BitrateLimit, e.GovLength = 0, 10000, 250
+			}),
+			options: options,
+			snapped: with(func(e *VideoEncoderConfig) {
+				e.Quality, e.BitrateLimit, e.GovLength = 1, 8192, 100
+			}),
+			violations: []Violation{
+				{"Quality", "0", "1-10", "1"},
+				{"BitrateLimit", "10000", "64-8192", "8192"},
+				{"GovLength", "250", "1-100", "100"},
+			},
+		},
+		{
+			name: "encoding interval",
+			config: with(func(e *VideoEncoderConfig) {
+				jpeg(e)
+				e.EncodingInterval = 20
+			}),
+			options: options,
+			snapped: with(func(e *VideoEncoderConfig) {
+				jpeg(e)
+				e.EncodingInterval = 10
+			}),
+			violations: []Violation{{"EncodingInterval", "20", "1-10", "10"}},
+		},
+		{
+			name: "missing encoding",
+			config: with(func(e *VideoEncoderConfig) {
+				e.Encoding, e.Width, e.Height, e.Profile = EncodingH265, 3840, 2160, "Main10"
+			}),
+			options: options,
+			snapped: with(func(e *VideoEncoderConfig) {
+				e.Width, e.Height, e.Profile = 1920, 1080, ""
+			}),
+			violations: []Violation{
+				{"Encoding", "H265", "H264, JPEG", "H264"},
+				{"Resolution", "3840x2160", "1920x1080, 1280x720, 640x360", "1920x1080"},
+			},
+		},
+		{
+			name: "profile and rate control",
+			config: with(func(e *VideoEncoderConfig) {
+				e.Profile, e.ConstantBitRate, e.GuaranteedFrameRate = "High", true, true
+			}),
+			options: options,
+			snapped: valid,
+			violations: []Violation{
+				{"Profile", "High", "Baseline, Main", "Main"},
+				{"ConstantBitRate", "true", "false", "false"},
+				{"GuaranteedFrameRate", "true", "false", "false"},
+			},
+		},
+		{
+			name: "rate control supported",
+			config: with(func(e *VideoEncoderConfig) {
+				jpeg(e)
+				e.ConstantBitRate, e.GuaranteedFrameRate = true, true
+			}),
+			options: options,
+			snapped: with(func(e *VideoEncoderConfig) {
+				jpeg(e)
+				e.ConstantBitRate, e.GuaranteedFrameRate = true, true
+			}),
+		},
+		{
+			name:    "no options",
+			config:  with(func(e *VideoEncoderConfig) { e.Encoding = EncodingH265 }),
+			snapped: with(func(e *VideoEncoderConfig) { e.Encoding = EncodingH265 }),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := test.config
+			snapped, violations := ValidateVideoEncoder(&config, test.options)
+			if !reflect.DeepEqual(snapped, test.snapped) {
+				t.Errorf("snapped %+v, expected %+v", snapped, test.snapped)
+			}
+			if !reflect.DeepEqual(violations, test.violations) {
+				t.Errorf("violations %v, expected %v", violations, test.violations)
+			}
+			if !reflect.DeepEqual(config, test.config) {
+				t.Errorf("configuration modified %+v", config)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package metadata
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/videonext/onvif/profiles/event"
+	"github.com/videonext/onvif/soap"
+)
+
+// MetadataStream contains the frames, PTZ states and events of the metadata documents
+type MetadataStream struct {
+	Frames []Frame
+	PTZ    []PTZStatus
+	Events []event.NotificationMessage
+}
+
+// Item is the frame, PTZ status or event, only one of the fields is set
+type Item struct {
+	Frame *Frame
+	PTZ   *PTZStatus
+	Event *event.NotificationMessage
+}
+
+// Parser reads items of tt:MetadataStream documents one by one, e.g. from
+// the file of the recorded metadata. Several documents may follow each other.
+type Parser struct {
+	d *soap.Decoder
+}
+
+// NewParser creates Parser of the XML read from r
+func NewParser(r io.Reader) *Parser {
+	return &Parser{d: soap.NewDecoder(r)}
+}
+
+// Next returns the next item, io.EOF at the end of the input. Elements other
+// than VideoAnalytics Frame, PTZ PTZStatus and Event NotificationMessage are skipped.
+func (p *Parser) Next() (*Item, error) {
+	for {
+		token, err := p.d.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(soap.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "Frame":
+			item := &Item{Frame: &Frame{}}
+			return item, p.d.DecodeElement(item.Frame, &start)
+		case "PTZStatus":
+			item := &Item{PTZ: &PTZStatus{}}
+			return item, p.d.DecodeElement(item.PTZ, &start)
+		case "NotificationMessage":
+			item := &Item{Event: &event.NotificationMessage{}}
+			return item, p.d.DecodeElement(item.Event, &start)
+		case "MetadataStream", "VideoAnalytics", "PTZ", "Event":
+		default:
+			if err := p.d.Skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Parse parses metadata document, e.g. of the RTP metadata track
+func Parse(data []byte) (*MetadataStream, error) {
+	s := &MetadataStream{}
+	p := NewParser(bytes.NewReader(data))
+	for {
+		item, err := p.Next()
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case item.Frame != nil:
+			s.Frames = append(s.Frames, *item.Frame)
+		case item.PTZ != nil:
+			s.PTZ = append(s.PTZ, *item.PTZ)
+		case item.Event != nil:
+			s.Events = append(s.Events, *item.Event)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package metadata
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// testMetadataStream contains an item of every kind, the vendor statistics
+// are skipped together with the frame counter inside
+const testMetadataStream = `<?xml version="1.0" encoding="UTF-8"?>
+<tt:MetadataStream xmlns:tt="http://www.onvif.org/ver10/schema" xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2"
+ xmlns:tns1="http://www.onvif.org/ver10/topics" xmlns:acme="http://www.acme.com/metadata">
+<tt:VideoAnalytics>
+<tt:Frame UtcTime="2024-01-01T00:00:00.100Z" Source="VideoSource_1">
+<tt:Object ObjectId="12"><tt:Appearance>
+<tt:Shape><tt:BoundingBox left="-0.5" top="0.5" right="0.1" bottom="-0.2"/><tt:CenterOfGravity x="-0.2" y="0.15"/></tt:Shape>
+<tt:Class><tt:Type Likelihood="0.9">Human</tt:Type></tt:Class>
+</tt:Appearance></tt:Object>
+<tt:Object ObjectId="13"/>
+</tt:Frame>
+</tt:VideoAnalytics>
+<acme:Statistics><acme:Frame Count="25"/><acme:PTZStatus/></acme:Statistics>
+<tt:PTZ>
+<tt:PTZStatus>
+<tt:Position><tt:PanTilt x="0.25" y="-0.5"/><tt:Zoom x="0.1"/></tt:Position>
+<tt:MoveStatus><tt:PanTilt>MOVING</tt:PanTilt><tt:Zoom>IDLE</tt:Zoom></tt:MoveStatus>
+<tt:UtcTime>2024-01-01T00:00:00.200Z</tt:UtcTime>
+</tt:PTZStatus>
+</tt:PTZ>
+<tt:Event>
+<wsnt:NotificationMessage>
+<wsnt:Topic Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet">tns1:VideoSource/MotionAlarm</wsnt:Topic>
+<wsnt:Message><tt:Message UtcTime="2024-01-01T00:00:00.300Z" PropertyOperation="Changed">
+<tt:Source><tt:SimpleItem Name="Source" Value="VideoSource_1"/></tt:Source>
+<tt:Data><tt:SimpleItem Name="State" Value="true"/></tt:Data>
+</tt:Message></wsnt:Message>
+</wsnt:NotificationMessage>
+</tt:Event>
+<tt:Extension><acme:Heartbeat/></tt:Extension>
+</tt:MetadataStream>`
+
+func TestParser(t *testing.T) {
+	// documents of the recording follow each other
+	p := NewParser(strings.NewReader(testMetadataStream + "\n" + testMetadataStream))
+
+	var kinds []string
+	for {
+		item, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case item.Frame != nil && item.PTZ == nil && item.Event == nil:
+			kinds = append(kinds, "frame")
+		case item.PTZ != nil && item.Frame == nil && item.Event == nil:
+			kinds = append(kinds, "ptz")
+		case item.Event != nil && item.Frame == nil && item.PTZ == nil:
+			kinds = append(kinds, "event")
+		default:
+			t.Fatalf("item %+v", item)
+		}
+	}
+
+	expected := "frame ptz event frame ptz event"
+	if s := strings.Join(kinds, " "); s != expected {
+		t.Errorf("items %q, expected %q", s, expected)
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(testMetadataStream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Frames) != 1 || len(s.PTZ) != 1 || len(s.Events) != 1 {
+		t.Fatalf("%d frames, %d PTZ states, %d events", len(s.Frames), len(s.PTZ), len(s.Events))
+	}
+
+	f := &s.Frames[0]
+	if tm, err := f.Time(); err != nil || tm.Nanosecond() != 100000000 || f.Source != "VideoSource_1" {
+		t.Errorf("frame time %v %v source %q", tm, err, f.Source)
+	}
+	if len(f.Object) != 2 {
+		t.Fatalf("%d objects", len(f.Object))
+	}
+	o := &f.Object[0]
+	if box := o.BoundingBox(); o.ObjectId != 12 || box == nil || *box != (Rectangle{Bottom: -0.2, Top: 0.5, Right: 0.1, Left: -0.5}) {
+		t.Errorf("object %d bounding box %+v", o.ObjectId, box)
+	}
+	if classes := o.Classes(); len(classes) != 1 || classes[0] != (Class{Type: ClassHuman, Likelihood: 0.9}) {
+		t.Errorf("classes %+v", classes)
+	}
+	if o := &f.Object[1]; o.ObjectId != 13 || o.BoundingBox() != nil || o.Classes() != nil {
+		t.Errorf("object without appearance %+v", o)
+	}
+
+	ptz := &s.PTZ[0]
+	if ptz.Position == nil || ptz.Position.PanTilt == nil || ptz.Position.Zoom == nil ||
+		ptz.Position.PanTilt.X != 0.25 || ptz.Position.PanTilt.Y != -0.5 || ptz.Position.Zoom.X != 0.1 {
+		t.Errorf("PTZ position %+v", ptz.Position)
+	}
+	if ptz.MoveStatus == nil || ptz.MoveStatus.PanTilt != MoveStatusMoving || ptz.MoveStatus.Zoom != MoveStatusIdle {
+		t.Errorf("PTZ move status %+v", ptz.MoveStatus)
+	}
+	if tm, err := ptz.Time(); err != nil || tm.Nanosecond() != 200000000 {
+		t.Errorf("PTZ time %v %v", tm, err)
+	}
+
+	e := &s.Events[0]
+	if topic := e.Topic.Canonical(); topic != "tns1:VideoSource/MotionAlarm" {
+		t.Errorf("topic %q", topic)
+	}
+	m := &e.Message.Message
+	source, _ := m.Source.Get("Source")
+	state, _ := m.Data.Get("State")
+	if m.PropertyOperation != "Changed" || source != "VideoSource_1" || state != "true" {
+		t.Errorf("message %+v", m)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	truncated := testMetadataStream[:strings.Index(testMetadataStream, "<tt:MoveStatus>")+20]
+	if _, err := Parse([]byte(truncated)); err == nil {
+		t.Error("no error for the truncated document")
+	}
+}
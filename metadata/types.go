@@ -0,0 +1,331 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/videonext/onvif/profiles/event"
+)
+
+// Class types of ClassCandidate
+const (
+	ClassAnimal  = "Animal"
+	ClassFace    = "Face"
+	ClassHuman   = "Human"
+	ClassVehicle = "Vehical"
+	ClassOther   = "Other"
+)
+
+// PTZ move status
+const (
+	MoveStatusIdle    = "IDLE"
+	MoveStatusMoving  = "MOVING"
+	MoveStatusUnknown = "UNKNOWN"
+)
+
+// Frame type
+type Frame struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver10/schema Frame"`
+
+	UtcTime string `xml:"UtcTime,attr"`
+
+	// Colorimetric coordinate system of the colors
+	Colorimetric string `xml:"Colorimetric,attr,omitempty"`
+
+	// Video source or analytics module token
+	Source string `xml:"Source,attr,omitempty"`
+
+	PTZStatus *PTZStatus `xml:"http://www.onvif.org/ver10/schema PTZStatus,omitempty"`
+
+	Transformation *Transformation `xml:"http://www.onvif.org/ver10/schema Transformation,omitempty"`
+
+	Object []Object `xml:"http://www.onvif.org/ver10/schema Object,omitempty"`
+
+	ObjectTree *ObjectTree `xml:"http://www.onvif.org/ver10/schema ObjectTree,omitempty"`
+
+	// Base64 encoded image of the scene
+	SceneImage string `xml:"http://www.onvif.org/ver10/schema SceneImage,omitempty"`
+}
+
+// Object type
+type Object struct {
+	ObjectId int `xml:"ObjectId,attr"`
+
+	// Object the object is part of, e.g. a face of the human
+	Parent int `xml:"Parent,attr,omitempty"`
+
+	ParentRelation string `xml:"ParentRelation,attr,omitempty"`
+
+	Appearance *Appearance `xml:"http://www.onvif.org/ver10/schema Appearance,omitempty"`
+
+	Behaviour *Behaviour `xml:"http://www.onvif.org/ver10/schema Behaviour,omitempty"`
+}
+
+// Appearance type
+type Appearance struct {
+	Transformation *Transformation `xml:"http://www.onvif.org/ver10/schema Transformation,omitempty"`
+
+	Shape *ShapeDescriptor `xml:"http://www.onvif.org/ver10/schema Shape,omitempty"`
+
+	Color *ColorDescriptor `xml:"http://www.onvif.org/ver10/schema Color,omitempty"`
+
+	Class *ClassDescriptor `xml:"http://www.onvif.org/ver10/schema Class,omitempty"`
+
+	GeoLocation *GeoLocation `xml:"http://www.onvif.org/ver10/schema GeoLocation,omitempty"`
+
+	VehicleInfo []VehicleInfo `xml:"http://www.onvif.org/ver10/schema VehicleInfo,omitempty"`
+
+	LicensePlateInfo *LicensePlateInfo `xml:"http://www.onvif.org/ver10/schema LicensePlateInfo,omitempty"`
+
+	// URI of the object image
+	ImageRef string `xml:"http://www.onvif.org/ver10/schema ImageRef,omitempty"`
+
+	// Base64 encoded object image
+	Image string `xml:"http://www.onvif.org/ver10/schema Image,omitempty"`
+}
+
+// ShapeDescriptor type
+type ShapeDescriptor struct {
+	BoundingBox Rectangle `xml:"http://www.onvif.org/ver10/schema BoundingBox"`
+
+	CenterOfGravity Vector `xml:"http://www.onvif.org/ver10/schema CenterOfGravity"`
+
+	Polygon []Polygon `xml:"http://www.onvif.org/ver10/schema Polygon,omitempty"`
+}
+
+// Rectangle type
+type Rectangle struct {
+	Bottom float64 `xml:"bottom,attr"`
+	Top    float64 `xml:"top,attr"`
+	Right  float64 `xml:"right,attr"`
+	Left   float64 `xml:"left,attr"`
+}
+
+// Vector type
+type Vector struct {
+	X float64 `xml:"x,attr"`
+	Y float64 `xml:"y,attr"`
+}
+
+// Polygon type
+type Polygon struct {
+	Point []Vector `xml:"http://www.onvif.org/ver10/schema Point"`
+}
+
+// Transformation type
+type Transformation struct {
+	Translate *Vector `xml:"http://www.onvif.org/ver10/schema Translate,omitempty"`
+
+	Scale *Vector `xml:"http://www.onvif.org/ver10/schema Scale,omitempty"`
+}
+
+// ColorDescriptor type
+type ColorDescriptor struct {
+	ColorCluster []ColorCluster `xml:"http://www.onvif.org/ver10/schema ColorCluster,omitempty"`
+}
+
+// ColorCluster type
+type ColorCluster struct {
+	Color Color `xml:"http://www.onvif.org/ver10/schema Color"`
+
+	Weight float64 `xml:"http://www.onvif.org/ver10/schema Weight,omitempty"`
+}
+
+// Color type
+type Color struct {
+	X float64 `xml:"X,attr"`
+	Y float64 `xml:"Y,attr"`
+	Z float64 `xml:"Z,attr"`
+
+	Colorspace string `xml:"Colorspace,attr,omitempty"`
+}
+
+// ClassDescriptor type. Devices use ClassCandidate of ONVIF 1.0 or Type of the
+// later versions, Classes returns both.
+type ClassDescriptor struct {
+	ClassCandidate []ClassCandidate `xml:"http://www.onvif.org/ver10/schema ClassCandidate,omitempty"`
+
+	Extension *struct {
+		OtherTypes []ClassCandidate `xml:"http://www.onvif.org/ver10/schema OtherTypes,omitempty"`
+	} `xml:"http://www.onvif.org/ver10/schema Extension,omitempty"`
+
+	Type []StringLikelihood `xml:"http://www.onvif.org/ver10/schema Type,omitempty"`
+}
+
+// ClassCandidate type
+type ClassCandidate struct {
+	Type string `xml:"http://www.onvif.org/ver10/schema Type"`
+
+	Likelihood float64 `xml:"http://www.onvif.org/ver10/schema Likelihood"`
+}
+
+// StringLikelihood type
+type StringLikelihood struct {
+	Value string `xml:",chardata"`
+
+	Likelihood float64 `xml:"Likelihood,attr,omitempty"`
+}
+
+// GeoLocation type
+type GeoLocation struct {
+	Lon       float64 `xml:"lon,attr"`
+	Lat       float64 `xml:"lat,attr"`
+	Elevation float64 `xml:"elevation,attr,omitempty"`
+}
+
+// VehicleInfo type
+type VehicleInfo struct {
+	Type StringLikelihood `xml:"http://www.onvif.org/ver10/schema Type"`
+
+	Brand *StringLikelihood `xml:"http://www.onvif.org/ver10/schema Brand,omitempty"`
+
+	Model *StringLikelihood `xml:"http://www.onvif.org/ver10/schema Model,omitempty"`
+}
+
+// LicensePlateInfo type
+type LicensePlateInfo struct {
+	PlateNumber StringLikelihood `xml:"http://www.onvif.org/ver10/schema PlateNumber"`
+
+	PlateType *StringLikelihood `xml:"http://www.onvif.org/ver10/schema PlateType,omitempty"`
+
+	CountryCode *StringLikelihood `xml:"http://www.onvif.org/ver10/schema CountryCode,omitempty"`
+
+	IssuingEntity *StringLikelihood `xml:"http://www.onvif.org/ver10/schema IssuingEntity,omitempty"`
+}
+
+// Behaviour type
+type Behaviour struct {
+	Removed *struct{} `xml:"http://www.onvif.org/ver10/schema Removed,omitempty"`
+
+	Idle *struct{} `xml:"http://www.onvif.org/ver10/schema Idle,omitempty"`
+
+	Speed float64 `xml:"http://www.onvif.org/ver10/schema Speed,omitempty"`
+}
+
+// ObjectTree type
+type ObjectTree struct {
+	Rename []Rename `xml:"http://www.onvif.org/ver10/schema Rename,omitempty"`
+
+	Split []Split `xml:"http://www.onvif.org/ver10/schema Split,omitempty"`
+
+	Merge []Merge `xml:"http://www.onvif.org/ver10/schema Merge,omitempty"`
+
+	Delete []ObjectId `xml:"http://www.onvif.org/ver10/schema Delete,omitempty"`
+}
+
+// ObjectId type
+type ObjectId struct {
+	ObjectId int `xml:"ObjectId,attr"`
+}
+
+// Rename type
+type Rename struct {
+	From ObjectId `xml:"http://www.onvif.org/ver10/schema from"`
+
+	To ObjectId `xml:"http://www.onvif.org/ver10/schema to"`
+}
+
+// Split type
+type Split struct {
+	From ObjectId `xml:"http://www.onvif.org/ver10/schema from"`
+
+	To []ObjectId `xml:"http://www.onvif.org/ver10/schema to"`
+}
+
+// Merge type
+type Merge struct {
+	From []ObjectId `xml:"http://www.onvif.org/ver10/schema from"`
+
+	To ObjectId `xml:"http://www.onvif.org/ver10/schema to"`
+}
+
+// PTZStatus type
+type PTZStatus struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver10/schema PTZStatus"`
+
+	Position *PTZVector `xml:"http://www.onvif.org/ver10/schema Position,omitempty"`
+
+	MoveStatus *PTZMoveStatus `xml:"http://www.onvif.org/ver10/schema MoveStatus,omitempty"`
+
+	Error string `xml:"http://www.onvif.org/ver10/schema Error,omitempty"`
+
+	UtcTime string `xml:"http://www.onvif.org/ver10/schema UtcTime,omitempty"`
+}
+
+// PTZVector type
+type PTZVector struct {
+	PanTilt *Vector2D `xml:"http://www.onvif.org/ver10/schema PanTilt,omitempty"`
+
+	Zoom *Vector1D `xml:"http://www.onvif.org/ver10/schema Zoom,omitempty"`
+}
+
+// Vector2D type
+type Vector2D struct {
+	X float64 `xml:"x,attr"`
+	Y float64 `xml:"y,attr"`
+
+	Space string `xml:"space,attr,omitempty"`
+}
+
+// Vector1D type
+type Vector1D struct {
+	X float64 `xml:"x,attr"`
+
+	Space string `xml:"space,attr,omitempty"`
+}
+
+// PTZMoveStatus type
+type PTZMoveStatus struct {
+	PanTilt string `xml:"http://www.onvif.org/ver10/schema PanTilt,omitempty"`
+
+	Zoom string `xml:"http://www.onvif.org/ver10/schema Zoom,omitempty"`
+}
+
+// Class is the object class with likelihood
+type Class struct {
+	Type       string
+	Likelihood float64
+}
+
+// Time parses UtcTime of the frame
+func (f *Frame) Time() (time.Time, error) {
+	return event.ParseDateTime(f.UtcTime)
+}
+
+// Time parses UtcTime of the PTZ status
+func (s *PTZStatus) Time() (time.Time, error) {
+	return event.ParseDateTime(s.UtcTime)
+}
+
+// Classes returns classes of the object, nil if not classified
+func (o *Object) Classes() []Class {
+	if o.Appearance == nil || o.Appearance.Class == nil {
+		return nil
+	}
+	return o.Appearance.Class.Classes()
+}
+
+// BoundingBox returns the bounding box of the object or nil
+func (o *Object) BoundingBox() *Rectangle {
+	if o.Appearance == nil || o.Appearance.Shape == nil {
+		return nil
+	}
+	return &o.Appearance.Shape.BoundingBox
+}
+
+// Classes returns classes of the class candidates and types
+func (c *ClassDescriptor) Classes() []Class {
+	var classes []Class
+	for _, cc := range c.ClassCandidate {
+		classes = append(classes, Class{Type: cc.Type, Likelihood: cc.Likelihood})
+	}
+	if c.Extension != nil {
+		for _, cc := range c.Extension.OtherTypes {
+			classes = append(classes, Class{Type: cc.Type, Likelihood: cc.Likelihood})
+		}
+	}
+	for _, t := range c.Type {
+		classes = append(classes, Class{Type: t.Value, Likelihood: t.Likelihood})
+	}
+	return classes
+}
@@ -0,0 +1,89 @@
+package event
+
+import (
+	"encoding/xml"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/videonext/onvif/soap"
+)
+
+// NsAddressing is the WS-Addressing 1.0 namespace used by the event service
+const NsAddressing = "http://www.w3.org/2005/08/addressing"
+
+// WS-Addressing actions of the subscription manager requests
+const (
+	ActionPullMessages            = "http://www.onvif.org/ver10/events/wsdl/PullPointSubscription/PullMessagesRequest"
+	ActionSeek                    = "http://www.onvif.org/ver10/events/wsdl/PullPointSubscription/SeekRequest"
+	ActionSetSynchronizationPoint = "http://www.onvif.org/ver10/events/wsdl/PullPointSubscription/SetSynchronizationPointRequest"
+	ActionRenew                   = "http://docs.oasis-open.org/wsn/bw-2/SubscriptionManager/RenewRequest"
+	ActionUnsubscribe             = "http://docs.oasis-open.org/wsn/bw-2/SubscriptionManager/UnsubscribeRequest"
+)
+
+// ReferenceParameter is a reference parameter of the endpoint reference.
+// Devices identify subscriptions by them, so they are echoed as headers
+// in every request sent to the subscription manager.
+type ReferenceParameter struct {
+	XMLName soap.Name
+
+	Attr []soap.Attr `xml:",any,attr"`
+
+	Value string `xml:",chardata"`
+}
+
+// MarshalXML implements xml.Marshaler
+func (p ReferenceParameter) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	return p.marshal(e, false)
+}
+
+func (p ReferenceParameter) marshal(e *xml.Encoder, header bool) error {
+	start := xml.StartElement{Name: xml.Name{Space: p.XMLName.Space, Local: p.XMLName.Local}}
+	for _, a := range p.Attr {
+		// Namespace declarations are restored by the encoder from the names
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Space: a.Name.Space, Local: a.Name.Local}, Value: a.Value})
+	}
+	if header {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Space: NsAddressing, Local: "IsReferenceParameter"}, Value: "true"})
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeToken(xml.CharData(p.Value)); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// referenceParameterHeader is the reference parameter sent as SOAP header
+type referenceParameterHeader ReferenceParameter
+
+// MarshalXML implements xml.Marshaler
+func (h referenceParameterHeader) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	return ReferenceParameter(h).marshal(e, true)
+}
+
+// addressingHeader is the WS-Addressing 1.0 message header, like To or Action
+type addressingHeader struct {
+	XMLName xml.Name
+
+	MustUnderstand string `xml:"http://www.w3.org/2003/05/soap-envelope mustUnderstand,attr,omitempty"`
+
+	Value string `xml:",chardata"`
+}
+
+// addressingHeaders returns WS-Addressing headers of the request
+// sent to the endpoint, including its reference parameters
+func addressingHeaders(action string, endpoint *EndpointReferenceType) []interface{} {
+	headers := []interface{}{
+		&addressingHeader{XMLName: xml.Name{Space: NsAddressing, Local: "MessageID"}, Value: "urn:uuid:" + uuid.NewV4().String()},
+		&addressingHeader{XMLName: xml.Name{Space: NsAddressing, Local: "To"}, MustUnderstand: "true", Value: string(endpoint.Address.Value)},
+		&addressingHeader{XMLName: xml.Name{Space: NsAddressing, Local: "Action"}, MustUnderstand: "true", Value: action},
+	}
+	for _, p := range endpoint.ReferenceParameters.Parameter {
+		headers = append(headers, referenceParameterHeader(p))
+	}
+	return headers
+}
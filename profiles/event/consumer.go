@@ -0,0 +1,394 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/videonext/onvif/soap"
+)
+
+// Query parameter of the consumer address identifying the subscription
+const consumerSubscriptionParam = "subscription"
+
+var errSubscriptionClosed = errors.New("Subscription is unsubscribed")
+
+// Notification is the notification pushed by the device
+type Notification struct {
+	// Subscription the notification was delivered for
+	Subscription *PushSubscription
+
+	Message NotificationMessage
+}
+
+// Consumer is the NotificationConsumer endpoint receiving Notify messages pushed
+// by devices. It must be served by an HTTP server at the address given to NewConsumer,
+// the address must be reachable by the devices.
+type Consumer struct {
+	address string
+	opts    []SubscriberOption
+
+	notifications chan Notification
+
+	mu            sync.Mutex
+	subscriptions map[string]*PushSubscription
+}
+
+// PushSubscription is the subscription created by Consumer.Subscribe.
+// It is renewed before termination and recreated if the device lost it.
+type PushSubscription struct {
+	id       string
+	xaddr    string
+	consumer *Consumer
+	opts     subscriberOptions
+
+	mu     sync.Mutex
+	sub    *subscription
+	status SubscriberStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewConsumer creates Consumer instance. The address is the consumer URL as seen
+// by devices. Options are the defaults of all subscriptions, WithBufferSize sets
+// the capacity of the notifications channel.
+func NewConsumer(address string, opt ...SubscriberOption) *Consumer {
+	opts := defaultSubscriberOptions
+	for _, o := range opt {
+		o(&opts)
+	}
+	return &Consumer{
+		address:       address,
+		opts:          opt,
+		notifications: make(chan Notification, opts.bufferSize),
+		subscriptions: map[string]*PushSubscription{},
+	}
+}
+
+// Notifications returns the channel of received notifications of all subscriptions
+func (c *Consumer) Notifications() <-chan Notification {
+	return c.notifications
+}
+
+// Subscriptions returns active subscriptions
+func (c *Consumer) Subscriptions() []*PushSubscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	subscriptions := make([]*PushSubscription, 0, len(c.subscriptions))
+	for _, s := range c.subscriptions {
+		subscriptions = append(subscriptions, s)
+	}
+	return subscriptions
+}
+
+// Subscribe subscribes to events of the device. The xaddr is the event service address,
+// options override the consumer defaults.
+func (c *Consumer) Subscribe(ctx context.Context, xaddr string, opt ...SubscriberOption) (*PushSubscription, error) {
+	opts := defaultSubscriberOptions
+	for _, o := range append(append([]SubscriberOption{}, c.opts...), opt...) {
+		o(&opts)
+	}
+
+	s := &PushSubscription{
+		id:       uuid.NewV4().String(),
+		xaddr:    xaddr,
+		consumer: c,
+		opts:     opts,
+		status:   SubscriberStatus{State: SubscriberStateSubscribing},
+	}
+
+	// Registered before subscribing, devices may notify immediately
+	c.mu.Lock()
+	c.subscriptions[s.id] = s
+	c.mu.Unlock()
+
+	if err := s.subscribe(ctx); err != nil {
+		c.remove(s)
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.run(runCtx, s.done)
+
+	return s, nil
+}
+
+// Close unsubscribes all subscriptions
+func (c *Consumer) Close() error {
+	var err error
+	for _, s := range c.Subscriptions() {
+		if e := s.Unsubscribe(context.Background()); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// ServeHTTP receives Notify messages
+func (c *Consumer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST expected", http.StatusMethodNotAllowed)
+		return
+	}
+
+	notify := &Notify{}
+	envelope := soap.SOAPEnvelope{Body: soap.SOAPBody{Content: notify}}
+	if err := soap.NewDecoder(r.Body).Decode(&envelope); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s := c.lookup(r.URL.Query().Get(consumerSubscriptionParam), notify)
+	if s == nil {
+		http.Error(w, "Unknown subscription", http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	s.status.LastMessage = time.Now()
+	s.status.Messages += uint64(len(notify.NotificationMessage))
+	s.mu.Unlock()
+
+	for _, m := range notify.NotificationMessage {
+		if s.opts.deduplicator != nil && s.opts.deduplicator.Seen(&m) {
+			continue
+		}
+		select {
+		case c.notifications <- Notification{Subscription: s, Message: m}:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	// Notify is one-way operation
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// lookup finds subscription by the consumer address parameter,
+// or by the subscription reference of the messages
+func (c *Consumer) lookup(id string, notify *Notify) *PushSubscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.subscriptions[id]; ok {
+		return s
+	}
+
+	for _, m := range notify.NotificationMessage {
+		address := string(m.SubscriptionReference.Address.Value)
+		if address == "" {
+			continue
+		}
+		for _, s := range c.subscriptions {
+			if s.SubscriptionAddress() == address {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Consumer) remove(s *PushSubscription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.subscriptions, s.id)
+}
+
+// consumerAddress returns the consumer address of the subscription
+func (c *Consumer) consumerAddress(id string) string {
+	u, err := url.Parse(c.address)
+	if err != nil {
+		return c.address
+	}
+	q := u.Query()
+	q.Set(consumerSubscriptionParam, id)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// ID returns the subscription identifier used in the consumer address
+func (s *PushSubscription) ID() string {
+	return s.id
+}
+
+// XAddr returns the event service address of the device
+func (s *PushSubscription) XAddr() string {
+	return s.xaddr
+}
+
+// SubscriptionAddress returns the address of the subscription manager
+func (s *PushSubscription) SubscriptionAddress() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status.SubscriptionAddress
+}
+
+// Status returns current status
+func (s *PushSubscription) Status() SubscriberStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Renew extends the subscription lifetime by the configured termination time.
+// Subscriptions are renewed automatically, this forces immediate renewal.
+func (s *PushSubscription) Renew(ctx context.Context) error {
+	s.mu.Lock()
+	if s.sub == nil {
+		s.mu.Unlock()
+		return errSubscriptionClosed
+	}
+	sub := *s.sub
+	s.mu.Unlock()
+
+	err := s.opts.renew(ctx, &sub)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.status.LastError = err
+		return err
+	}
+	if s.sub != nil {
+		s.sub = &sub
+		s.status.TerminationTime = sub.terminationTime
+	}
+	return nil
+}
+
+// Unsubscribe terminates the subscription on the device and removes it from the consumer
+func (s *PushSubscription) Unsubscribe(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return errSubscriptionClosed
+	}
+	cancel()
+	<-done
+
+	s.consumer.remove(s)
+
+	s.mu.Lock()
+	sub := s.sub
+	s.sub = nil
+	s.status.State = SubscriberStateStopped
+	s.status.SubscriptionAddress = ""
+	s.status.TerminationTime = time.Time{}
+	s.mu.Unlock()
+
+	if sub == nil {
+		return nil
+	}
+
+	_, err := sub.manager(&s.opts, ActionUnsubscribe).UnsubscribeContext(ctx, &Unsubscribe{})
+	return err
+}
+
+// subscribe creates the subscription on the device
+func (s *PushSubscription) subscribe(ctx context.Context) error {
+	request := &Subscribe{
+		Filter:                 s.opts.filter,
+		InitialTerminationTime: NewRelativeTime(s.opts.terminationTime),
+	}
+	request.ConsumerReference.Address.Value = AnyURI(s.consumer.consumerAddress(s.id))
+
+	reply, err := NewNotificationProducer(s.opts.newClient(0), s.xaddr).SubscribeContext(ctx, request)
+	if err != nil {
+		return err
+	}
+	if reply.SubscriptionReference.Address.Value == "" {
+		return errors.New("Subscribe returned empty subscription address")
+	}
+
+	sub := &subscription{reference: reply.SubscriptionReference}
+	sub.update(reply.CurrentTime, reply.TerminationTime, s.opts.terminationTime)
+
+	s.mu.Lock()
+	s.sub = sub
+	s.status.State = SubscriberStateActive
+	s.status.SubscriptionAddress = string(sub.reference.Address.Value)
+	s.status.TerminationTime = sub.terminationTime
+	s.status.LastError = nil
+	s.mu.Unlock()
+
+	if err := s.opts.synchronizationPoint(ctx, sub); err != nil {
+		s.mu.Lock()
+		s.status.LastError = err
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// run renews the subscription at the half of the remaining lifetime
+// and recreates it when renewal is not possible
+func (s *PushSubscription) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	backoff := s.opts.minBackoff
+	for {
+		s.mu.Lock()
+		sub := *s.sub
+		s.mu.Unlock()
+
+		delay := time.Until(sub.terminationTime) / 2
+		if delay < time.Second {
+			delay = time.Second
+		}
+		if !sleep(ctx, delay) {
+			return
+		}
+
+		err := s.Renew(ctx)
+		if err == nil {
+			backoff = s.opts.minBackoff
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if !isResourceUnknown(err) && time.Now().Before(sub.terminationTime) {
+			continue
+		}
+
+		// Subscription is lost, e.g. the device rebooted
+		for {
+			s.mu.Lock()
+			s.status.State = SubscriberStateBackoff
+			s.mu.Unlock()
+
+			if !sleep(ctx, backoff) {
+				return
+			}
+			if backoff *= 2; backoff > s.opts.maxBackoff {
+				backoff = s.opts.maxBackoff
+			}
+
+			err := s.subscribe(ctx)
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			s.mu.Lock()
+			s.status.LastError = err
+			s.mu.Unlock()
+		}
+
+		s.mu.Lock()
+		s.status.Reconnects++
+		s.mu.Unlock()
+	}
+}
@@ -29,7 +29,7 @@ type CreatePullPointSubscription struct {
 	XMLName xml.Name `xml:"http://www.onvif.org/ver10/events/wsdl CreatePullPointSubscription"`
 
 	// Optional XPATH expression to select specific topics.
-	Filter FilterType `xml:"Filter,omitempty"`
+	Filter *FilterType `xml:"http://www.onvif.org/ver10/events/wsdl Filter,omitempty"`
 
 	// Initial termination time.
 	InitialTerminationTime AbsoluteOrRelativeTimeType `xml:"InitialTerminationTime,omitempty"`
@@ -55,10 +55,10 @@ type PullMessages struct {
 	XMLName xml.Name `xml:"http://www.onvif.org/ver10/events/wsdl PullMessages"`
 
 	// Maximum time to block until this method returns.
-	Timeout Duration `xml:"http://www.onvif.org/ver10/schema Timeout,omitempty"`
+	Timeout Duration `xml:"http://www.onvif.org/ver10/events/wsdl Timeout,omitempty"`
 
 	// Upper limit for the number of messages to return at once. A server implementation may decide to return less messages.
-	MessageLimit int32 `xml:"http://www.onvif.org/ver10/schema MessageLimit,omitempty"`
+	MessageLimit int32 `xml:"http://www.onvif.org/ver10/events/wsdl MessageLimit,omitempty"`
 }
 
 // PullMessagesResponse type
@@ -79,7 +79,7 @@ type Seek struct {
 	XMLName xml.Name `xml:"http://www.onvif.org/ver10/events/wsdl Seek"`
 
 	// The date and time to match against stored messages.
-	UtcTime string `xml:"http://www.onvif.org/ver10/schema UtcTime,omitempty"`
+	UtcTime string `xml:"http://www.onvif.org/ver10/events/wsdl UtcTime,omitempty"`
 
 	// Reverse the pull direction of PullMessages.
 	Reverse bool `xml:"http://www.onvif.org/ver10/events/wsdl Reverse,omitempty"`
@@ -236,17 +236,16 @@ type ProblemAction ProblemActionType
 
 // EndpointReferenceType type
 type EndpointReferenceType struct {
-	XMLName xml.Name `xml:"http://www.w3.org/2005/08/addressing EndpointReference"`
+	Address AttributedURIType `xml:"http://www.w3.org/2005/08/addressing Address,omitempty"`
 
-	Address AttributedURIType `xml:"Address,omitempty"`
+	ReferenceParameters ReferenceParametersType `xml:"http://www.w3.org/2005/08/addressing ReferenceParameters,omitempty"`
 
-	ReferenceParameters ReferenceParametersType `xml:"ReferenceParameters,omitempty"`
-
-	Metadata Metadata `xml:"Metadata,omitempty"`
+	Metadata Metadata `xml:"http://www.w3.org/2005/08/addressing Metadata,omitempty"`
 }
 
 // ReferenceParametersType type
 type ReferenceParametersType struct {
+	Parameter []ReferenceParameter `xml:",any"`
 }
 
 // MetadataType type
@@ -265,9 +264,7 @@ type RelatesToType struct {
 
 // AttributedURIType type
 type AttributedURIType struct {
-	XMLName xml.Name `xml:"http://www.w3.org/2005/08/addressing MessageID"`
-
-	Value AnyURI
+	Value AnyURI `xml:",chardata"`
 }
 
 // ProblemActionType type
@@ -302,9 +299,12 @@ type ExtensibleDocumented struct {
 
 // QueryExpressionType type
 type QueryExpressionType struct {
-	XMLName xml.Name `xml:"http://docs.oasis-open.org/wsn/t-1 ProducerProperties"`
+	Value string `xml:",chardata"`
 
-	Dialect AnyURI `xml:"http://www.onvif.org/ver10/schema Dialect,attr,omitempty"`
+	Dialect AnyURI `xml:"Dialect,attr,omitempty"`
+
+	// Namespace prefixes used in the expression, marshalled as xmlns declarations.
+	Namespaces map[string]string `xml:"-"`
 }
 
 // TopicNamespaceType type
@@ -346,6 +346,9 @@ type TopicSetType struct {
 	XMLName xml.Name `xml:"http://docs.oasis-open.org/wsn/t-1 TopicSet"`
 
 	*ExtensibleDocumented
+
+	// Root topics, see ParseTopicSet
+	Topics []soap.Element `xml:",any"`
 }
 
 // BaseFault type
@@ -355,7 +358,7 @@ type BaseFault BaseFaultType
 type BaseFaultType struct {
 	XMLName xml.Name `xml:"http://docs.oasis-open.org/wsrf/bf-2 BaseFault"`
 
-	Timestamp string `xml:"http://www.onvif.org/ver10/schema Timestamp,omitempty"`
+	Timestamp string `xml:"http://docs.oasis-open.org/wsrf/bf-2 Timestamp,omitempty"`
 
 	Originator EndpointReferenceType `xml:"Originator,omitempty"`
 
@@ -364,9 +367,9 @@ type BaseFaultType struct {
 	} `xml:"ErrorCode,omitempty"`
 
 	Description []struct {
-		Value string
+		Value string `xml:",chardata"`
 
-		string `xml:",attr,omitempty"`
+		Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
 	} `xml:"Description,omitempty"`
 
 	FaultCause struct {
@@ -428,7 +431,7 @@ type Subscribe struct {
 
 	ConsumerReference EndpointReferenceType `xml:"ConsumerReference,omitempty"`
 
-	Filter FilterType `xml:"Filter,omitempty"`
+	Filter *FilterType `xml:"http://docs.oasis-open.org/wsn/b-2 Filter,omitempty"`
 
 	InitialTerminationTime AbsoluteOrRelativeTimeType `xml:"InitialTerminationTime,omitempty"`
 
@@ -436,6 +439,63 @@ type Subscribe struct {
 	} `xml:"SubscriptionPolicy,omitempty"`
 }
 
+// SubscribeResponse type
+type SubscribeResponse struct {
+	XMLName xml.Name `xml:"SubscribeResponse"`
+
+	SubscriptionReference EndpointReferenceType `xml:"SubscriptionReference,omitempty"`
+
+	CurrentTime CurrentTime `xml:"CurrentTime,omitempty"`
+
+	TerminationTime TerminationTime `xml:"TerminationTime,omitempty"`
+}
+
+// Renew type
+type Renew struct {
+	XMLName xml.Name `xml:"http://docs.oasis-open.org/wsn/b-2 Renew"`
+
+	TerminationTime AbsoluteOrRelativeTimeType `xml:"http://docs.oasis-open.org/wsn/b-2 TerminationTime"`
+}
+
+// RenewResponse type
+type RenewResponse struct {
+	XMLName xml.Name `xml:"RenewResponse"`
+
+	TerminationTime TerminationTime `xml:"TerminationTime,omitempty"`
+
+	CurrentTime CurrentTime `xml:"CurrentTime,omitempty"`
+}
+
+// Unsubscribe type
+type Unsubscribe struct {
+	XMLName xml.Name `xml:"http://docs.oasis-open.org/wsn/b-2 Unsubscribe"`
+}
+
+// UnsubscribeResponse type
+type UnsubscribeResponse struct {
+	XMLName xml.Name `xml:"UnsubscribeResponse"`
+}
+
+// PauseSubscription type
+type PauseSubscription struct {
+	XMLName xml.Name `xml:"http://docs.oasis-open.org/wsn/b-2 PauseSubscription"`
+}
+
+// PauseSubscriptionResponse type
+type PauseSubscriptionResponse struct {
+	XMLName xml.Name `xml:"PauseSubscriptionResponse"`
+}
+
+// ResumeSubscription type
+type ResumeSubscription struct {
+	XMLName xml.Name `xml:"http://docs.oasis-open.org/wsn/b-2 ResumeSubscription"`
+}
+
+// ResumeSubscriptionResponse type
+type ResumeSubscriptionResponse struct {
+	XMLName xml.Name `xml:"ResumeSubscriptionResponse"`
+}
+
 // SubscribeCreationFailedFault type
 type SubscribeCreationFailedFault SubscribeCreationFailedFaultType
 
@@ -505,14 +565,19 @@ type ResumeFailedFault ResumeFailedFaultType
 
 // TopicExpressionType type
 type TopicExpressionType struct {
-	XMLName xml.Name `xml:"http://docs.oasis-open.org/wsn/b-2 TopicExpression"`
+	Value string `xml:",chardata"`
 
-	Dialect AnyURI `xml:"http://www.onvif.org/ver10/schema Dialect,attr,omitempty"`
+	Dialect AnyURI `xml:"Dialect,attr,omitempty"`
+
+	// Namespace prefixes used in the expression, marshalled as xmlns declarations.
+	Namespaces map[string]string `xml:"-"`
 }
 
 // FilterType type
 type FilterType struct {
-	XMLName xml.Name `xml:"http://docs.oasis-open.org/wsn/b-2 Filter"`
+	TopicExpression []TopicExpressionType `xml:"http://docs.oasis-open.org/wsn/b-2 TopicExpression,omitempty"`
+
+	MessageContent []QueryExpressionType `xml:"http://docs.oasis-open.org/wsn/b-2 MessageContent,omitempty"`
 }
 
 // SubscriptionPolicyType type
@@ -531,6 +596,7 @@ type NotificationMessageHolderType struct {
 	ProducerReference ProducerReference `xml:"ProducerReference,omitempty"`
 
 	Message struct {
+		Message Message `xml:"http://www.onvif.org/ver10/schema Message"`
 	} `xml:"Message,omitempty"`
 }
 
@@ -623,9 +689,9 @@ type UnacceptableInitialTerminationTimeFaultType struct {
 
 	*BaseFaultType
 
-	MinimumTime string `xml:"http://www.onvif.org/ver10/schema MinimumTime,omitempty"`
+	MinimumTime string `xml:"http://docs.oasis-open.org/wsn/b-2 MinimumTime,omitempty"`
 
-	MaximumTime string `xml:"http://www.onvif.org/ver10/schema MaximumTime,omitempty"`
+	MaximumTime string `xml:"http://docs.oasis-open.org/wsn/b-2 MaximumTime,omitempty"`
 }
 
 // NoCurrentMessageOnTopicFaultType type
@@ -662,9 +728,9 @@ type UnacceptableTerminationTimeFaultType struct {
 
 	*BaseFaultType
 
-	MinimumTime string `xml:"http://www.onvif.org/ver10/schema MinimumTime,omitempty"`
+	MinimumTime string `xml:"http://docs.oasis-open.org/wsn/b-2 MinimumTime,omitempty"`
 
-	MaximumTime string `xml:"http://www.onvif.org/ver10/schema MaximumTime,omitempty"`
+	MaximumTime string `xml:"http://docs.oasis-open.org/wsn/b-2 MaximumTime,omitempty"`
 }
 
 // UnableToDestroySubscriptionFaultType type
@@ -913,9 +979,9 @@ func (service *pullPointSubscription) SetSynchronizationPoint(request *SetSynchr
 
 func (service *pullPointSubscription) UnsubscribeContext(ctx context.Context) error {
 
-	err := service.client.CallContext(ctx, service.xaddr, "''", nil, struct{}{})
+	err := service.client.CallContext(ctx, service.xaddr, ActionUnsubscribe, &Unsubscribe{}, &UnsubscribeResponse{})
 	if err != nil {
-		return err
+		return decodeFault(err)
 	}
 
 	return nil
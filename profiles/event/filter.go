@@ -0,0 +1,176 @@
+package event
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/videonext/onvif/soap"
+)
+
+// MessageContentFilterDialectItemFilter is the ONVIF message content filter dialect
+const MessageContentFilterDialectItemFilter = "http://www.onvif.org/ver10/tev/messageContentFilter/ItemFilter"
+
+// Namespaces of well-known prefixes, declared automatically when used in filter expressions
+var knownNamespaces = map[string]string{
+	"tns1": "http://www.onvif.org/ver10/topics",
+	"tt":   "http://www.onvif.org/ver10/schema",
+}
+
+var errConcreteTopic = errors.New("Concrete topic expression can not contain wildcards or alternatives")
+
+// MarshalXML implements xml.Marshaler
+func (t TopicExpressionType) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalExpression(e, start, string(t.Dialect), t.Value, t.Namespaces)
+}
+
+// MarshalXML implements xml.Marshaler
+func (q QueryExpressionType) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return marshalExpression(e, start, string(q.Dialect), q.Value, q.Namespaces)
+}
+
+// ResolveNamespaces implements soap.NamespaceResolver, the declarations of the prefixes
+// used in the expression are kept to marshal it back, e.g. with SetMetadataConfiguration
+func (t *TopicExpressionType) ResolveNamespaces(scope map[string]string) {
+	t.Namespaces = soap.UsedNamespaces(t.Value, scope)
+}
+
+// ResolveNamespaces implements soap.NamespaceResolver
+func (q *QueryExpressionType) ResolveNamespaces(scope map[string]string) {
+	q.Namespaces = soap.UsedNamespaces(q.Value, scope)
+}
+
+func marshalExpression(e *xml.Encoder, start xml.StartElement, dialect, value string, namespaces map[string]string) error {
+	if dialect != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "Dialect"}, Value: dialect})
+	}
+
+	// Sort prefixes to keep the output stable
+	prefixes := make([]string, 0, len(namespaces))
+	for prefix := range namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: namespaces[prefix]})
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeToken(xml.CharData(value)); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// FilterBuilder builds FilterType for CreatePullPointSubscription and Subscribe.
+// Prefixes tns1 and tt are declared automatically, others have to be added with Namespace.
+type FilterBuilder struct {
+	namespaces map[string]string
+	dialect    string
+	topics     []string
+	content    []string
+	err        error
+}
+
+// NewFilterBuilder creates FilterBuilder instance
+func NewFilterBuilder() *FilterBuilder {
+	return &FilterBuilder{namespaces: map[string]string{}}
+}
+
+// Namespace declares prefix used in the topic or message content expressions,
+// e.g. Namespace("tnsaxis", "http://www.axis.com/2009/event/topics")
+func (b *FilterBuilder) Namespace(prefix, uri string) *FilterBuilder {
+	b.namespaces[prefix] = uri
+	return b
+}
+
+// Topics selects topics with the ConcreteSet dialect. Expressions are joined
+// as alternatives and may use wildcards, e.g. "tns1:RuleEngine//."
+func (b *FilterBuilder) Topics(expressions ...string) *FilterBuilder {
+	if b.dialect == TopicExpressionDialectConcrete {
+		b.err = errors.New("Concrete and ConcreteSet topic expressions can not be mixed")
+	}
+	b.dialect = TopicExpressionDialectConcreteSet
+	b.topics = append(b.topics, expressions...)
+	return b
+}
+
+// Topic selects single topic with the Concrete dialect, e.g. "tns1:VideoSource/MotionAlarm"
+func (b *FilterBuilder) Topic(expression string) *FilterBuilder {
+	if len(b.topics) > 0 {
+		b.err = errors.New("Concrete dialect allows single topic expression")
+	}
+	if strings.ContainsAny(expression, "|*") || strings.Contains(expression, "//") {
+		b.err = errConcreteTopic
+	}
+	b.dialect = TopicExpressionDialectConcrete
+	b.topics = append(b.topics, expression)
+	return b
+}
+
+// MessageContent adds XPath expression on the message content. Several expressions are combined with "and".
+func (b *FilterBuilder) MessageContent(xpath string) *FilterBuilder {
+	b.content = append(b.content, xpath)
+	return b
+}
+
+// SimpleItem adds message content expression selecting messages
+// with the SimpleItem of given name and value, e.g. SimpleItem("IsMotion", "true")
+func (b *FilterBuilder) SimpleItem(name, value string) *FilterBuilder {
+	return b.MessageContent(fmt.Sprintf(`boolean(//tt:SimpleItem[@Name=%s and @Value=%s])`, xpathLiteral(name), xpathLiteral(value)))
+}
+
+// Build returns filter
+func (b *FilterBuilder) Build() (*FilterType, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	f := &FilterType{}
+	if len(b.topics) > 0 {
+		value := strings.Join(b.topics, "|")
+		f.TopicExpression = append(f.TopicExpression, TopicExpressionType{
+			Value:      value,
+			Dialect:    AnyURI(b.dialect),
+			Namespaces: b.usedNamespaces(value),
+		})
+	}
+	if len(b.content) > 0 {
+		value := strings.Join(b.content, " and ")
+		f.MessageContent = append(f.MessageContent, QueryExpressionType{
+			Value:      value,
+			Dialect:    MessageContentFilterDialectItemFilter,
+			Namespaces: b.usedNamespaces(value),
+		})
+	}
+
+	return f, nil
+}
+
+// usedNamespaces returns declarations of the prefixes found in the expression
+func (b *FilterBuilder) usedNamespaces(expression string) map[string]string {
+	namespaces := map[string]string{}
+	for _, ns := range []map[string]string{knownNamespaces, b.namespaces} {
+		for prefix, uri := range ns {
+			if strings.Contains(expression, prefix+":") {
+				namespaces[prefix] = uri
+			}
+		}
+	}
+	return namespaces
+}
+
+// xpathLiteral quotes string for XPath 1.0, which has no escaping
+func xpathLiteral(s string) string {
+	if !strings.Contains(s, `"`) {
+		return `"` + s + `"`
+	}
+	if !strings.Contains(s, `'`) {
+		return `'` + s + `'`
+	}
+	return `concat("` + strings.Replace(s, `"`, `", '"', "`, -1) + `")`
+}
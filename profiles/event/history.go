@@ -0,0 +1,179 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errNoPersistentStorage = errors.New("Device does not support persistent notification storage")
+
+// Timeout of PullMessages while reading stored messages, they are returned immediately
+const historyPullTimeout = time.Second
+
+// HistoryRequest selects stored notifications
+type HistoryRequest struct {
+	// Time window of the messages, zero To means now
+	From time.Time
+	To   time.Time
+
+	// Reverse reads messages from To back to From
+	Reverse bool
+
+	// Maximum number of returned messages, zero means no limit
+	MaxMessages int
+
+	// Optional deduplicator shared with the live subscription
+	Deduplicator *Deduplicator
+}
+
+// NewSeek creates Seek request for the time
+func NewSeek(t time.Time, reverse bool) *Seek {
+	return &Seek{UtcTime: t.UTC().Format(time.RFC3339Nano), Reverse: reverse}
+}
+
+// ReadHistory retrieves stored notifications of the time window from the device
+// advertising PersistentNotificationStorage. The xaddr is the event service address,
+// the pull point subscription is created with given options and released afterwards.
+// Messages are returned in the pull order, oldest first unless Reverse is set.
+func ReadHistory(ctx context.Context, xaddr string, req HistoryRequest, opt ...SubscriberOption) ([]NotificationMessage, error) {
+	s := NewSubscriber(xaddr, opt...)
+	opts := &s.opts
+
+	caps, err := NewEventPortType(opts.newClient(0), xaddr).GetServiceCapabilitiesContext(ctx, &GetServiceCapabilities{})
+	if err != nil {
+		return nil, err
+	}
+	if !caps.Capabilities.PersistentNotificationStorage {
+		return nil, errNoPersistentStorage
+	}
+
+	sub, err := s.subscribe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer opts.unsubscribe(sub)
+
+	to := req.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	start := req.From
+	if req.Reverse {
+		start = to
+	}
+	// Sent with the action of the WS-Addressing header, the generated port uses other
+	err = opts.newClient(sub.clockOffset, addressingHeaders(ActionSeek, &sub.reference)...).
+		CallContext(ctx, string(sub.reference.Address.Value), ActionSeek, NewSeek(start, req.Reverse), &SeekResponse{})
+	if err != nil {
+		return nil, decodeFault(err)
+	}
+
+	dedup := req.Deduplicator
+	if dedup == nil {
+		dedup = NewDeduplicator(0)
+	}
+
+	messages := []NotificationMessage{}
+	for {
+		if time.Until(sub.terminationTime) < 2*historyPullTimeout {
+			if err := opts.renew(ctx, sub); err != nil {
+				return messages, err
+			}
+		}
+
+		limit := opts.messageLimit
+		if req.MaxMessages > 0 && int(limit) > req.MaxMessages-len(messages) {
+			limit = int32(req.MaxMessages - len(messages))
+		}
+		request := &PullMessages{Timeout: Duration(FormatDuration(historyPullTimeout)), MessageLimit: limit}
+		reply := &PullMessagesResponse{}
+		err := opts.newClient(sub.clockOffset, addressingHeaders(ActionPullMessages, &sub.reference)...).
+			CallContext(ctx, string(sub.reference.Address.Value), ActionPullMessages, request, reply)
+		if err != nil {
+			return messages, err
+		}
+		sub.pulled(reply)
+
+		// No more stored messages
+		if len(reply.NotificationMessage) == 0 {
+			return messages, nil
+		}
+
+		for _, m := range reply.NotificationMessage {
+			if t, err := m.Message.Message.Time(); err == nil {
+				// Past the window, the rest is live or out of range
+				if (!req.Reverse && t.After(to)) || (req.Reverse && t.Before(req.From)) {
+					return messages, nil
+				}
+				if t.Before(req.From) || t.After(to) {
+					continue
+				}
+			}
+			if dedup.Seen(&m) {
+				continue
+			}
+
+			messages = append(messages, m)
+			if req.MaxMessages > 0 && len(messages) >= req.MaxMessages {
+				return messages, nil
+			}
+		}
+	}
+}
+
+// Deduplicator detects repeated notifications, e.g. stored messages
+// already received live. It remembers the given number of last messages.
+type Deduplicator struct {
+	mu    sync.Mutex
+	size  int
+	seen  map[string]struct{}
+	order []string
+}
+
+// Default number of messages remembered by Deduplicator
+const defaultDeduplicatorSize = 10000
+
+// NewDeduplicator creates Deduplicator remembering size last messages, default if zero
+func NewDeduplicator(size int) *Deduplicator {
+	if size <= 0 {
+		size = defaultDeduplicatorSize
+	}
+	return &Deduplicator{size: size, seen: map[string]struct{}{}}
+}
+
+// Seen reports if the message was seen before and remembers it
+func (d *Deduplicator) Seen(n *NotificationMessage) bool {
+	key := MessageKey(n)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.seen[key]; ok {
+		return true
+	}
+
+	d.seen[key] = struct{}{}
+	d.order = append(d.order, key)
+	if len(d.order) > d.size {
+		delete(d.seen, d.order[0])
+		d.order = d.order[1:]
+	}
+	return false
+}
+
+// MessageKey identifies the notification by topic, time, operation and items
+func MessageKey(n *NotificationMessage) string {
+	m := &n.Message.Message
+	return strings.Join([]string{
+		strings.TrimSpace(n.Topic.Value),
+		m.UtcTime,
+		string(m.PropertyOperation),
+		sourceKey(m.Source.SimpleItem),
+		sourceKey(m.Key.SimpleItem),
+		sourceKey(m.Data.SimpleItem),
+	}, "|")
+}
@@ -0,0 +1,35 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testPullPointResponses are the responses of the event service with persistent storage
+var testPullPointResponses = map[string]string{
+	"GetServiceCapabilities": `<tev:GetServiceCapabilitiesResponse><tev:Capabilities PersistentNotificationStorage="true"/></tev:GetServiceCapabilitiesResponse>`,
+	"CreatePullPointSubscription": `<tev:CreatePullPointSubscriptionResponse><tev:SubscriptionReference><wsa:Address>{{URL}}/subscription</wsa:Address></tev:SubscriptionReference>` +
+		`<wsnt:CurrentTime>2024-01-01T00:00:00Z</wsnt:CurrentTime><wsnt:TerminationTime>2024-01-01T00:01:00Z</wsnt:TerminationTime></tev:CreatePullPointSubscriptionResponse>`,
+	"Seek":                    `<tev:SeekResponse/>`,
+	"SetSynchronizationPoint": `<tev:SetSynchronizationPointResponse/>`,
+	"PullMessages": `<tev:PullMessagesResponse><tev:CurrentTime>2024-01-01T00:00:00Z</tev:CurrentTime>` +
+		`<tev:TerminationTime>2024-01-01T00:01:00Z</tev:TerminationTime></tev:PullMessagesResponse>`,
+	"Unsubscribe": `<wsnt:UnsubscribeResponse/>`,
+}
+
+func TestReadHistoryActions(t *testing.T) {
+	s := newEventService(testPullPointResponses)
+	defer s.Close()
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := ReadHistory(context.Background(), s.URL, HistoryRequest{From: from, To: from.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.requested(ActionSeek) {
+		t.Error("Seek not sent with its WS-Addressing action")
+	}
+	if actions := s.mismatched(); len(actions) != 0 {
+		t.Errorf("actions differ from WS-Addressing headers: %v", actions)
+	}
+}
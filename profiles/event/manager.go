@@ -0,0 +1,207 @@
+package event
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/videonext/onvif/soap"
+)
+
+// WS-BaseNotification actions of the pausable subscription manager
+const (
+	ActionPauseSubscription  = "http://docs.oasis-open.org/wsn/bw-2/PausableSubscriptionManager/PauseSubscriptionRequest"
+	ActionResumeSubscription = "http://docs.oasis-open.org/wsn/bw-2/PausableSubscriptionManager/ResumeSubscriptionRequest"
+)
+
+// SubscriptionManager type
+type SubscriptionManager interface {
+
+	// Error can be either of the following types:
+	//
+	//   - UnacceptableTerminationTimeFault
+	/* Extends the subscription lifetime. The termination time is either absolute,
+	see NewAbsoluteTime, or relative to the current device time, see NewRelativeTime. */
+	Renew(request *Renew) (*RenewResponse, error)
+
+	RenewContext(ctx context.Context, request *Renew) (*RenewResponse, error)
+
+	// Error can be either of the following types:
+	//
+	//   - UnableToDestroySubscriptionFault
+	/* Terminates the subscription. */
+	Unsubscribe(request *Unsubscribe) (*UnsubscribeResponse, error)
+
+	UnsubscribeContext(ctx context.Context, request *Unsubscribe) (*UnsubscribeResponse, error)
+
+	// Error can be either of the following types:
+	//
+	//   - PauseFailedFault
+	/* Stops delivery of notifications until ResumeSubscription. */
+	PauseSubscription(request *PauseSubscription) (*PauseSubscriptionResponse, error)
+
+	PauseSubscriptionContext(ctx context.Context, request *PauseSubscription) (*PauseSubscriptionResponse, error)
+
+	// Error can be either of the following types:
+	//
+	//   - ResumeFailedFault
+	/* Resumes delivery of notifications of the paused subscription. */
+	ResumeSubscription(request *ResumeSubscription) (*ResumeSubscriptionResponse, error)
+
+	ResumeSubscriptionContext(ctx context.Context, request *ResumeSubscription) (*ResumeSubscriptionResponse, error)
+}
+
+// subscriptionManager type
+type subscriptionManager struct {
+	client *soap.Client
+	xaddr  string
+}
+
+func NewSubscriptionManager(client *soap.Client, xaddr string) SubscriptionManager {
+	return &subscriptionManager{
+		client: client,
+		xaddr:  xaddr,
+	}
+}
+
+func (service *subscriptionManager) RenewContext(ctx context.Context, request *Renew) (*RenewResponse, error) {
+	response := new(RenewResponse)
+	err := service.client.CallContext(ctx, service.xaddr, ActionRenew, request, response)
+	if err != nil {
+		return nil, decodeFault(err)
+	}
+
+	return response, nil
+}
+
+func (service *subscriptionManager) Renew(request *Renew) (*RenewResponse, error) {
+	return service.RenewContext(
+		context.Background(),
+		request,
+	)
+}
+
+func (service *subscriptionManager) UnsubscribeContext(ctx context.Context, request *Unsubscribe) (*UnsubscribeResponse, error) {
+	response := new(UnsubscribeResponse)
+	err := service.client.CallContext(ctx, service.xaddr, ActionUnsubscribe, request, response)
+	if err != nil {
+		return nil, decodeFault(err)
+	}
+
+	return response, nil
+}
+
+func (service *subscriptionManager) Unsubscribe(request *Unsubscribe) (*UnsubscribeResponse, error) {
+	return service.UnsubscribeContext(
+		context.Background(),
+		request,
+	)
+}
+
+func (service *subscriptionManager) PauseSubscriptionContext(ctx context.Context, request *PauseSubscription) (*PauseSubscriptionResponse, error) {
+	response := new(PauseSubscriptionResponse)
+	err := service.client.CallContext(ctx, service.xaddr, ActionPauseSubscription, request, response)
+	if err != nil {
+		return nil, decodeFault(err)
+	}
+
+	return response, nil
+}
+
+func (service *subscriptionManager) PauseSubscription(request *PauseSubscription) (*PauseSubscriptionResponse, error) {
+	return service.PauseSubscriptionContext(
+		context.Background(),
+		request,
+	)
+}
+
+func (service *subscriptionManager) ResumeSubscriptionContext(ctx context.Context, request *ResumeSubscription) (*ResumeSubscriptionResponse, error) {
+	response := new(ResumeSubscriptionResponse)
+	err := service.client.CallContext(ctx, service.xaddr, ActionResumeSubscription, request, response)
+	if err != nil {
+		return nil, decodeFault(err)
+	}
+
+	return response, nil
+}
+
+func (service *subscriptionManager) ResumeSubscription(request *ResumeSubscription) (*ResumeSubscriptionResponse, error) {
+	return service.ResumeSubscriptionContext(
+		context.Background(),
+		request,
+	)
+}
+
+// NewRelativeTime returns termination time relative to the current device time
+func NewRelativeTime(d time.Duration) AbsoluteOrRelativeTimeType {
+	return AbsoluteOrRelativeTimeType(FormatDuration(d))
+}
+
+// NewAbsoluteTime returns absolute termination time
+func NewAbsoluteTime(t time.Time) AbsoluteOrRelativeTimeType {
+	return AbsoluteOrRelativeTimeType(t.UTC().Format(time.RFC3339))
+}
+
+func (f *UnacceptableTerminationTimeFault) Error() string {
+	s := faultError("Unacceptable termination time", f.BaseFaultType)
+	if f.MinimumTime != "" || f.MaximumTime != "" {
+		s += " (allowed " + f.MinimumTime + " - " + f.MaximumTime + ")"
+	}
+	return s
+}
+
+func (f *UnableToDestroySubscriptionFault) Error() string {
+	return faultError("Unable to destroy subscription", f.BaseFaultType)
+}
+
+func (f *PauseFailedFault) Error() string {
+	return faultError("Pause failed", f.BaseFaultType)
+}
+
+func (f *ResumeFailedFault) Error() string {
+	return faultError("Resume failed", f.BaseFaultType)
+}
+
+func faultError(s string, base *BaseFaultType) string {
+	if base == nil {
+		return s
+	}
+	for _, d := range base.Description {
+		if d.Value = strings.TrimSpace(d.Value); d.Value != "" {
+			s += ": " + d.Value
+		}
+	}
+	return s
+}
+
+// decodeFault converts SOAP fault with the WS-BaseNotification fault
+// detail to the typed fault, other errors are returned as is
+func decodeFault(err error) error {
+	fault, ok := err.(*soap.SOAPFault)
+	if !ok {
+		return err
+	}
+
+	for i := range fault.Detail.Content {
+		e := &fault.Detail.Content[i]
+
+		var typed error
+		switch e.XMLName.Local {
+		case "UnacceptableTerminationTimeFault":
+			typed = &UnacceptableTerminationTimeFault{}
+		case "UnableToDestroySubscriptionFault":
+			typed = &UnableToDestroySubscriptionFault{}
+		case "PauseFailedFault":
+			typed = &PauseFailedFault{}
+		case "ResumeFailedFault":
+			typed = &ResumeFailedFault{}
+		default:
+			continue
+		}
+
+		if e.Decode(typed) == nil {
+			return typed
+		}
+	}
+	return err
+}
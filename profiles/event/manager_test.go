@@ -0,0 +1,102 @@
+package event
+
+import (
+	"context"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/videonext/onvif/soap"
+)
+
+// unacceptableTerminationTime is the fault of a device rejecting Renew
+const unacceptableTerminationTime = `<s:Fault xmlns:wsrf-bf="http://docs.oasis-open.org/wsrf/bf-2">` +
+	`<s:Code><s:Value>s:Receiver</s:Value><s:Subcode><s:Value>wsnt:UnacceptableTerminationTimeFault</s:Value></s:Subcode></s:Code>` +
+	`<s:Reason><s:Text xml:lang="en">Unacceptable termination time</s:Text></s:Reason>` +
+	`<s:Detail><wsnt:UnacceptableTerminationTimeFault>` +
+	`<wsrf-bf:Timestamp>2024-01-01T00:00:00Z</wsrf-bf:Timestamp>` +
+	`<wsrf-bf:Description xml:lang="en">Termination time is out of range</wsrf-bf:Description>` +
+	`<wsnt:MinimumTime>2024-01-01T00:00:10Z</wsnt:MinimumTime>` +
+	`<wsnt:MaximumTime>2024-01-01T01:00:00Z</wsnt:MaximumTime>` +
+	`</wsnt:UnacceptableTerminationTimeFault></s:Detail></s:Fault>`
+
+func TestRenewFault(t *testing.T) {
+	s := newEventService(map[string]string{"Renew": unacceptableTerminationTime})
+	defer s.Close()
+
+	_, err := NewSubscriptionManager(soap.NewClient(), s.URL).RenewContext(context.Background(), &Renew{TerminationTime: NewRelativeTime(0)})
+	fault, ok := err.(*UnacceptableTerminationTimeFault)
+	if !ok {
+		t.Fatalf("error %v is not *UnacceptableTerminationTimeFault", err)
+	}
+	if fault.MinimumTime != "2024-01-01T00:00:10Z" || fault.MaximumTime != "2024-01-01T01:00:00Z" {
+		t.Errorf("bounds %q %q", fault.MinimumTime, fault.MaximumTime)
+	}
+	if fault.BaseFaultType == nil || fault.Timestamp != "2024-01-01T00:00:00Z" {
+		t.Errorf("base fault %+v", fault.BaseFaultType)
+	}
+	expected := "Unacceptable termination time: Termination time is out of range (allowed 2024-01-01T00:00:10Z - 2024-01-01T01:00:00Z)"
+	if msg := err.Error(); !strings.Contains(msg, expected) {
+		t.Errorf("error %q, expected %q", msg, expected)
+	}
+
+	// namespaces of the fields are checked by encoding/xml
+	detail := unacceptableTerminationTime[strings.Index(unacceptableTerminationTime, "<wsnt:Unacceptable"):strings.Index(unacceptableTerminationTime, "</s:Detail>")]
+	detail = strings.Replace(detail, "<wsnt:UnacceptableTerminationTimeFault>",
+		`<wsnt:UnacceptableTerminationTimeFault xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2" xmlns:wsrf-bf="http://docs.oasis-open.org/wsrf/bf-2">`, 1)
+	strict := &UnacceptableTerminationTimeFault{}
+	if err := xml.Unmarshal([]byte(detail), strict); err != nil {
+		t.Fatal(err)
+	}
+	if strict.MinimumTime != fault.MinimumTime || strict.MaximumTime != fault.MaximumTime || strict.Timestamp != fault.Timestamp {
+		t.Errorf("encoding/xml decoded %q %q %q", strict.MinimumTime, strict.MaximumTime, strict.Timestamp)
+	}
+}
+
+func TestRenewResponseTimes(t *testing.T) {
+	tests := []struct {
+		name        string
+		reply       string
+		current     time.Time
+		termination time.Time
+	}{
+		{
+			name: "utc",
+			reply: `<wsnt:RenewResponse><wsnt:TerminationTime>2024-01-01T00:01:00Z</wsnt:TerminationTime>` +
+				`<wsnt:CurrentTime>2024-01-01T00:00:00Z</wsnt:CurrentTime></wsnt:RenewResponse>`,
+			current:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			termination: time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC),
+		},
+		{
+			name: "no time zone",
+			reply: `<wsnt:RenewResponse><wsnt:TerminationTime>2024-01-01T00:01:00.5</wsnt:TerminationTime>` +
+				`<wsnt:CurrentTime>2024-01-01T00:00:00</wsnt:CurrentTime></wsnt:RenewResponse>`,
+			current:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			termination: time.Date(2024, 1, 1, 0, 1, 0, 500000000, time.UTC),
+		},
+		{
+			name: "invalid and missing",
+			reply: `<wsnt:RenewResponse><wsnt:TerminationTime>tomorrow</wsnt:TerminationTime>` +
+				`</wsnt:RenewResponse>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newEventService(map[string]string{"Renew": test.reply})
+			defer s.Close()
+
+			reply, err := NewSubscriptionManager(soap.NewClient(), s.URL).RenewContext(context.Background(), &Renew{TerminationTime: NewRelativeTime(time.Minute)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current := reply.CurrentTime.Time(); !current.Equal(test.current) {
+				t.Errorf("current time %v, expected %v", current, test.current)
+			}
+			if termination := reply.TerminationTime.Time(); !termination.Equal(test.termination) {
+				t.Errorf("termination time %v, expected %v", termination, test.termination)
+			}
+		})
+	}
+}
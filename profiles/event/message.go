@@ -0,0 +1,184 @@
+package event
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"github.com/videonext/onvif/soap"
+)
+
+// Topic expression dialects
+const (
+	TopicExpressionDialectSimple      = "http://docs.oasis-open.org/wsn/t-1/TopicExpression/Simple"
+	TopicExpressionDialectConcrete    = "http://docs.oasis-open.org/wsn/t-1/TopicExpression/Concrete"
+	TopicExpressionDialectConcreteSet = "http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet"
+)
+
+// PropertyOperation type
+type PropertyOperation string
+
+const (
+	// PropertyOperationInitialized const
+	PropertyOperationInitialized PropertyOperation = "Initialized"
+
+	// PropertyOperationDeleted const
+	PropertyOperationDeleted PropertyOperation = "Deleted"
+
+	// PropertyOperationChanged const
+	PropertyOperationChanged PropertyOperation = "Changed"
+)
+
+// Message type
+type Message struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver10/schema Message"`
+
+	// Token value pairs that triggered this message. Typically only one item is present.
+	Source ItemList `xml:"http://www.onvif.org/ver10/schema Source,omitempty"`
+
+	Key ItemList `xml:"http://www.onvif.org/ver10/schema Key,omitempty"`
+
+	Data ItemList `xml:"http://www.onvif.org/ver10/schema Data,omitempty"`
+
+	UtcTime string `xml:"UtcTime,attr,omitempty"`
+
+	PropertyOperation PropertyOperation `xml:"PropertyOperation,attr,omitempty"`
+}
+
+// ItemList type
+type ItemList struct {
+
+	// Value name pair as defined by the corresponding description.
+	SimpleItem []SimpleItem `xml:"http://www.onvif.org/ver10/schema SimpleItem,omitempty"`
+
+	// Complex value structure.
+	ElementItem []ElementItem `xml:"http://www.onvif.org/ver10/schema ElementItem,omitempty"`
+}
+
+// SimpleItem type
+type SimpleItem struct {
+
+	// Item name.
+	Name string `xml:"Name,attr"`
+
+	// Item value. The type is defined in the corresponding description.
+	Value string `xml:"Value,attr"`
+}
+
+// ElementItem type
+type ElementItem struct {
+
+	// Item name.
+	Name string `xml:"Name,attr"`
+
+	// XML tree containing the element value as defined in the corresponding description.
+	InnerXML string `xml:",innerxml"`
+}
+
+// Get returns value of the SimpleItem with given name
+func (l *ItemList) Get(name string) (string, bool) {
+	for _, i := range l.SimpleItem {
+		if i.Name == name {
+			return i.Value, true
+		}
+	}
+	return "", false
+}
+
+// Element returns the ElementItem with given name or nil
+func (l *ItemList) Element(name string) *ElementItem {
+	for i := range l.ElementItem {
+		if l.ElementItem[i].Name == name {
+			return &l.ElementItem[i]
+		}
+	}
+	return nil
+}
+
+// Decode unmarshals the element value into v
+func (e *ElementItem) Decode(v interface{}) error {
+	return soap.NewDecoder(strings.NewReader(e.InnerXML)).Decode(v)
+}
+
+// ResolveNamespaces implements soap.NamespaceResolver, namespaces of the prefixes
+// used in the topic are kept in Namespaces
+func (t *Topic) ResolveNamespaces(scope map[string]string) {
+	t.Namespaces = soap.UsedNamespaces(t.Value, scope)
+}
+
+// Canonical returns the topic with the prefixes bound to the ONVIF topic namespace
+// replaced by tns1, e.g. "tns1:VideoSource/MotionAlarm" for "ns1:VideoSource/MotionAlarm"
+func (t *Topic) Canonical() string {
+	segments := strings.Split(strings.TrimSpace(t.Value), "/")
+	for i, segment := range segments {
+		j := strings.IndexByte(segment, ':')
+		if j <= 0 {
+			continue
+		}
+		if ns, ok := t.Namespaces[segment[:j]]; ok && ns == knownNamespaces["tns1"] {
+			segments[i] = "tns1" + segment[j:]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Time parses UtcTime of the message. Time zone is optional, UTC is assumed.
+func (m *Message) Time() (time.Time, error) {
+	return ParseDateTime(m.UtcTime)
+}
+
+// ParseDateTime parses xs:dateTime. Time zone is optional, UTC is assumed.
+func ParseDateTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05.999999999", s, time.UTC)
+}
+
+// Time returns the current device time, zero if the device did not return it
+func (t CurrentTime) Time() time.Time {
+	return time.Time(t)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. Invalid time is left zero,
+// like missing one, devices are known to return local time in various formats.
+func (t *CurrentTime) UnmarshalText(text []byte) error {
+	return unmarshalDateTime((*time.Time)(t), text)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (t CurrentTime) MarshalText() ([]byte, error) {
+	return marshalDateTime(time.Time(t))
+}
+
+// Time returns the termination time in the device clock, zero if the device
+// did not return it
+func (t TerminationTime) Time() time.Time {
+	return time.Time(t)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, see CurrentTime
+func (t *TerminationTime) UnmarshalText(text []byte) error {
+	return unmarshalDateTime((*time.Time)(t), text)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (t TerminationTime) MarshalText() ([]byte, error) {
+	return marshalDateTime(time.Time(t))
+}
+
+func unmarshalDateTime(t *time.Time, text []byte) error {
+	*t = time.Time{}
+	if v, err := ParseDateTime(string(text)); err == nil {
+		*t = v
+	}
+	return nil
+}
+
+func marshalDateTime(t time.Time) ([]byte, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return []byte(t.UTC().Format(time.RFC3339Nano)), nil
+}
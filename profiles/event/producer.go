@@ -0,0 +1,62 @@
+package event
+
+import (
+	"context"
+
+	"github.com/videonext/onvif/soap"
+)
+
+// NotificationProducer type
+type NotificationProducer interface {
+
+	// Error can be either of the following types:
+	//
+	//   - ResourceUnknownFault
+	//   - InvalidFilterFault
+	//   - TopicExpressionDialectUnknownFault
+	//   - InvalidTopicExpressionFault
+	//   - TopicNotSupportedFault
+	//   - InvalidProducerPropertiesExpressionFault
+	//   - InvalidMessageContentExpressionFault
+	//   - UnacceptableInitialTerminationTimeFault
+	//   - UnrecognizedPolicyRequestFault
+	//   - UnsupportedPolicyRequestFault
+	//   - NotifyMessageNotSupportedFault
+	//   - SubscribeCreationFailedFault
+	/* Creates the subscription delivering notifications to the ConsumerReference
+	with Notify messages. The returned SubscriptionReference is the address of
+	the SubscriptionManager used to renew and terminate the subscription. */
+	Subscribe(request *Subscribe) (*SubscribeResponse, error)
+
+	SubscribeContext(ctx context.Context, request *Subscribe) (*SubscribeResponse, error)
+}
+
+// notificationProducer type
+type notificationProducer struct {
+	client *soap.Client
+	xaddr  string
+}
+
+func NewNotificationProducer(client *soap.Client, xaddr string) NotificationProducer {
+	return &notificationProducer{
+		client: client,
+		xaddr:  xaddr,
+	}
+}
+
+func (service *notificationProducer) SubscribeContext(ctx context.Context, request *Subscribe) (*SubscribeResponse, error) {
+	response := new(SubscribeResponse)
+	err := service.client.CallContext(ctx, service.xaddr, "http://docs.oasis-open.org/wsn/bw-2/NotificationProducer/SubscribeRequest", request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (service *notificationProducer) Subscribe(request *Subscribe) (*SubscribeResponse, error) {
+	return service.SubscribeContext(
+		context.Background(),
+		request,
+	)
+}
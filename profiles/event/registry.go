@@ -0,0 +1,148 @@
+package event
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownTopic is returned by Decode for topics without registered decoder
+var ErrUnknownTopic = errors.New("No decoder registered for the topic")
+
+// EventInfo contains the fields common to all typed events
+type EventInfo struct {
+	// Topic of the notification, e.g. "tns1:VideoSource/MotionAlarm"
+	Topic string
+
+	// Time of the message, UtcTime attribute
+	Time time.Time
+
+	PropertyOperation PropertyOperation
+}
+
+// TopicDecoder decodes the notification message into typed event
+type TopicDecoder func(info EventInfo, m *Message) (interface{}, error)
+
+// Registry maps topics to the decoders of typed events.
+// Topic patterns are either exact topics or prefixes ending with "/*"
+// matching all subtopics, e.g. "tns1:Door/State/*". Exact topics take
+// precedence, then the longest prefix.
+type Registry struct {
+	mu       sync.RWMutex
+	decoders map[string]TopicDecoder
+}
+
+// DefaultRegistry contains decoders of the standard topics, used by Decode
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates Registry with decoders of the standard ONVIF topics
+func NewRegistry() *Registry {
+	r := &Registry{decoders: map[string]TopicDecoder{}}
+	for pattern, d := range standardDecoders {
+		r.decoders[pattern] = d
+	}
+	return r
+}
+
+// Register adds decoder for the topic pattern, e.g. vendor topic
+// "tnsaxis:CameraApplicationPlatform/VMD/*". Existing decoder is replaced.
+func (r *Registry) Register(pattern string, d TopicDecoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decoders[pattern] = d
+}
+
+// Lookup returns decoder of the topic or nil
+func (r *Registry) Lookup(topic string) TopicDecoder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if d, ok := r.decoders[topic]; ok {
+		return d
+	}
+	for prefix := topic; ; {
+		i := strings.LastIndex(prefix, "/")
+		if i < 0 {
+			return nil
+		}
+		prefix = prefix[:i]
+		if d, ok := r.decoders[prefix+"/*"]; ok {
+			return d
+		}
+	}
+}
+
+// Decode decodes the notification into typed event, e.g. *MotionAlarm
+func (r *Registry) Decode(n *NotificationMessage) (interface{}, error) {
+	topic := n.Topic.Canonical()
+	d := r.Lookup(topic)
+	if d == nil {
+		return nil, ErrUnknownTopic
+	}
+
+	m := &n.Message.Message
+	info := EventInfo{Topic: topic, PropertyOperation: m.PropertyOperation}
+	if m.UtcTime != "" {
+		t, err := m.Time()
+		if err != nil {
+			return nil, err
+		}
+		info.Time = t
+	}
+
+	e, err := d(info, m)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Decode decodes the notification with DefaultRegistry
+func Decode(n *NotificationMessage) (interface{}, error) {
+	return DefaultRegistry.Decode(n)
+}
+
+// itemReader reads items of the message remembering the first error
+type itemReader struct {
+	m   *Message
+	err error
+}
+
+// source returns value of the Source item
+func (r *itemReader) source(name string) string {
+	return r.required(&r.m.Source, "Source", name)
+}
+
+// data returns value of the Data item
+func (r *itemReader) data(name string) string {
+	return r.required(&r.m.Data, "Data", name)
+}
+
+// optional returns value of the Data item if present
+func (r *itemReader) optional(name string) string {
+	v, _ := r.m.Data.Get(name)
+	return v
+}
+
+// bool returns boolean value of the Data item
+func (r *itemReader) bool(name string) bool {
+	v := r.data(name)
+	if r.err != nil {
+		return false
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		r.err = errors.New("Invalid boolean value of " + name + ": " + v)
+	}
+	return b
+}
+
+func (r *itemReader) required(l *ItemList, list, name string) string {
+	v, ok := l.Get(name)
+	if !ok && r.err == nil {
+		r.err = errors.New("Message has no " + list + " item " + name)
+	}
+	return v
+}
@@ -0,0 +1,66 @@
+package event
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/videonext/onvif/soap"
+)
+
+func TestDecodeTopicPrefix(t *testing.T) {
+	const message = `<wsnt:Message><tt:Message UtcTime="2024-01-01T00:00:00Z">` +
+		`<tt:Source><tt:SimpleItem Name="Source" Value="vs1"/></tt:Source>` +
+		`<tt:Data><tt:SimpleItem Name="State" Value="true"/></tt:Data>` +
+		`</tt:Message></wsnt:Message>`
+
+	tests := []struct {
+		name   string
+		notify string
+		topic  string
+	}{
+		{
+			"tns1 prefix",
+			`<wsnt:Notify xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2" xmlns:tt="http://www.onvif.org/ver10/schema" xmlns:tns1="http://www.onvif.org/ver10/topics">` +
+				`<wsnt:NotificationMessage><wsnt:Topic>tns1:VideoSource/MotionAlarm</wsnt:Topic>` + message + `</wsnt:NotificationMessage></wsnt:Notify>`,
+			"tns1:VideoSource/MotionAlarm",
+		},
+		{
+			"other prefix of the envelope",
+			`<wsnt:Notify xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2" xmlns:tt="http://www.onvif.org/ver10/schema" xmlns:ns1="http://www.onvif.org/ver10/topics">` +
+				`<wsnt:NotificationMessage><wsnt:Topic>ns1:VideoSource/MotionAlarm</wsnt:Topic>` + message + `</wsnt:NotificationMessage></wsnt:Notify>`,
+			"tns1:VideoSource/MotionAlarm",
+		},
+		{
+			"prefix declared on the topic",
+			`<wsnt:Notify xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2" xmlns:tt="http://www.onvif.org/ver10/schema">` +
+				`<wsnt:NotificationMessage><wsnt:Topic xmlns:onvif="http://www.onvif.org/ver10/topics">onvif:VideoSource/MotionAlarm</wsnt:Topic>` + message + `</wsnt:NotificationMessage></wsnt:Notify>`,
+			"tns1:VideoSource/MotionAlarm",
+		},
+	}
+
+	for _, test := range tests {
+		n := Notify{}
+		if err := soap.NewDecoder(strings.NewReader(test.notify)).Decode(&n); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(n.NotificationMessage) != 1 {
+			t.Errorf("%s: %d messages", test.name, len(n.NotificationMessage))
+			continue
+		}
+		m := &n.NotificationMessage[0]
+		if topic := m.Topic.Canonical(); topic != test.topic {
+			t.Errorf("%s: topic %q, expected %q", test.name, topic, test.topic)
+		}
+
+		e, err := Decode(m)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		alarm, ok := e.(*MotionAlarm)
+		if !ok || alarm.VideoSourceToken != "vs1" || !alarm.State {
+			t.Errorf("%s: decoded %+v", test.name, e)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package event
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// actionHeader matches the WS-Addressing Action header of the request
+var actionHeader = regexp.MustCompile(`<Action xmlns="http://www.w3.org/2005/08/addressing"[^>]*>([^<]*)</Action>`)
+
+// eventService is the stand-in of the event service and its pull point, replying
+// to the operations with the bodies of the responses. The caller closes it.
+type eventService struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses map[string]string
+
+	// SOAP actions of the requests and WS-Addressing actions, empty without the header
+	actions   []string
+	addressed []string
+}
+
+func newEventService(responses map[string]string) *eventService {
+	s := &eventService{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *eventService) serve(w http.ResponseWriter, r *http.Request) {
+	action := strings.Trim(r.Header.Get("Soapaction"), `"`)
+	body, _ := ioutil.ReadAll(r.Body)
+	addressed := ""
+	if m := actionHeader.FindSubmatch(body); m != nil {
+		addressed = string(m[1])
+	}
+
+	operation := action[strings.LastIndex(action, "/")+1:]
+	operation = strings.TrimSuffix(operation, "Request")
+
+	s.mu.Lock()
+	s.actions = append(s.actions, action)
+	s.addressed = append(s.addressed, addressed)
+	response, ok := s.responses[operation]
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/soap+xml")
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		response = `<s:Fault><s:Code><s:Value>s:Receiver</s:Value></s:Code><s:Reason><s:Text>failed</s:Text></s:Reason></s:Fault>`
+	}
+	w.Write([]byte(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:tev="http://www.onvif.org/ver10/events/wsdl"` +
+		` xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2" xmlns:wsa="http://www.w3.org/2005/08/addressing"` +
+		` xmlns:tt="http://www.onvif.org/ver10/schema"><s:Body>` + strings.Replace(response, "{{URL}}", s.URL, -1) + `</s:Body></s:Envelope>`))
+}
+
+// mismatched returns the SOAP actions of the requests with other WS-Addressing action
+func (s *eventService) mismatched() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var actions []string
+	for i, action := range s.actions {
+		if s.addressed[i] != "" && s.addressed[i] != action {
+			actions = append(actions, action+" with header "+s.addressed[i])
+		}
+	}
+	return actions
+}
+
+// requested reports if the request with the action was received
+func (s *eventService) requested(action string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package event
+
+import (
+	"strings"
+)
+
+// Decoders of the standard topics of the ONVIF core and access control specifications
+var standardDecoders = map[string]TopicDecoder{
+	"tns1:VideoSource/MotionAlarm":              decodeMotionAlarm,
+	"tns1:RuleEngine/CellMotionDetector/Motion": decodeCellMotion,
+	"tns1:VideoSource/GlobalSceneChange/*":      decodeGlobalSceneChange,
+	"tns1:Device/Trigger/DigitalInput":          decodeDigitalInput,
+	"tns1:Device/Trigger/Relay":                 decodeRelay,
+	"tns1:RecordingConfig/*":                    decodeRecordingConfig,
+	"tns1:AccessControl/*":                      decodeAccessControl,
+	"tns1:Door/State/*":                         decodeDoorState,
+}
+
+// MotionAlarm is the tns1:VideoSource/MotionAlarm event
+type MotionAlarm struct {
+	EventInfo
+
+	VideoSourceToken string
+
+	State bool
+}
+
+// CellMotion is the tns1:RuleEngine/CellMotionDetector/Motion event
+type CellMotion struct {
+	EventInfo
+
+	VideoSourceConfigurationToken    string
+	VideoAnalyticsConfigurationToken string
+	Rule                             string
+
+	IsMotion bool
+}
+
+// GlobalSceneChange is the tns1:VideoSource/GlobalSceneChange/* event,
+// e.g. camera tampering detected by the imaging service
+type GlobalSceneChange struct {
+	EventInfo
+
+	// Service detecting the change, e.g. "ImagingService" or "AnalyticsService"
+	Service string
+
+	VideoSourceToken string
+
+	State bool
+}
+
+// DigitalInput is the tns1:Device/Trigger/DigitalInput event
+type DigitalInput struct {
+	EventInfo
+
+	InputToken string
+
+	LogicalState bool
+}
+
+// Relay is the tns1:Device/Trigger/Relay event
+type Relay struct {
+	EventInfo
+
+	RelayToken string
+
+	// LogicalState is "active" or "inactive"
+	LogicalState string
+}
+
+// Active reports if the relay is in the active state
+func (r *Relay) Active() bool {
+	return r.LogicalState == "active"
+}
+
+// RecordingConfig is the tns1:RecordingConfig/* event. Only the tokens
+// carried by the topic are set, e.g. RecordingJobToken and State for JobState.
+type RecordingConfig struct {
+	EventInfo
+
+	// Subtopic, e.g. "JobState", "CreateRecording" or "TrackConfiguration"
+	Kind string
+
+	RecordingToken    string
+	TrackToken        string
+	RecordingJobToken string
+
+	// Job state of the JobState event, e.g. "Active" or "Idle"
+	State string
+}
+
+// AccessControl is the tns1:AccessControl/* event of the access control service
+type AccessControl struct {
+	EventInfo
+
+	// Subtopic, e.g. "AccessGranted/Credential", "Denied/Anonymous" or "AccessPoint/State/Enabled"
+	Kind string
+
+	AccessPointToken string
+
+	CredentialToken      string
+	CredentialHolderName string
+	Card                 string
+	Reason               string
+
+	// State of the AccessPoint/State/Enabled event
+	Enabled bool
+}
+
+// DoorState is the tns1:Door/State/* event of the door control service
+type DoorState struct {
+	EventInfo
+
+	// Subtopic, e.g. "DoorPhysicalState", "DoorMode" or "DoorAlarm"
+	Kind string
+
+	DoorToken string
+
+	// State value, e.g. "Open", "Locked" or "DoorForcedOpen"
+	State string
+}
+
+func decodeMotionAlarm(info EventInfo, m *Message) (interface{}, error) {
+	r := itemReader{m: m}
+	e := &MotionAlarm{
+		EventInfo:        info,
+		VideoSourceToken: r.source("Source"),
+		State:            r.bool("State"),
+	}
+	return e, r.err
+}
+
+func decodeCellMotion(info EventInfo, m *Message) (interface{}, error) {
+	r := itemReader{m: m}
+	e := &CellMotion{
+		EventInfo:                        info,
+		VideoSourceConfigurationToken:    r.source("VideoSourceConfigurationToken"),
+		VideoAnalyticsConfigurationToken: r.source("VideoAnalyticsConfigurationToken"),
+		Rule:                             r.source("Rule"),
+		IsMotion:                         r.bool("IsMotion"),
+	}
+	return e, r.err
+}
+
+func decodeGlobalSceneChange(info EventInfo, m *Message) (interface{}, error) {
+	r := itemReader{m: m}
+	e := &GlobalSceneChange{
+		EventInfo:        info,
+		Service:          subtopic(info.Topic, "tns1:VideoSource/GlobalSceneChange/"),
+		VideoSourceToken: r.source("Source"),
+		State:            r.bool("State"),
+	}
+	return e, r.err
+}
+
+func decodeDigitalInput(info EventInfo, m *Message) (interface{}, error) {
+	r := itemReader{m: m}
+	e := &DigitalInput{
+		EventInfo:    info,
+		InputToken:   r.source("InputToken"),
+		LogicalState: r.bool("LogicalState"),
+	}
+	return e, r.err
+}
+
+func decodeRelay(info EventInfo, m *Message) (interface{}, error) {
+	r := itemReader{m: m}
+	e := &Relay{
+		EventInfo:    info,
+		RelayToken:   r.source("RelayToken"),
+		LogicalState: strings.TrimSpace(r.data("LogicalState")),
+	}
+	return e, r.err
+}
+
+func decodeRecordingConfig(info EventInfo, m *Message) (interface{}, error) {
+	e := &RecordingConfig{
+		EventInfo: info,
+		Kind:      subtopic(info.Topic, "tns1:RecordingConfig/"),
+	}
+	e.RecordingToken, _ = m.Source.Get("RecordingToken")
+	e.TrackToken, _ = m.Source.Get("TrackToken")
+	e.RecordingJobToken, _ = m.Source.Get("RecordingJobToken")
+	e.State, _ = m.Data.Get("State")
+	return e, nil
+}
+
+func decodeAccessControl(info EventInfo, m *Message) (interface{}, error) {
+	r := itemReader{m: m}
+	e := &AccessControl{
+		EventInfo:            info,
+		Kind:                 subtopic(info.Topic, "tns1:AccessControl/"),
+		AccessPointToken:     r.source("AccessPointToken"),
+		CredentialToken:      r.optional("CredentialToken"),
+		CredentialHolderName: r.optional("CredentialHolderName"),
+		Card:                 r.optional("Card"),
+		Reason:               r.optional("Reason"),
+	}
+	if e.Kind == "AccessPoint/State/Enabled" {
+		e.Enabled = r.bool("State")
+	}
+	return e, r.err
+}
+
+func decodeDoorState(info EventInfo, m *Message) (interface{}, error) {
+	r := itemReader{m: m}
+	e := &DoorState{
+		EventInfo: info,
+		Kind:      subtopic(info.Topic, "tns1:Door/State/"),
+		DoorToken: r.source("DoorToken"),
+		State:     r.data("State"),
+	}
+	return e, r.err
+}
+
+// subtopic returns the topic part after the prefix
+func subtopic(topic, prefix string) string {
+	return strings.TrimPrefix(topic, prefix)
+}
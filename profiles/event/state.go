@@ -0,0 +1,219 @@
+package event
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PropertyKey identifies the property by topic and Source items
+type PropertyKey struct {
+	Topic string
+
+	// Source items formatted as "Name=Value" sorted by name and joined with ",",
+	// e.g. "InputToken=DigitalInput_1"
+	Source string
+}
+
+// PropertyState is the current state of the property
+type PropertyState struct {
+	PropertyKey
+
+	SourceItems []SimpleItem
+	Data        ItemList
+
+	// Time of the last message
+	Time time.Time
+
+	// Operation of the last message, Initialized or Changed
+	Operation PropertyOperation
+}
+
+// Get returns value of the Data SimpleItem with given name
+func (p *PropertyState) Get(name string) (string, bool) {
+	return p.Data.Get(name)
+}
+
+// StateChange is the change of the property state.
+// Old is nil for new properties and New is nil for deleted ones.
+type StateChange struct {
+	PropertyKey
+
+	Old *PropertyState
+	New *PropertyState
+}
+
+// StateStore keeps current state of the properties, like DigitalInput LogicalState
+// or DoorPhysicalState, updated with property notifications. Subscribers should be
+// created WithSynchronizationPoint to receive current state on every (re)subscribe.
+type StateStore struct {
+	// delivery serializes the updates with the notification of the watchers,
+	// so the changes are received in the order of the state updates
+	delivery sync.Mutex
+
+	mu       sync.Mutex
+	states   map[PropertyKey]*PropertyState
+	watchers map[*stateWatcher]struct{}
+}
+
+type stateWatcher struct {
+	changes chan StateChange
+	done    chan struct{}
+}
+
+// NewStateStore creates StateStore instance
+func NewStateStore() *StateStore {
+	return &StateStore{
+		states:   map[PropertyKey]*PropertyState{},
+		watchers: map[*stateWatcher]struct{}{},
+	}
+}
+
+// Update applies the notification. Messages without PropertyOperation and Changed
+// messages older than the current state of the property are ignored. It reports if the
+// state was changed, repeated Initialized messages with the same data update the time only.
+func (s *StateStore) Update(n *NotificationMessage) bool {
+	m := &n.Message.Message
+	if m.PropertyOperation == "" {
+		return false
+	}
+
+	key := PropertyKey{Topic: n.Topic.Canonical(), Source: sourceKey(m.Source.SimpleItem)}
+	t, err := m.Time()
+	if err != nil {
+		t = time.Now()
+	}
+
+	s.delivery.Lock()
+	defer s.delivery.Unlock()
+
+	s.mu.Lock()
+	old := s.states[key]
+	if old != nil && m.PropertyOperation == PropertyOperationChanged && t.Before(old.Time) {
+		// reordered by the device or delivered late. Initialized and Deleted are
+		// applied anyway, the clock may be set back after reboot or correction.
+		s.mu.Unlock()
+		return false
+	}
+	var state *PropertyState
+	if m.PropertyOperation == PropertyOperationDeleted {
+		if old == nil {
+			s.mu.Unlock()
+			return false
+		}
+		delete(s.states, key)
+	} else {
+		state = &PropertyState{
+			PropertyKey: key,
+			SourceItems: m.Source.SimpleItem,
+			Data:        m.Data,
+			Time:        t,
+			Operation:   m.PropertyOperation,
+		}
+		s.states[key] = state
+		if old != nil && sameItems(&old.Data, &state.Data) {
+			s.mu.Unlock()
+			return false
+		}
+	}
+
+	watchers := make([]*stateWatcher, 0, len(s.watchers))
+	for w := range s.watchers {
+		watchers = append(watchers, w)
+	}
+	s.mu.Unlock()
+
+	// Sent outside of the state lock, watchers have to keep up with the updates
+	change := StateChange{PropertyKey: key, Old: old, New: state}
+	for _, w := range watchers {
+		select {
+		case w.changes <- change:
+		case <-w.done:
+		}
+	}
+	return true
+}
+
+// Get returns current state of the property
+func (s *StateStore) Get(key PropertyKey) (PropertyState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state, ok := s.states[key]; ok {
+		return *state, true
+	}
+	return PropertyState{}, false
+}
+
+// Snapshot returns current state of all properties sorted by topic and source
+func (s *StateStore) Snapshot() []PropertyState {
+	s.mu.Lock()
+	states := make([]PropertyState, 0, len(s.states))
+	for _, state := range s.states {
+		states = append(states, *state)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Topic != states[j].Topic {
+			return states[i].Topic < states[j].Topic
+		}
+		return states[i].Source < states[j].Source
+	})
+	return states
+}
+
+// Watch returns the stream of state changes and the function to stop watching.
+// Changes are sent in the order of the updates. Update blocks until the change
+// is received, so the channel has to be drained.
+func (s *StateStore) Watch(buffer int) (<-chan StateChange, func()) {
+	w := &stateWatcher{changes: make(chan StateChange, buffer), done: make(chan struct{})}
+
+	s.mu.Lock()
+	s.watchers[w] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return w.changes, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.watchers, w)
+			s.mu.Unlock()
+			close(w.done)
+		})
+	}
+}
+
+// Reset removes all states, e.g. when the device is replaced
+func (s *StateStore) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = map[PropertyKey]*PropertyState{}
+}
+
+// sourceKey formats Source items as key
+func sourceKey(items []SimpleItem) string {
+	parts := make([]string, 0, len(items))
+	for _, i := range items {
+		parts = append(parts, i.Name+"="+i.Value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// sameItems reports if the item lists have the same items in any order
+func sameItems(a, b *ItemList) bool {
+	if len(a.SimpleItem) != len(b.SimpleItem) || len(a.ElementItem) != len(b.ElementItem) {
+		return false
+	}
+	if sourceKey(a.SimpleItem) != sourceKey(b.SimpleItem) {
+		return false
+	}
+	for i := range a.ElementItem {
+		if a.ElementItem[i] != b.ElementItem[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package event
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// propertyMessage returns the message of the DigitalInput property
+func propertyMessage(operation PropertyOperation, utcTime, state string) *NotificationMessage {
+	n := &NotificationMessage{}
+	n.Topic.Value = "tns1:Device/Trigger/DigitalInput"
+	m := &n.Message.Message
+	m.UtcTime = utcTime
+	m.PropertyOperation = operation
+	m.Source.SimpleItem = []SimpleItem{{Name: "InputToken", Value: "DigitalInput_1"}}
+	m.Data.SimpleItem = []SimpleItem{{Name: "LogicalState", Value: state}}
+	return n
+}
+
+func TestStateStoreOrder(t *testing.T) {
+	s := NewStateStore()
+	key := PropertyKey{Topic: "tns1:Device/Trigger/DigitalInput", Source: "InputToken=DigitalInput_1"}
+
+	tests := []struct {
+		name      string
+		message   *NotificationMessage
+		changed   bool
+		state     string
+		operation PropertyOperation
+	}{
+		{"initialized", propertyMessage(PropertyOperationInitialized, "2024-01-01T00:00:10Z", "false"), true, "false", PropertyOperationInitialized},
+		{"changed", propertyMessage(PropertyOperationChanged, "2024-01-01T00:00:20Z", "true"), true, "true", PropertyOperationChanged},
+		{"older change", propertyMessage(PropertyOperationChanged, "2024-01-01T00:00:15Z", "false"), false, "true", PropertyOperationChanged},
+		{"same time", propertyMessage(PropertyOperationChanged, "2024-01-01T00:00:20Z", "false"), true, "false", PropertyOperationChanged},
+		// the clock of the device was set back, e.g. after reboot
+		{"older initialized", propertyMessage(PropertyOperationInitialized, "2024-01-01T00:00:05Z", "true"), true, "true", PropertyOperationInitialized},
+		{"change after initialized", propertyMessage(PropertyOperationChanged, "2024-01-01T00:00:06Z", "false"), true, "false", PropertyOperationChanged},
+		{"later change", propertyMessage(PropertyOperationChanged, "2024-01-01T00:00:15Z", "true"), true, "true", PropertyOperationChanged},
+	}
+	for _, test := range tests {
+		if changed := s.Update(test.message); changed != test.changed {
+			t.Errorf("%s: changed %v, expected %v", test.name, changed, test.changed)
+		}
+		state, ok := s.Get(key)
+		if !ok {
+			t.Fatalf("%s: no state", test.name)
+		}
+		if v, _ := state.Get("LogicalState"); v != test.state || state.Operation != test.operation {
+			t.Errorf("%s: state %s %s, expected %s %s", test.name, v, state.Operation, test.state, test.operation)
+		}
+	}
+
+	// deletion is applied regardless of the time
+	if !s.Update(propertyMessage(PropertyOperationDeleted, "2024-01-01T00:00:01Z", "")) {
+		t.Error("not deleted")
+	}
+	if _, ok := s.Get(key); ok {
+		t.Error("state not deleted")
+	}
+}
+
+func TestStateStoreWatchOrder(t *testing.T) {
+	s := NewStateStore()
+	changes, stop := s.Watch(0)
+	defer stop()
+
+	const updates = 1000
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// same time, every update changes the state
+			s.Update(propertyMessage(PropertyOperationChanged, "2024-01-01T00:00:00Z", strconv.Itoa(i)))
+		}(i)
+	}
+
+	// the changes are chained in the order of the updates
+	var last *PropertyState
+	for i := 0; i < updates; i++ {
+		change := <-changes
+		if change.Old != last {
+			t.Fatalf("change %d follows other state", i)
+		}
+		last = change.New
+	}
+	wg.Wait()
+
+	state, _ := s.Get(last.PropertyKey)
+	if v, _ := state.Get("LogicalState"); v != last.Data.SimpleItem[0].Value {
+		t.Errorf("last change %s, state %s", last.Data.SimpleItem[0].Value, v)
+	}
+}
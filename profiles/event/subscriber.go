@@ -0,0 +1,534 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/videonext/onvif/soap"
+)
+
+var (
+	errSubscriberStarted    = errors.New("Subscriber is already started")
+	errSubscriberNotStarted = errors.New("Subscriber is not started")
+)
+
+// Number of consecutive failed requests after which the subscription is recreated
+const maxSubscriptionErrors = 3
+
+// SubscriberState is the state of the Subscriber
+type SubscriberState string
+
+const (
+	// SubscriberStateStopped means the subscriber is not started
+	SubscriberStateStopped SubscriberState = "Stopped"
+
+	// SubscriberStateSubscribing means the pull point is being created
+	SubscriberStateSubscribing SubscriberState = "Subscribing"
+
+	// SubscriberStateActive means the subscriber is pulling messages
+	SubscriberStateActive SubscriberState = "Active"
+
+	// SubscriberStateBackoff means the subscriber waits before the next attempt after the error
+	SubscriberStateBackoff SubscriberState = "Backoff"
+)
+
+// SubscriberStatus describes the Subscriber for health checks
+type SubscriberStatus struct {
+	State SubscriberState
+
+	// Address of the current subscription manager
+	SubscriptionAddress string
+
+	// Termination time of the current subscription, local clock
+	TerminationTime time.Time
+
+	// Time of the last received notification
+	LastMessage time.Time
+
+	// Last error, reset when the subscription is created
+	LastError error
+
+	// Number of times the subscription was recreated
+	Reconnects int
+
+	// Number of received notifications
+	Messages uint64
+}
+
+type subscriberOptions struct {
+	soapOptions     []soap.Option
+	username        string
+	password        string
+	filter          *FilterType
+	terminationTime time.Duration
+	pullTimeout     time.Duration
+	messageLimit    int32
+	minBackoff      time.Duration
+	maxBackoff      time.Duration
+	bufferSize      int
+	synchronize     bool
+	deduplicator    *Deduplicator
+}
+
+var defaultSubscriberOptions = subscriberOptions{
+	terminationTime: time.Minute,
+	pullTimeout:     10 * time.Second,
+	messageLimit:    100,
+	minBackoff:      time.Second,
+	maxBackoff:      time.Minute,
+	bufferSize:      100,
+}
+
+// A SubscriberOption sets options such as credentials, filter, timeouts, etc.
+type SubscriberOption func(*subscriberOptions)
+
+// WithSOAPOptions is a SubscriberOption to set options of the soap clients
+func WithSOAPOptions(opt ...soap.Option) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.soapOptions = append(o.soapOptions, opt...)
+	}
+}
+
+// WithCredentials is a SubscriberOption to authenticate requests with WS-Security UsernameToken
+func WithCredentials(username, password string) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.username = username
+		o.password = password
+	}
+}
+
+// WithFilter is a SubscriberOption to set the subscription filter, see FilterBuilder
+func WithFilter(filter *FilterType) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.filter = filter
+	}
+}
+
+// WithTerminationTime is a SubscriberOption to set the subscription lifetime requested
+// on create and renew. Default is one minute.
+func WithTerminationTime(d time.Duration) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.terminationTime = d
+	}
+}
+
+// WithPullTimeout is a SubscriberOption to set the PullMessages timeout. Default is 10 seconds.
+func WithPullTimeout(d time.Duration) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.pullTimeout = d
+	}
+}
+
+// WithMessageLimit is a SubscriberOption to set the PullMessages message limit. Default is 100.
+func WithMessageLimit(n int32) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.messageLimit = n
+	}
+}
+
+// WithBackoff is a SubscriberOption to set the delays between attempts after errors.
+// The delay starts with min and doubles up to max. Default is one second to one minute.
+func WithBackoff(min, max time.Duration) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// WithBufferSize is a SubscriberOption to set the capacity of the notifications channel
+func WithBufferSize(n int) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.bufferSize = n
+	}
+}
+
+// WithSynchronizationPoint is a SubscriberOption to request SetSynchronizationPoint
+// every time the subscription is created, so the device repeats current state
+// of all properties with Initialized messages, see StateStore
+func WithSynchronizationPoint() SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.synchronize = true
+	}
+}
+
+// WithDeduplicator is a SubscriberOption to drop notifications seen before,
+// e.g. when the same Deduplicator is used to read the history with ReadHistory
+func WithDeduplicator(d *Deduplicator) SubscriberOption {
+	return func(o *subscriberOptions) {
+		o.deduplicator = d
+	}
+}
+
+// Subscriber is a long-running pull point subscription. It creates the subscription,
+// pulls messages, renews the subscription before it terminates and recreates it
+// after device reboot, ResourceUnknown faults or repeated errors.
+type Subscriber struct {
+	xaddr string
+	opts  subscriberOptions
+
+	mu            sync.Mutex
+	status        SubscriberStatus
+	notifications chan NotificationMessage
+	cancel        context.CancelFunc
+	done          chan struct{}
+}
+
+// subscription is the subscription created on the device
+type subscription struct {
+	reference EndpointReferenceType
+
+	// termination time, local clock
+	terminationTime time.Time
+
+	// device clock minus local clock
+	clockOffset time.Duration
+}
+
+// NewSubscriber creates Subscriber instance. The xaddr is the event service address.
+func NewSubscriber(xaddr string, opt ...SubscriberOption) *Subscriber {
+	opts := defaultSubscriberOptions
+	for _, o := range opt {
+		o(&opts)
+	}
+	return &Subscriber{
+		xaddr:  xaddr,
+		opts:   opts,
+		status: SubscriberStatus{State: SubscriberStateStopped},
+	}
+}
+
+// Start starts the subscriber
+func (s *Subscriber) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return errSubscriberStarted
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.notifications = make(chan NotificationMessage, s.opts.bufferSize)
+
+	go s.run(ctx, s.notifications, s.done)
+
+	return nil
+}
+
+// Stop unsubscribes and stops the subscriber. The notifications channel is closed.
+func (s *Subscriber) Stop() error {
+	s.mu.Lock()
+	if s.cancel == nil {
+		s.mu.Unlock()
+		return errSubscriberNotStarted
+	}
+
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+
+	cancel()
+	<-done
+
+	return nil
+}
+
+// Notifications returns the channel of received notifications.
+// The channel is created by Start and closed by Stop.
+func (s *Subscriber) Notifications() <-chan NotificationMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notifications
+}
+
+// Status returns current status
+func (s *Subscriber) Status() SubscriberStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *Subscriber) run(ctx context.Context, notifications chan NotificationMessage, done chan struct{}) {
+	defer close(done)
+	defer close(notifications)
+
+	backoff := s.opts.minBackoff
+	subscribed := false
+	for {
+		s.setState(SubscriberStateSubscribing)
+
+		pp, err := s.subscribe(ctx)
+		if err == nil {
+			backoff = s.opts.minBackoff
+			s.mu.Lock()
+			if subscribed {
+				s.status.Reconnects++
+			}
+			s.status.LastError = nil
+			s.mu.Unlock()
+			subscribed = true
+
+			if err := s.opts.synchronizationPoint(ctx, pp); err != nil {
+				s.mu.Lock()
+				s.status.LastError = err
+				s.mu.Unlock()
+			}
+
+			err = s.pull(ctx, pp, notifications)
+
+			// Subscription may still exist on the device
+			if ctx.Err() != nil || !isResourceUnknown(err) {
+				s.opts.unsubscribe(pp)
+			}
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		s.mu.Lock()
+		s.status.State = SubscriberStateBackoff
+		s.status.SubscriptionAddress = ""
+		s.status.TerminationTime = time.Time{}
+		s.status.LastError = err
+		s.mu.Unlock()
+
+		if !sleep(ctx, backoff) {
+			break
+		}
+		if backoff *= 2; backoff > s.opts.maxBackoff {
+			backoff = s.opts.maxBackoff
+		}
+	}
+
+	s.mu.Lock()
+	s.status.State = SubscriberStateStopped
+	s.status.SubscriptionAddress = ""
+	s.status.TerminationTime = time.Time{}
+	s.mu.Unlock()
+}
+
+// subscribe creates pull point subscription
+func (s *Subscriber) subscribe(ctx context.Context) (*subscription, error) {
+	request := &CreatePullPointSubscription{
+		Filter:                 s.opts.filter,
+		InitialTerminationTime: NewRelativeTime(s.opts.terminationTime),
+	}
+
+	reply, err := NewEventPortType(s.opts.newClient(0), s.xaddr).CreatePullPointSubscriptionContext(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if reply.SubscriptionReference.Address.Value == "" {
+		return nil, errors.New("CreatePullPointSubscription returned empty subscription address")
+	}
+
+	pp := &subscription{reference: reply.SubscriptionReference}
+	pp.update(reply.CurrentTime, reply.TerminationTime, s.opts.terminationTime)
+
+	s.mu.Lock()
+	s.status.State = SubscriberStateActive
+	s.status.SubscriptionAddress = string(pp.reference.Address.Value)
+	s.status.TerminationTime = pp.terminationTime
+	s.mu.Unlock()
+
+	return pp, nil
+}
+
+// pull pulls messages until the context is cancelled or the subscription is lost
+func (s *Subscriber) pull(ctx context.Context, pp *subscription, notifications chan NotificationMessage) error {
+	failures := 0
+	fail := func(err error) error {
+		s.mu.Lock()
+		s.status.LastError = err
+		s.mu.Unlock()
+
+		if failures++; failures >= maxSubscriptionErrors || isResourceUnknown(err) {
+			return err
+		}
+		return nil
+	}
+
+	for ctx.Err() == nil {
+		// Renew when the subscription may terminate before the next pull returns
+		if time.Until(pp.terminationTime) < 2*s.opts.pullTimeout {
+			err := s.opts.renew(ctx, pp)
+			if err != nil && ctx.Err() == nil {
+				if err = fail(err); err != nil {
+					return err
+				}
+			}
+
+			s.mu.Lock()
+			s.status.TerminationTime = pp.terminationTime
+			s.mu.Unlock()
+		}
+
+		request := &PullMessages{
+			Timeout:      Duration(FormatDuration(s.opts.pullTimeout)),
+			MessageLimit: s.opts.messageLimit,
+		}
+		reply := &PullMessagesResponse{}
+		err := s.opts.newClient(pp.clockOffset, addressingHeaders(ActionPullMessages, &pp.reference)...).
+			CallContext(ctx, string(pp.reference.Address.Value), ActionPullMessages, request, reply)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err = fail(err); err != nil {
+				return err
+			}
+			if !sleep(ctx, s.opts.minBackoff) {
+				return nil
+			}
+			continue
+		}
+		failures = 0
+
+		pp.pulled(reply)
+
+		s.mu.Lock()
+		s.status.TerminationTime = pp.terminationTime
+		if len(reply.NotificationMessage) > 0 {
+			s.status.LastMessage = time.Now()
+			s.status.Messages += uint64(len(reply.NotificationMessage))
+		}
+		s.mu.Unlock()
+
+		for _, m := range reply.NotificationMessage {
+			if s.opts.deduplicator != nil && s.opts.deduplicator.Seen(&m) {
+				continue
+			}
+			select {
+			case notifications <- m:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// renew extends the subscription lifetime
+func (o *subscriberOptions) renew(ctx context.Context, sub *subscription) error {
+	request := &Renew{TerminationTime: NewRelativeTime(o.terminationTime)}
+	reply, err := sub.manager(o, ActionRenew).RenewContext(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	sub.update(reply.CurrentTime, reply.TerminationTime, o.terminationTime)
+	return nil
+}
+
+// synchronizationPoint requests current state of the properties if enabled
+func (o *subscriberOptions) synchronizationPoint(ctx context.Context, sub *subscription) error {
+	if !o.synchronize {
+		return nil
+	}
+
+	// Sent with the action of the WS-Addressing header, the generated port uses other
+	err := o.newClient(sub.clockOffset, addressingHeaders(ActionSetSynchronizationPoint, &sub.reference)...).
+		CallContext(ctx, string(sub.reference.Address.Value), ActionSetSynchronizationPoint, &SetSynchronizationPoint{}, &SetSynchronizationPointResponse{})
+	return decodeFault(err)
+}
+
+// unsubscribe releases the subscription on the device, errors are ignored
+func (o *subscriberOptions) unsubscribe(sub *subscription) {
+	ctx, cancel := context.WithTimeout(context.Background(), o.pullTimeout)
+	defer cancel()
+
+	sub.manager(o, ActionUnsubscribe).UnsubscribeContext(ctx, &Unsubscribe{})
+}
+
+// newClient creates soap client with new UsernameToken and given headers
+func (o *subscriberOptions) newClient(clockOffset time.Duration, headers ...interface{}) *soap.Client {
+	client := soap.NewClient(o.soapOptions...)
+	if o.username != "" {
+		client.AddHeader(soap.NewWSSSecurityHeader(o.username, o.password, time.Now().UTC().Add(clockOffset)))
+	}
+	for _, h := range headers {
+		client.AddHeader(h)
+	}
+	return client
+}
+
+func (s *Subscriber) setState(state SubscriberState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.State = state
+}
+
+// manager returns the subscription manager port for the request with given action
+func (sub *subscription) manager(o *subscriberOptions, action string) SubscriptionManager {
+	client := o.newClient(sub.clockOffset, addressingHeaders(action, &sub.reference)...)
+	return NewSubscriptionManager(client, string(sub.reference.Address.Value))
+}
+
+// update sets termination time from the device response. Device times are
+// converted to the local clock. If the device did not return termination time
+// the requested lifetime is used, zero lifetime keeps the current value.
+func (sub *subscription) update(currentTime CurrentTime, terminationTime TerminationTime, lifetime time.Duration) {
+	now := time.Now()
+
+	if current := currentTime.Time(); !current.IsZero() {
+		sub.clockOffset = current.Sub(now)
+	}
+
+	termination := terminationTime.Time()
+	switch {
+	case !termination.IsZero():
+		sub.terminationTime = termination.Add(-sub.clockOffset)
+	case lifetime > 0:
+		sub.terminationTime = now.Add(lifetime)
+	}
+}
+
+// pulled updates the subscription from the PullMessages response, its times
+// are not typed
+func (sub *subscription) pulled(reply *PullMessagesResponse) {
+	var currentTime CurrentTime
+	var terminationTime TerminationTime
+	currentTime.UnmarshalText([]byte(reply.CurrentTime))
+	terminationTime.UnmarshalText([]byte(reply.TerminationTime))
+	sub.update(currentTime, terminationTime, 0)
+}
+
+// isResourceUnknown reports if the error is the fault returned for unknown subscriptions,
+// e.g. after device reboot
+func isResourceUnknown(err error) bool {
+	fault, ok := err.(*soap.SOAPFault)
+	if !ok {
+		return false
+	}
+	for _, s := range []string{fault.Code.Subcode.Value, fault.Reason.Text, fault.Detail.Text} {
+		if strings.Contains(s, "ResourceUnknown") {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatDuration formats duration as xs:duration in seconds, e.g. PT60S
+func FormatDuration(d time.Duration) string {
+	return fmt.Sprintf("PT%dS", int64(d.Round(time.Second)/time.Second))
+}
+
+// sleep waits for the duration and reports if the context is still active
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
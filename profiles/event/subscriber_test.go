@@ -0,0 +1,26 @@
+package event
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSynchronizationPointAction(t *testing.T) {
+	s := newEventService(testPullPointResponses)
+	defer s.Close()
+
+	subscriber := NewSubscriber(s.URL, WithSynchronizationPoint())
+	sub, err := subscriber.subscribe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := subscriber.opts.synchronizationPoint(context.Background(), sub); err != nil {
+		t.Fatal(err)
+	}
+	if !s.requested(ActionSetSynchronizationPoint) {
+		t.Error("SetSynchronizationPoint not sent with its WS-Addressing action")
+	}
+	if actions := s.mismatched(); len(actions) != 0 {
+		t.Errorf("actions differ from WS-Addressing headers: %v", actions)
+	}
+}
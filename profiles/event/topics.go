@@ -0,0 +1,271 @@
+package event
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/videonext/onvif/soap"
+)
+
+// NsTopics is the WS-Topics namespace
+const NsTopics = "http://docs.oasis-open.org/wsn/t-1"
+
+// NsSchema is the ONVIF schema namespace
+const NsSchema = "http://www.onvif.org/ver10/schema"
+
+// TopicNode is the node of the topic tree parsed from the TopicSet
+type TopicNode struct {
+	// Namespace and local name of the topic element
+	Namespace string
+	Name      string
+
+	// Concrete path of the topic, e.g. "tns1:VideoSource/MotionAlarm"
+	Path string
+
+	// IsTopic is set for nodes marked with wstop:topic, notifications
+	// are produced on such topics
+	IsTopic bool
+
+	// Description of the messages, nil if the device did not provide it
+	Description *MessageDescription
+
+	Parent   *TopicNode
+	Children []*TopicNode
+}
+
+// MessageDescription describes the messages produced on the topic
+type MessageDescription struct {
+	// IsProperty is set for property topics, with messages
+	// carrying Initialized, Changed and Deleted operations
+	IsProperty bool
+
+	Source []ItemDescription
+	Key    []ItemDescription
+	Data   []ItemDescription
+}
+
+// ItemDescription describes SimpleItem or ElementItem of the message
+type ItemDescription struct {
+	Name string
+
+	// Type as written by the device, e.g. "xs:boolean" or "tt:ReferenceToken"
+	Type string
+
+	// Element is set for ElementItem descriptions
+	Element bool
+}
+
+// TopicTree is the topic set of the device
+type TopicTree struct {
+	Topics []*TopicNode
+
+	// Namespaces of the prefixes used in the topic paths
+	Namespaces map[string]string
+}
+
+// ParseTopicSet builds the tree of the TopicSet returned with GetEventProperties.
+// Prefixes tns1 and tt are used for the ONVIF namespaces, other namespaces get
+// generated prefixes listed in TopicTree.Namespaces.
+func ParseTopicSet(ts *TopicSet) *TopicTree {
+	t := &TopicTree{Namespaces: map[string]string{}}
+	for i := range ts.Topics {
+		t.Topics = append(t.Topics, t.parse(&ts.Topics[i], nil))
+	}
+	return t
+}
+
+func (t *TopicTree) parse(e *soap.Element, parent *TopicNode) *TopicNode {
+	topic := &TopicNode{
+		Namespace: e.XMLName.Space,
+		Name:      e.XMLName.Local,
+		Parent:    parent,
+	}
+	if parent == nil {
+		topic.Path = t.prefix(topic.Namespace) + topic.Name
+	} else {
+		topic.Path = parent.Path + "/" + topic.Name
+	}
+
+	for _, a := range e.Attr {
+		if a.Name.Local == "topic" && (a.Name.Space == NsTopics || a.Name.Space == "wstop") {
+			topic.IsTopic = a.Value == "true" || a.Value == "1"
+		}
+	}
+
+	for i := range e.Children {
+		c := &e.Children[i]
+		if c.XMLName.Space == NsSchema && c.XMLName.Local == "MessageDescription" {
+			topic.Description = parseMessageDescription(c)
+			continue
+		}
+		topic.Children = append(topic.Children, t.parse(c, topic))
+	}
+
+	return topic
+}
+
+// prefix returns prefix with colon for the namespace
+func (t *TopicTree) prefix(namespace string) string {
+	if namespace == "" {
+		return ""
+	}
+	for prefix, uri := range t.Namespaces {
+		if uri == namespace {
+			return prefix + ":"
+		}
+	}
+
+	prefix := ""
+	for p, uri := range knownNamespaces {
+		if uri == namespace {
+			prefix = p
+		}
+	}
+	for i := 1; prefix == ""; i++ {
+		if _, ok := t.Namespaces["ns"+strconv.Itoa(i)]; !ok {
+			prefix = "ns" + strconv.Itoa(i)
+		}
+	}
+
+	t.Namespaces[prefix] = namespace
+	return prefix + ":"
+}
+
+func parseMessageDescription(e *soap.Element) *MessageDescription {
+	d := &MessageDescription{}
+	for _, a := range e.Attr {
+		if a.Name.Local == "IsProperty" {
+			d.IsProperty = a.Value == "true" || a.Value == "1"
+		}
+	}
+
+	for i := range e.Children {
+		c := &e.Children[i]
+		switch c.XMLName.Local {
+		case "Source":
+			d.Source = parseItemDescriptions(c)
+		case "Key":
+			d.Key = parseItemDescriptions(c)
+		case "Data":
+			d.Data = parseItemDescriptions(c)
+		}
+	}
+	return d
+}
+
+func parseItemDescriptions(e *soap.Element) []ItemDescription {
+	items := []ItemDescription{}
+	for _, c := range e.Children {
+		item := ItemDescription{Element: c.XMLName.Local == "ElementItemDescription"}
+		if !item.Element && c.XMLName.Local != "SimpleItemDescription" {
+			continue
+		}
+		for _, a := range c.Attr {
+			switch a.Name.Local {
+			case "Name":
+				item.Name = a.Value
+			case "Type":
+				item.Type = a.Value
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// Walk calls fn for every topic, depth first. Children are skipped if fn returns false.
+func (t *TopicTree) Walk(fn func(*TopicNode) bool) {
+	var walk func([]*TopicNode)
+	walk = func(topics []*TopicNode) {
+		for _, topic := range topics {
+			if fn(topic) {
+				walk(topic.Children)
+			}
+		}
+	}
+	walk(t.Topics)
+}
+
+// Find returns topic with the path, e.g. "tns1:VideoSource/MotionAlarm", or nil
+func (t *TopicTree) Find(path string) *TopicNode {
+	var found *TopicNode
+	t.Walk(func(topic *TopicNode) bool {
+		if topic.Path == path {
+			found = topic
+		}
+		return found == nil && strings.HasPrefix(path, topic.Path+"/")
+	})
+	return found
+}
+
+// Search returns topics marked with wstop:topic whose path or item names
+// contain the query, case insensitive
+func (t *TopicTree) Search(query string) []*TopicNode {
+	query = strings.ToLower(query)
+	return t.Select(func(topic *TopicNode) bool {
+		if strings.Contains(strings.ToLower(topic.Path), query) {
+			return true
+		}
+		for _, item := range topic.Items() {
+			if strings.Contains(strings.ToLower(item.Name), query) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Select returns topics marked with wstop:topic matching the predicate
+func (t *TopicTree) Select(fn func(*TopicNode) bool) []*TopicNode {
+	topics := []*TopicNode{}
+	t.Walk(func(topic *TopicNode) bool {
+		if topic.IsTopic && fn(topic) {
+			topics = append(topics, topic)
+		}
+		return true
+	})
+	return topics
+}
+
+// Properties returns property topics
+func (t *TopicTree) Properties() []*TopicNode {
+	return t.Select(func(topic *TopicNode) bool {
+		return topic.Description != nil && topic.Description.IsProperty
+	})
+}
+
+// Filter returns FilterBuilder selecting the topics, with their namespaces declared.
+// Topics with children select the whole subtree.
+func (t *TopicTree) Filter(topics ...*TopicNode) *FilterBuilder {
+	b := NewFilterBuilder()
+	for prefix, uri := range t.Namespaces {
+		b.Namespace(prefix, uri)
+	}
+
+	expressions := []string{}
+	for _, topic := range topics {
+		expressions = append(expressions, topic.Expression())
+	}
+	return b.Topics(expressions...)
+}
+
+// Expression returns ConcreteSet expression of the topic,
+// including descendants if the topic has children
+func (topic *TopicNode) Expression() string {
+	if len(topic.Children) > 0 {
+		return topic.Path + "//."
+	}
+	return topic.Path
+}
+
+// Items returns all item descriptions of the messages
+func (topic *TopicNode) Items() []ItemDescription {
+	if topic.Description == nil {
+		return nil
+	}
+	d := topic.Description
+	items := make([]ItemDescription, 0, len(d.Source)+len(d.Key)+len(d.Data))
+	items = append(items, d.Source...)
+	items = append(items, d.Key...)
+	return append(items, d.Data...)
+}
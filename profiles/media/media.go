@@ -7398,7 +7398,7 @@ type ProfileStatusExtension struct {
 
 // OSDReference type
 type OSDReference struct {
-	Value ReferenceToken
+	Value ReferenceToken `xml:",chardata"`
 }
 
 // OSDPosConfiguration type
@@ -0,0 +1,164 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var errMalformedAACConfig = errors.New("Malformed AAC AudioSpecificConfig")
+
+var aacSampleRates = []int{
+	96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350,
+}
+
+// AACConfig is the AudioSpecificConfig of ISO 14496-3
+type AACConfig struct {
+	// Audio object type, 2 is AAC-LC
+	ObjectType int
+
+	SampleRate int
+	Channels   int
+
+	// Samples per frame, 1024 or 960
+	FrameLength int
+}
+
+// ParseAACConfig parses AudioSpecificConfig
+func ParseAACConfig(b []byte) (*AACConfig, error) {
+	r := &bitReader{data: b}
+	c := &AACConfig{FrameLength: 1024}
+
+	c.ObjectType = int(r.bits(5))
+	if c.ObjectType == 31 {
+		c.ObjectType = 32 + int(r.bits(6))
+	}
+	if index := int(r.bits(4)); index == 15 {
+		c.SampleRate = int(r.bits(24))
+	} else if index < len(aacSampleRates) {
+		c.SampleRate = aacSampleRates[index]
+	}
+	c.Channels = int(r.bits(4))
+
+	// frameLengthFlag of GASpecificConfig
+	if c.ObjectType <= 4 || c.ObjectType == 6 || c.ObjectType == 7 || c.ObjectType == 17 {
+		if r.bit() {
+			c.FrameLength = 960
+		}
+	}
+
+	if r.err != nil || c.SampleRate == 0 {
+		return nil, errMalformedAACConfig
+	}
+	return c, nil
+}
+
+// AACDepacketizer extracts AAC frames of RFC 3640 packets, e.g. AAC-hbr mode
+type AACDepacketizer struct {
+	clock timeline
+	seq   sequence
+
+	// Config is the AudioSpecificConfig, nil if not in the format parameters
+	Config *AACConfig
+
+	sizeLength       int
+	indexLength      int
+	indexDeltaLength int
+	frameLength      uint32
+
+	// fragmented frame
+	fragment  []byte
+	size      int
+	timestamp uint32
+}
+
+// NewAACDepacketizer creates AACDepacketizer of the "mpeg4-generic" format parameters
+func NewAACDepacketizer(clockRate int, fmtp map[string]string) (*AACDepacketizer, error) {
+	d := &AACDepacketizer{frameLength: 1024}
+
+	if config, err := hex.DecodeString(fmtp["config"]); err == nil && len(config) > 0 {
+		if d.Config, err = ParseAACConfig(config); err != nil {
+			return nil, err
+		}
+		d.frameLength = uint32(d.Config.FrameLength)
+		if clockRate <= 0 {
+			clockRate = d.Config.SampleRate
+		}
+	}
+	d.clock = newTimeline(clockRate, 44100)
+
+	if mode := strings.ToLower(fmtp["mode"]); mode != "" && !strings.HasPrefix(mode, "aac-") {
+		return nil, errUnsupportedPacketization
+	}
+	d.sizeLength, _ = strconv.Atoi(fmtp["sizelength"])
+	d.indexLength, _ = strconv.Atoi(fmtp["indexlength"])
+	d.indexDeltaLength, _ = strconv.Atoi(fmtp["indexdeltalength"])
+	if d.sizeLength == 0 {
+		return nil, errUnsupportedPacketization
+	}
+	return d, nil
+}
+
+// Depacketize returns AAC frames of the packet
+func (d *AACDepacketizer) Depacketize(p *Packet) ([]*AccessUnit, error) {
+	var err error
+	if d.seq.lost(p.SequenceNumber) {
+		d.fragment = nil
+		err = errPacketLoss
+	}
+
+	b := p.Payload
+	if len(b) < 2 {
+		return nil, errMalformedPayload
+	}
+	headersLength := int(binary.BigEndian.Uint16(b))
+	n := (headersLength + 7) / 8
+	if len(b) < 2+n {
+		return nil, errMalformedPayload
+	}
+	r := &bitReader{data: b[2 : 2+n]}
+	data := b[2+n:]
+
+	var sizes []int
+	for r.pos+d.sizeLength <= headersLength {
+		sizes = append(sizes, int(r.bits(d.sizeLength)))
+		if len(sizes) == 1 {
+			r.bits(d.indexLength)
+		} else {
+			r.bits(d.indexDeltaLength)
+		}
+	}
+
+	// The frame larger than the packet is fragmented
+	if d.fragment != nil && p.Timestamp != d.timestamp {
+		d.fragment = nil
+	}
+	if len(sizes) == 1 && (sizes[0] > len(data) || d.fragment != nil) {
+		if d.fragment == nil {
+			d.size, d.timestamp = sizes[0], p.Timestamp
+		}
+		d.fragment = append(d.fragment, data...)
+		if len(d.fragment) < d.size {
+			return nil, err
+		}
+		frame := d.fragment[:d.size]
+		d.fragment = nil
+		return []*AccessUnit{d.unit(p.Timestamp, frame)}, err
+	}
+
+	var units []*AccessUnit
+	for i, size := range sizes {
+		if size > len(data) {
+			return units, errMalformedPayload
+		}
+		units = append(units, d.unit(p.Timestamp+uint32(i)*d.frameLength, append([]byte{}, data[:size]...)))
+		data = data[size:]
+	}
+	return units, err
+}
+
+func (d *AACDepacketizer) unit(timestamp uint32, frame []byte) *AccessUnit {
+	return &AccessUnit{Timestamp: timestamp, PTS: d.clock.pts(timestamp), Data: frame, Keyframe: true}
+}
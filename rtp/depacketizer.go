@@ -0,0 +1,217 @@
+package rtp
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	errPacketLoss               = errors.New("RTP packets lost, access unit dropped")
+	errUnsupportedEncoding      = errors.New("Unsupported RTP encoding")
+	errUnsupportedPacketization = errors.New("Unsupported RTP packetization")
+	errMalformedPayload         = errors.New("Malformed RTP payload")
+)
+
+// IsPacketLoss reports if the error returned by Depacketize is caused by lost packets,
+// depacketizing may continue then
+func IsPacketLoss(err error) bool {
+	return err == errPacketLoss
+}
+
+// AccessUnit is the video frame or audio frame
+type AccessUnit struct {
+	// RTP timestamp
+	Timestamp uint32
+
+	// Presentation time relative to the first packet
+	PTS time.Duration
+
+	// NAL units of H.264 and H.265 without start codes
+	NALUs [][]byte
+
+	// JPEG image or audio frame
+	Data []byte
+
+	// Keyframe is true for IDR/IRAP frames, JPEG images and audio.
+	// H.264 and H.265 keyframes start with the parameter sets.
+	Keyframe bool
+}
+
+// Size returns size of the payload in bytes
+func (au *AccessUnit) Size() int {
+	n := len(au.Data)
+	for _, nalu := range au.NALUs {
+		n += len(nalu)
+	}
+	return n
+}
+
+// AnnexB returns NAL units with start codes
+func (au *AccessUnit) AnnexB() []byte {
+	b := make([]byte, 0, au.Size()+4*len(au.NALUs))
+	for _, nalu := range au.NALUs {
+		b = append(b, 0, 0, 0, 1)
+		b = append(b, nalu...)
+	}
+	return b
+}
+
+// Depacketizer assembles access units from RTP packets. Packets are expected
+// in order, a gap in sequence numbers drops the incomplete access unit.
+type Depacketizer interface {
+	// Depacketize returns access units completed by the packet
+	Depacketize(p *Packet) ([]*AccessUnit, error)
+}
+
+// NewDepacketizer creates depacketizer of the encoding, clock rate and format parameters
+// of the SDP media, e.g. "H264", 90000 and "packetization-mode=1;sprop-parameter-sets=..."
+func NewDepacketizer(encoding string, clockRate int, fmtp map[string]string) (Depacketizer, error) {
+	switch strings.ToUpper(encoding) {
+	case "H264":
+		return NewH264Depacketizer(clockRate, fmtp)
+	case "H265":
+		return NewH265Depacketizer(clockRate, fmtp)
+	case "JPEG":
+		return NewJPEGDepacketizer(clockRate), nil
+	case "MPEG4-GENERIC":
+		return NewAACDepacketizer(clockRate, fmtp)
+	case "PCMU", "PCMA":
+		return NewG711Depacketizer(clockRate), nil
+	case "VND.ONVIF.METADATA":
+		return NewMetadataDepacketizer(clockRate), nil
+	}
+	return nil, errUnsupportedEncoding
+}
+
+// sequence detects gaps in sequence numbers
+type sequence struct {
+	started bool
+	last    uint16
+}
+
+func (s *sequence) lost(seq uint16) bool {
+	lost := s.started && seq != s.last+1
+	s.started = true
+	s.last = seq
+	return lost
+}
+
+// timeline converts RTP timestamps to the presentation time, handling wrap around
+type timeline struct {
+	clockRate int64
+	started   bool
+	last      uint32
+	ticks     int64
+}
+
+func newTimeline(clockRate, defaultRate int) timeline {
+	if clockRate <= 0 {
+		clockRate = defaultRate
+	}
+	return timeline{clockRate: int64(clockRate)}
+}
+
+func (t *timeline) pts(timestamp uint32) time.Duration {
+	if !t.started {
+		t.started = true
+		t.last = timestamp
+	}
+	t.ticks += int64(int32(timestamp - t.last))
+	t.last = timestamp
+
+	return time.Duration(t.ticks/t.clockRate)*time.Second +
+		time.Duration(t.ticks%t.clockRate)*time.Second/time.Duration(t.clockRate)
+}
+
+// nalDepacketizer assembles NAL units of the same timestamp into access units
+type nalDepacketizer struct {
+	clock     timeline
+	seq       sequence
+	timestamp uint32
+	nalus     [][]byte
+	fragment  []byte
+	damaged   bool
+}
+
+// depacketize parses the payload with the parse function and completes access units
+// with the unit function on marker bit or timestamp change
+func (d *nalDepacketizer) depacketize(p *Packet, parse func([]byte) error, unit func() *AccessUnit) ([]*AccessUnit, error) {
+	var units []*AccessUnit
+	var err error
+
+	lost := d.seq.lost(p.SequenceNumber)
+	if lost {
+		d.drop()
+		err = errPacketLoss
+	}
+	if p.Timestamp != d.timestamp {
+		if len(d.nalus) > 0 && !d.damaged {
+			units = append(units, unit())
+		}
+		d.drop()
+		d.timestamp = p.Timestamp
+	}
+	// the lost packets may be the first ones of the access unit
+	if lost {
+		d.damaged = true
+	}
+
+	if perr := parse(p.Payload); perr != nil {
+		d.damaged = true
+		if err == nil {
+			err = perr
+		}
+	}
+
+	if p.Marker {
+		if len(d.nalus) > 0 && !d.damaged {
+			units = append(units, unit())
+		}
+		d.drop()
+	}
+	return units, err
+}
+
+func (d *nalDepacketizer) drop() {
+	d.nalus = nil
+	d.fragment = nil
+	d.damaged = false
+}
+
+// fragmentStart starts the fragmented NAL unit with the header
+func (d *nalDepacketizer) fragmentStart(header []byte, data []byte) {
+	d.fragment = append(append([]byte{}, header...), data...)
+}
+
+// fragmentNext appends the fragment and returns the NAL unit on the end fragment,
+// a fragment without the start one damages the access unit
+func (d *nalDepacketizer) fragmentNext(data []byte, end bool) []byte {
+	if d.fragment == nil {
+		d.damaged = true
+		return nil
+	}
+	d.fragment = append(d.fragment, data...)
+	if !end {
+		return nil
+	}
+	nalu := d.fragment
+	d.fragment = nil
+	return nalu
+}
+
+// withParameterSets prepends parameter sets missing in the NAL units
+func withParameterSets(nalus [][]byte, sets [][]byte, isSet func([]byte) bool) [][]byte {
+	for _, nalu := range nalus {
+		if isSet(nalu) {
+			return nalus
+		}
+	}
+	out := make([][]byte, 0, len(sets)+len(nalus))
+	for _, s := range sets {
+		if len(s) > 0 {
+			out = append(out, s)
+		}
+	}
+	return append(out, nalus...)
+}
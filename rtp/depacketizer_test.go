@@ -0,0 +1,254 @@
+package rtp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+const (
+	testH264SPS = "6742 c01f da01 4016 e840 0000 0300 4000 000c 83c6 0ca8"
+	testH264PPS = "68ce 3c80"
+	testH265VPS = "4001 0c01 ffff 0160 0000 0300 9000 0003 0000 0300 7895 9809"
+	testH265SPS = "4201 0101 6000 0003 0090 0000 0300 0003 0078 a003 c080 10e5 9666 6924 cae0 1000 0003 0010 0000 0301 e080"
+	testH265PPS = "4401 c172 b462 40"
+)
+
+// packet is the RTP packet of the depacketizer tests
+type packet struct {
+	seq       uint16
+	timestamp uint32
+	marker    bool
+	payload   string
+}
+
+// unit is the expected access unit
+type unit struct {
+	timestamp uint32
+	keyframe  bool
+	nalus     []string
+}
+
+type depacketizerTest struct {
+	name    string
+	packets []packet
+	units   []unit
+	loss    bool
+}
+
+func runDepacketizerTests(t *testing.T, tests []depacketizerTest, newDepacketizer func() Depacketizer) {
+	for _, test := range tests {
+		d := newDepacketizer()
+		var units []*AccessUnit
+		loss := false
+		for _, p := range test.packets {
+			aus, err := d.Depacketize(&Packet{SequenceNumber: p.seq, Timestamp: p.timestamp, Marker: p.marker, Payload: unhex(p.payload)})
+			if IsPacketLoss(err) {
+				loss = true
+			} else if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			units = append(units, aus...)
+		}
+
+		if loss != test.loss {
+			t.Errorf("%s: packet loss %v, expected %v", test.name, loss, test.loss)
+		}
+		if len(units) != len(test.units) {
+			t.Errorf("%s: %d access units, expected %d", test.name, len(units), len(test.units))
+			continue
+		}
+		for i, expected := range test.units {
+			au := units[i]
+			if au.Timestamp != expected.timestamp || au.Keyframe != expected.keyframe {
+				t.Errorf("%s: unit %d timestamp %d keyframe %v, expected %d %v",
+					test.name, i, au.Timestamp, au.Keyframe, expected.timestamp, expected.keyframe)
+			}
+			if len(au.NALUs) != len(expected.nalus) {
+				t.Errorf("%s: unit %d has %d NAL units, expected %d", test.name, i, len(au.NALUs), len(expected.nalus))
+				continue
+			}
+			for j, nalu := range expected.nalus {
+				if !bytes.Equal(au.NALUs[j], unhex(nalu)) {
+					t.Errorf("%s: unit %d NAL unit %d is %s, expected %s", test.name, i, j, hex.EncodeToString(au.NALUs[j]), nalu)
+				}
+			}
+		}
+	}
+}
+
+func TestH264Depacketizer(t *testing.T) {
+	tests := []depacketizerTest{
+		{
+			name: "single NAL units",
+			packets: []packet{
+				{1, 1000, true, "4101 0203"},
+				{2, 4000, true, "4104 0506"},
+			},
+			units: []unit{
+				{1000, false, []string{"4101 0203"}},
+				{4000, false, []string{"4104 0506"}},
+			},
+		},
+		{
+			name: "STAP-A parameter sets and IDR",
+			packets: []packet{
+				{1, 1000, false, "18 0016 " + testH264SPS + " 0004 " + testH264PPS},
+				{2, 1000, true, "6588 8040"},
+			},
+			units: []unit{
+				{1000, true, []string{testH264SPS, testH264PPS, "6588 8040"}},
+			},
+		},
+		{
+			name: "FU-A",
+			packets: []packet{
+				{65534, 1000, false, "7c85 8880 40"},
+				{65535, 1000, false, "7c05 0102"},
+				{0, 1000, true, "7c45 0304"},
+			},
+			units: []unit{
+				// parameter sets of sprop-parameter-sets are prepended to IDR
+				{1000, true, []string{testH264SPS, testH264PPS, "6588 8040 0102 0304"}},
+			},
+		},
+		{
+			name: "timestamp change completes the unit",
+			packets: []packet{
+				{1, 1000, false, "4101"},
+				{2, 1000, false, "0102"},
+				{3, 4000, true, "4103"},
+			},
+			units: []unit{
+				{1000, false, []string{"4101", "0102"}},
+				{4000, false, []string{"4103"}},
+			},
+		},
+		{
+			name: "FU-A without the start fragment",
+			packets: []packet{
+				{1, 1000, false, "7c05 0102"},
+				{2, 1000, true, "7c45 0304"},
+				{3, 4000, true, "4101"},
+			},
+			units: []unit{
+				{4000, false, []string{"4101"}},
+			},
+		},
+		{
+			name: "lost middle fragment",
+			packets: []packet{
+				{1, 1000, false, "7c85 8880 40"},
+				{3, 1000, true, "7c45 0304"},
+				{4, 4000, true, "4101"},
+			},
+			units: []unit{
+				{4000, false, []string{"4101"}},
+			},
+			loss: true,
+		},
+		{
+			name: "lost first packet of the next unit",
+			packets: []packet{
+				{1, 1000, false, "6588 8040"},
+				{3, 4000, true, "4101"},
+				{4, 7000, true, "4102"},
+			},
+			units: []unit{
+				{7000, false, []string{"4102"}},
+			},
+			loss: true,
+		},
+	}
+
+	fmtp := map[string]string{"packetization-mode": "1", "sprop-parameter-sets": "Z0LAH9oBQBboQAAAAwBAAAAMg8YMqA==,aM48gA=="}
+	runDepacketizerTests(t, tests, func() Depacketizer {
+		d, err := NewH264Depacketizer(90000, fmtp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	})
+}
+
+func TestH265Depacketizer(t *testing.T) {
+	tests := []depacketizerTest{
+		{
+			name: "AP parameter sets and IDR",
+			packets: []packet{
+				{1, 1000, false, "6001 0018 " + testH265VPS + " 002a " + testH265SPS + " 0007 " + testH265PPS},
+				{2, 1000, true, "2601 af09 40"},
+			},
+			units: []unit{
+				{1000, true, []string{testH265VPS, testH265SPS, testH265PPS, "2601 af09 40"}},
+			},
+		},
+		{
+			name: "FU",
+			packets: []packet{
+				{1, 1000, false, "6201 93af 09"},
+				{2, 1000, false, "6201 1301 02"},
+				{3, 1000, true, "6201 5303 04"},
+			},
+			units: []unit{
+				{1000, true, []string{"2601 af09 0102 0304"}},
+			},
+		},
+		{
+			name: "trailing picture",
+			packets: []packet{
+				{1, 1000, true, "0201 d001"},
+			},
+			units: []unit{
+				{1000, false, []string{"0201 d001"}},
+			},
+		},
+		{
+			name: "lost start fragment",
+			packets: []packet{
+				{1, 1000, true, "0201 d001"},
+				{3, 4000, false, "6201 1301 02"},
+				{4, 4000, true, "6201 5303 04"},
+			},
+			units: []unit{
+				{1000, false, []string{"0201 d001"}},
+			},
+			loss: true,
+		},
+	}
+
+	runDepacketizerTests(t, tests, func() Depacketizer {
+		d, err := NewH265Depacketizer(90000, map[string]string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	})
+}
+
+func TestH265DepacketizerDONL(t *testing.T) {
+	d, err := NewH265Depacketizer(90000, map[string]string{"sprop-max-don-diff": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// AP with DONL and DOND, then FU with DONL
+	packets := []packet{
+		{1, 1000, false, "6001 0000 0004 0201 d001 01 0004 0201 d002"},
+		{2, 1000, false, "6201 8100 02d0 03"},
+		{3, 1000, true, "6201 4104"},
+	}
+	var units []*AccessUnit
+	for _, p := range packets {
+		aus, err := d.Depacketize(&Packet{SequenceNumber: p.seq, Timestamp: p.timestamp, Marker: p.marker, Payload: unhex(p.payload)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		units = append(units, aus...)
+	}
+	if len(units) != 1 || len(units[0].NALUs) != 3 {
+		t.Fatalf("unexpected units %v", units)
+	}
+	if nalu := hex.EncodeToString(units[0].NALUs[2]); nalu != "0201d00304" {
+		t.Errorf("fragmented NAL unit %s", nalu)
+	}
+}
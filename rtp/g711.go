@@ -0,0 +1,30 @@
+package rtp
+
+// G711Depacketizer returns payload of PCMU and PCMA packets as audio frames
+type G711Depacketizer struct {
+	clock timeline
+	seq   sequence
+}
+
+// NewG711Depacketizer creates G711Depacketizer
+func NewG711Depacketizer(clockRate int) *G711Depacketizer {
+	return &G711Depacketizer{clock: newTimeline(clockRate, 8000)}
+}
+
+// Depacketize returns audio frame of the packet
+func (d *G711Depacketizer) Depacketize(p *Packet) ([]*AccessUnit, error) {
+	var err error
+	if d.seq.lost(p.SequenceNumber) {
+		err = errPacketLoss
+	}
+	if len(p.Payload) == 0 {
+		return nil, err
+	}
+	au := &AccessUnit{
+		Timestamp: p.Timestamp,
+		PTS:       d.clock.pts(p.Timestamp),
+		Data:      append([]byte{}, p.Payload...),
+		Keyframe:  true,
+	}
+	return []*AccessUnit{au}, err
+}
@@ -0,0 +1,137 @@
+package rtp
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+)
+
+// H.264 NAL unit types
+const (
+	h264IDR   = 5
+	h264SPS   = 7
+	h264PPS   = 8
+	h264STAPA = 24
+	h264FUA   = 28
+)
+
+// H264Depacketizer assembles H.264 access units of RFC 6184 single NAL unit,
+// STAP-A and FU-A packets
+type H264Depacketizer struct {
+	nalDepacketizer
+
+	sps []byte
+	pps []byte
+}
+
+// NewH264Depacketizer creates H264Depacketizer, the parameter sets are taken
+// from "sprop-parameter-sets" of the format parameters
+func NewH264Depacketizer(clockRate int, fmtp map[string]string) (*H264Depacketizer, error) {
+	if mode := fmtp["packetization-mode"]; mode == "2" {
+		return nil, errUnsupportedPacketization
+	}
+
+	d := &H264Depacketizer{}
+	d.clock = newTimeline(clockRate, 90000)
+	for _, s := range strings.Split(fmtp["sprop-parameter-sets"], ",") {
+		if nalu, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s)); err == nil && len(nalu) > 0 {
+			d.setParameterSet(nalu)
+		}
+	}
+	return d, nil
+}
+
+// Depacketize returns access units completed by the packet
+func (d *H264Depacketizer) Depacketize(p *Packet) ([]*AccessUnit, error) {
+	return d.depacketize(p, d.parse, d.unit)
+}
+
+// SPS returns the last sequence parameter set, nil if not known yet
+func (d *H264Depacketizer) SPS() []byte {
+	return d.sps
+}
+
+// PPS returns the last picture parameter set, nil if not known yet
+func (d *H264Depacketizer) PPS() []byte {
+	return d.pps
+}
+
+func (d *H264Depacketizer) parse(payload []byte) error {
+	if len(payload) < 1 {
+		return errMalformedPayload
+	}
+
+	switch typ := payload[0] & 0x1f; {
+	case typ >= 1 && typ <= 23:
+		d.add(payload)
+
+	case typ == h264STAPA:
+		b := payload[1:]
+		for len(b) > 0 {
+			if len(b) < 2 {
+				return errMalformedPayload
+			}
+			n := int(binary.BigEndian.Uint16(b))
+			if len(b) < 2+n {
+				return errMalformedPayload
+			}
+			d.add(b[2 : 2+n])
+			b = b[2+n:]
+		}
+
+	case typ == h264FUA:
+		if len(payload) < 2 {
+			return errMalformedPayload
+		}
+		fu := payload[1]
+		if fu&0x80 != 0 {
+			if d.fragment != nil {
+				d.damaged = true
+			}
+			d.fragmentStart([]byte{payload[0]&0xe0 | fu&0x1f}, payload[2:])
+			if fu&0x40 == 0 {
+				return nil
+			}
+			d.add(d.fragmentNext(nil, true))
+		} else if nalu := d.fragmentNext(payload[2:], fu&0x40 != 0); nalu != nil {
+			d.add(nalu)
+		}
+
+	default:
+		return errUnsupportedPacketization
+	}
+	return nil
+}
+
+func (d *H264Depacketizer) add(nalu []byte) {
+	if len(nalu) == 0 {
+		return
+	}
+	nalu = append([]byte{}, nalu...)
+	d.setParameterSet(nalu)
+	d.nalus = append(d.nalus, nalu)
+}
+
+func (d *H264Depacketizer) setParameterSet(nalu []byte) {
+	switch nalu[0] & 0x1f {
+	case h264SPS:
+		d.sps = nalu
+	case h264PPS:
+		d.pps = nalu
+	}
+}
+
+func (d *H264Depacketizer) unit() *AccessUnit {
+	au := &AccessUnit{Timestamp: d.timestamp, PTS: d.clock.pts(d.timestamp), NALUs: d.nalus}
+	for _, nalu := range d.nalus {
+		if nalu[0]&0x1f == h264IDR {
+			au.Keyframe = true
+		}
+	}
+	if au.Keyframe {
+		au.NALUs = withParameterSets(au.NALUs, [][]byte{d.sps, d.pps}, func(nalu []byte) bool {
+			return nalu[0]&0x1f == h264SPS
+		})
+	}
+	return au
+}
@@ -0,0 +1,169 @@
+package rtp
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strconv"
+)
+
+// H.265 NAL unit types
+const (
+	h265BLAWLP    = 16
+	h265RSVIRAP23 = 23
+	h265VPS       = 32
+	h265SPS       = 33
+	h265PPS       = 34
+	h265AP        = 48
+	h265FU        = 49
+)
+
+// H265Depacketizer assembles H.265 access units of RFC 7798 single NAL unit,
+// aggregation and fragmentation packets
+type H265Depacketizer struct {
+	nalDepacketizer
+
+	// DONL/DOND fields are present if sprop-max-don-diff is positive
+	donl bool
+
+	vps []byte
+	sps []byte
+	pps []byte
+}
+
+// NewH265Depacketizer creates H265Depacketizer, the parameter sets are taken
+// from "sprop-vps", "sprop-sps" and "sprop-pps" of the format parameters
+func NewH265Depacketizer(clockRate int, fmtp map[string]string) (*H265Depacketizer, error) {
+	d := &H265Depacketizer{}
+	d.clock = newTimeline(clockRate, 90000)
+	if n, err := strconv.Atoi(fmtp["sprop-max-don-diff"]); err == nil && n > 0 {
+		d.donl = true
+	}
+	for _, name := range []string{"sprop-vps", "sprop-sps", "sprop-pps"} {
+		if nalu, err := base64.StdEncoding.DecodeString(fmtp[name]); err == nil && len(nalu) > 1 {
+			d.setParameterSet(nalu)
+		}
+	}
+	return d, nil
+}
+
+// Depacketize returns access units completed by the packet
+func (d *H265Depacketizer) Depacketize(p *Packet) ([]*AccessUnit, error) {
+	return d.depacketize(p, d.parse, d.unit)
+}
+
+// VPS returns the last video parameter set, nil if not known yet
+func (d *H265Depacketizer) VPS() []byte {
+	return d.vps
+}
+
+// SPS returns the last sequence parameter set, nil if not known yet
+func (d *H265Depacketizer) SPS() []byte {
+	return d.sps
+}
+
+// PPS returns the last picture parameter set, nil if not known yet
+func (d *H265Depacketizer) PPS() []byte {
+	return d.pps
+}
+
+func (d *H265Depacketizer) parse(payload []byte) error {
+	if len(payload) < 3 {
+		return errMalformedPayload
+	}
+
+	switch typ := h265Type(payload); {
+	case typ < h265AP:
+		d.add(payload)
+
+	case typ == h265AP:
+		b := payload[2:]
+		for first := true; len(b) > 0; first = false {
+			if d.donl {
+				// DONL of the first unit, DOND of the others
+				skip := 1
+				if first {
+					skip = 2
+				}
+				if len(b) < skip {
+					return errMalformedPayload
+				}
+				b = b[skip:]
+			}
+			if len(b) < 2 {
+				return errMalformedPayload
+			}
+			n := int(binary.BigEndian.Uint16(b))
+			if len(b) < 2+n {
+				return errMalformedPayload
+			}
+			d.add(b[2 : 2+n])
+			b = b[2+n:]
+		}
+
+	case typ == h265FU:
+		fu := payload[2]
+		data := payload[3:]
+		if fu&0x80 != 0 {
+			if d.donl {
+				if len(data) < 2 {
+					return errMalformedPayload
+				}
+				data = data[2:]
+			}
+			if d.fragment != nil {
+				d.damaged = true
+			}
+			header := []byte{payload[0]&0x81 | (fu&0x3f)<<1, payload[1]}
+			d.fragmentStart(header, data)
+			if fu&0x40 == 0 {
+				return nil
+			}
+			d.add(d.fragmentNext(nil, true))
+		} else if nalu := d.fragmentNext(data, fu&0x40 != 0); nalu != nil {
+			d.add(nalu)
+		}
+
+	default:
+		return errUnsupportedPacketization
+	}
+	return nil
+}
+
+func (d *H265Depacketizer) add(nalu []byte) {
+	if len(nalu) < 2 {
+		return
+	}
+	nalu = append([]byte{}, nalu...)
+	d.setParameterSet(nalu)
+	d.nalus = append(d.nalus, nalu)
+}
+
+func (d *H265Depacketizer) setParameterSet(nalu []byte) {
+	switch h265Type(nalu) {
+	case h265VPS:
+		d.vps = nalu
+	case h265SPS:
+		d.sps = nalu
+	case h265PPS:
+		d.pps = nalu
+	}
+}
+
+func (d *H265Depacketizer) unit() *AccessUnit {
+	au := &AccessUnit{Timestamp: d.timestamp, PTS: d.clock.pts(d.timestamp), NALUs: d.nalus}
+	for _, nalu := range d.nalus {
+		if typ := h265Type(nalu); typ >= h265BLAWLP && typ <= h265RSVIRAP23 {
+			au.Keyframe = true
+		}
+	}
+	if au.Keyframe {
+		au.NALUs = withParameterSets(au.NALUs, [][]byte{d.vps, d.sps, d.pps}, func(nalu []byte) bool {
+			return h265Type(nalu) == h265SPS
+		})
+	}
+	return au
+}
+
+func h265Type(nalu []byte) byte {
+	return nalu[0] >> 1 & 0x3f
+}
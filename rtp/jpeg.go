@@ -0,0 +1,299 @@
+package rtp
+
+import (
+	"encoding/binary"
+)
+
+// JPEGDepacketizer assembles JPEG images of RFC 2435 packets. The JPEG headers
+// are reconstructed from the type, Q factor and the quantization tables.
+type JPEGDepacketizer struct {
+	clock     timeline
+	seq       sequence
+	timestamp uint32
+	damaged   bool
+
+	typ      byte
+	width    int
+	height   int
+	dri      uint16
+	qtables  []byte
+	data     []byte
+	started  bool
+	cachedQT map[byte][]byte
+}
+
+// NewJPEGDepacketizer creates JPEGDepacketizer
+func NewJPEGDepacketizer(clockRate int) *JPEGDepacketizer {
+	return &JPEGDepacketizer{clock: newTimeline(clockRate, 90000), cachedQT: map[byte][]byte{}}
+}
+
+// Depacketize returns the image completed by the packet
+func (d *JPEGDepacketizer) Depacketize(p *Packet) ([]*AccessUnit, error) {
+	var err error
+	lost := d.seq.lost(p.SequenceNumber)
+	if lost {
+		d.drop()
+		err = errPacketLoss
+	}
+	if p.Timestamp != d.timestamp {
+		// The image without the marker bit is incomplete
+		d.drop()
+		d.timestamp = p.Timestamp
+	}
+	// The lost packets may be the first ones of the image, parse
+	// clears the flag on the fragment at offset zero
+	if lost {
+		d.damaged = true
+	}
+
+	if perr := d.parse(p.Payload); perr != nil {
+		d.damaged = true
+		if err == nil {
+			err = perr
+		}
+	}
+
+	var units []*AccessUnit
+	if p.Marker {
+		if d.started && !d.damaged {
+			units = append(units, &AccessUnit{
+				Timestamp: d.timestamp,
+				PTS:       d.clock.pts(d.timestamp),
+				Data:      d.image(),
+				Keyframe:  true,
+			})
+		}
+		d.drop()
+	}
+	return units, err
+}
+
+func (d *JPEGDepacketizer) drop() {
+	d.data = nil
+	d.started = false
+	d.damaged = false
+}
+
+func (d *JPEGDepacketizer) parse(payload []byte) error {
+	if len(payload) < 8 {
+		return errMalformedPayload
+	}
+	offset := int(payload[1])<<16 | int(payload[2])<<8 | int(payload[3])
+	typ, q := payload[4], payload[5]
+	width, height := int(payload[6])*8, int(payload[7])*8
+	b := payload[8:]
+
+	var dri uint16
+	if typ >= 64 && typ <= 127 {
+		if len(b) < 4 {
+			return errMalformedPayload
+		}
+		dri = binary.BigEndian.Uint16(b)
+		b = b[4:]
+		typ -= 64
+	}
+	if typ > 1 {
+		return errUnsupportedPacketization
+	}
+
+	if offset == 0 {
+		qtables := makeQuantizationTables(q)
+		if q >= 128 {
+			if len(b) < 4 {
+				return errMalformedPayload
+			}
+			if b[1] != 0 {
+				// 16-bit precision tables
+				return errUnsupportedPacketization
+			}
+			n := int(binary.BigEndian.Uint16(b[2:]))
+			if len(b) < 4+n {
+				return errMalformedPayload
+			}
+			if n > 0 {
+				qtables = append([]byte{}, b[4:4+n]...)
+				d.cachedQT[q] = qtables
+			} else if qtables = d.cachedQT[q]; qtables == nil {
+				return errMalformedPayload
+			}
+			b = b[4+n:]
+		}
+		d.started = true
+		d.damaged = false
+		d.typ, d.width, d.height, d.dri, d.qtables = typ, width, height, dri, qtables
+		d.data = d.data[:0]
+	}
+
+	// A missing fragment damages the image
+	if !d.started || offset != len(d.data) {
+		d.damaged = true
+		return nil
+	}
+	d.data = append(d.data, b...)
+	return nil
+}
+
+// image returns JPEG with the headers of RFC 2435 Appendix B
+func (d *JPEGDepacketizer) image() []byte {
+	img := make([]byte, 0, len(d.data)+1024)
+	img = append(img, 0xff, 0xd8)
+
+	// Luma and chroma quantization tables of 8-bit precision
+	for id := 0; id*64 < len(d.qtables) && id < 2; id++ {
+		img = append(img, 0xff, 0xdb, 0, 67, byte(id))
+		img = append(img, d.qtables[id*64:id*64+64]...)
+	}
+	chromaQT := byte(0)
+	if len(d.qtables) >= 128 {
+		chromaQT = 1
+	}
+
+	// Baseline frame, type 0 is 4:2:2 and type 1 is 4:2:0
+	sampling := byte(0x21)
+	if d.typ == 1 {
+		sampling = 0x22
+	}
+	img = append(img, 0xff, 0xc0, 0, 17, 8,
+		byte(d.height>>8), byte(d.height), byte(d.width>>8), byte(d.width), 3,
+		0, sampling, 0,
+		1, 0x11, chromaQT,
+		2, 0x11, chromaQT)
+
+	img = appendHuffmanTable(img, 0x00, lumDCCodeLens, lumDCSymbols)
+	img = appendHuffmanTable(img, 0x10, lumACCodeLens, lumACSymbols)
+	img = appendHuffmanTable(img, 0x01, chmDCCodeLens, chmDCSymbols)
+	img = appendHuffmanTable(img, 0x11, chmACCodeLens, chmACSymbols)
+
+	if d.dri != 0 {
+		img = append(img, 0xff, 0xdd, 0, 4, byte(d.dri>>8), byte(d.dri))
+	}
+
+	img = append(img, 0xff, 0xda, 0, 12, 3, 0, 0x00, 1, 0x11, 2, 0x11, 0, 63, 0)
+	img = append(img, d.data...)
+	if n := len(d.data); n < 2 || d.data[n-2] != 0xff || d.data[n-1] != 0xd9 {
+		img = append(img, 0xff, 0xd9)
+	}
+	return img
+}
+
+func appendHuffmanTable(b []byte, class byte, codeLens, symbols []byte) []byte {
+	n := 3 + len(codeLens) + len(symbols)
+	b = append(b, 0xff, 0xc4, byte(n>>8), byte(n), class)
+	b = append(b, codeLens...)
+	return append(b, symbols...)
+}
+
+// makeQuantizationTables returns luma and chroma tables in zigzag order
+// for Q factor 1-99 as of RFC 2435 Appendix A
+func makeQuantizationTables(q byte) []byte {
+	factor := int(q)
+	if factor < 1 {
+		factor = 1
+	} else if factor > 99 {
+		factor = 99
+	}
+	scale := 200 - factor*2
+	if factor < 50 {
+		scale = 5000 / factor
+	}
+
+	tables := make([]byte, 128)
+	for i := 0; i < 64; i++ {
+		for j, quantizer := range [][]int{jpegLumaQuantizer, jpegChromaQuantizer} {
+			v := (quantizer[zigzag[i]]*scale + 50) / 100
+			if v < 1 {
+				v = 1
+			} else if v > 255 {
+				v = 255
+			}
+			tables[j*64+i] = byte(v)
+		}
+	}
+	return tables
+}
+
+// Natural order index of zigzag positions
+var zigzag = []int{
+	0, 1, 8, 16, 9, 2, 3, 10, 17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34, 27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36, 29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46, 53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+var jpegLumaQuantizer = []int{
+	16, 11, 10, 16, 24, 40, 51, 61,
+	12, 12, 14, 19, 26, 58, 60, 55,
+	14, 13, 16, 24, 40, 57, 69, 56,
+	14, 17, 22, 29, 51, 87, 80, 62,
+	18, 22, 37, 56, 68, 109, 103, 77,
+	24, 35, 55, 64, 81, 104, 113, 92,
+	49, 64, 78, 87, 103, 121, 120, 101,
+	72, 92, 95, 98, 112, 100, 103, 99,
+}
+
+var jpegChromaQuantizer = []int{
+	17, 18, 24, 47, 99, 99, 99, 99,
+	18, 21, 26, 66, 99, 99, 99, 99,
+	24, 26, 56, 99, 99, 99, 99, 99,
+	47, 66, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+}
+
+// Huffman tables of JPEG Annex K.3
+var (
+	lumDCCodeLens = []byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0}
+	lumDCSymbols  = []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	lumACCodeLens = []byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 0x7d}
+	lumACSymbols  = []byte{
+		0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+		0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+		0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+		0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+		0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+		0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+		0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+		0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+		0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+		0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+		0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+		0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+		0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+		0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+		0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+		0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+		0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+		0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+		0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+		0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+		0xf9, 0xfa,
+	}
+	chmDCCodeLens = []byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0}
+	chmDCSymbols  = []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	chmACCodeLens = []byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 0x77}
+	chmACSymbols  = []byte{
+		0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+		0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+		0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+		0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+		0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+		0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+		0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+		0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+		0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+		0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+		0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+		0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+		0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+		0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+		0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+		0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+		0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+		0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+		0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+		0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+		0xf9, 0xfa,
+	}
+)
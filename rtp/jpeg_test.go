@@ -0,0 +1,156 @@
+package rtp
+
+import (
+	"bytes"
+	"testing"
+)
+
+// jpegPayload returns RFC 2435 payload of the fragment, 640x480 type 1
+func jpegPayload(offset int, typ, q byte, extra []byte, data []byte) []byte {
+	b := []byte{0, byte(offset >> 16), byte(offset >> 8), byte(offset), typ, q, 640 / 8, 480 / 8}
+	return append(append(b, extra...), data...)
+}
+
+func TestJPEGDepacketizer(t *testing.T) {
+	inbandTables := make([]byte, 128)
+	for i := range inbandTables {
+		inbandTables[i] = byte(i + 1)
+	}
+	quantization := append([]byte{0, 0, 0, 128}, inbandTables...)
+
+	tests := []struct {
+		name    string
+		packets []Packet
+		images  []uint32
+		loss    bool
+
+		// expected quantization tables and restart interval of the last image
+		qtables []byte
+		dri     uint16
+	}{
+		{
+			name: "fragmented image",
+			packets: []Packet{
+				{SequenceNumber: 1, Timestamp: 1000, Payload: jpegPayload(0, 1, 50, nil, []byte{1, 2, 3, 4})},
+				{SequenceNumber: 2, Timestamp: 1000, Marker: true, Payload: jpegPayload(4, 1, 50, nil, []byte{5, 6})},
+			},
+			images:  []uint32{1000},
+			qtables: makeQuantizationTables(50),
+		},
+		{
+			name: "in-band quantization tables cached by Q",
+			packets: []Packet{
+				{SequenceNumber: 1, Timestamp: 1000, Marker: true, Payload: jpegPayload(0, 1, 255, quantization, []byte{1, 2})},
+				{SequenceNumber: 2, Timestamp: 4000, Marker: true, Payload: jpegPayload(0, 1, 255, []byte{0, 0, 0, 0}, []byte{1, 2})},
+			},
+			images:  []uint32{1000, 4000},
+			qtables: inbandTables,
+		},
+		{
+			name: "restart markers",
+			packets: []Packet{
+				{SequenceNumber: 1, Timestamp: 1000, Marker: true, Payload: jpegPayload(0, 65, 50, []byte{0, 8, 0xff, 0xff}, []byte{1, 2})},
+			},
+			images:  []uint32{1000},
+			qtables: makeQuantizationTables(50),
+			dri:     8,
+		},
+		{
+			name: "lost middle fragment",
+			packets: []Packet{
+				{SequenceNumber: 1, Timestamp: 1000, Payload: jpegPayload(0, 1, 50, nil, []byte{1, 2})},
+				{SequenceNumber: 3, Timestamp: 1000, Marker: true, Payload: jpegPayload(4, 1, 50, nil, []byte{5, 6})},
+			},
+			loss: true,
+		},
+		{
+			name: "lost first fragment of the next image",
+			packets: []Packet{
+				{SequenceNumber: 1, Timestamp: 1000, Marker: true, Payload: jpegPayload(0, 1, 50, nil, []byte{1, 2})},
+				{SequenceNumber: 3, Timestamp: 4000, Marker: true, Payload: jpegPayload(2, 1, 50, nil, []byte{3, 4})},
+			},
+			images:  []uint32{1000},
+			loss:    true,
+			qtables: makeQuantizationTables(50),
+		},
+		{
+			name: "lost last fragment of the previous image",
+			packets: []Packet{
+				{SequenceNumber: 1, Timestamp: 1000, Payload: jpegPayload(0, 1, 50, nil, []byte{1, 2})},
+				{SequenceNumber: 3, Timestamp: 4000, Marker: true, Payload: jpegPayload(0, 1, 50, nil, []byte{3, 4})},
+			},
+			images:  []uint32{4000},
+			loss:    true,
+			qtables: makeQuantizationTables(50),
+		},
+	}
+
+	for _, test := range tests {
+		d := NewJPEGDepacketizer(90000)
+		var units []*AccessUnit
+		loss := false
+		for i := range test.packets {
+			aus, err := d.Depacketize(&test.packets[i])
+			if IsPacketLoss(err) {
+				loss = true
+			} else if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			units = append(units, aus...)
+		}
+
+		if loss != test.loss {
+			t.Errorf("%s: packet loss %v, expected %v", test.name, loss, test.loss)
+		}
+		if len(units) != len(test.images) {
+			t.Errorf("%s: %d images, expected %d", test.name, len(units), len(test.images))
+			continue
+		}
+		for i, ts := range test.images {
+			if units[i].Timestamp != ts || !units[i].Keyframe {
+				t.Errorf("%s: image %d timestamp %d keyframe %v, expected %d", test.name, i, units[i].Timestamp, units[i].Keyframe, ts)
+			}
+		}
+		if len(units) == 0 {
+			continue
+		}
+
+		img := units[len(units)-1].Data
+		if !bytes.HasPrefix(img, []byte{0xff, 0xd8}) || !bytes.HasSuffix(img, []byte{0xff, 0xd9}) {
+			t.Errorf("%s: no SOI or EOI marker", test.name)
+		}
+		luma := append([]byte{0xff, 0xdb, 0, 67, 0}, test.qtables[:64]...)
+		chroma := append([]byte{0xff, 0xdb, 0, 67, 1}, test.qtables[64:]...)
+		if !bytes.Contains(img, luma) || !bytes.Contains(img, chroma) {
+			t.Errorf("%s: quantization tables not found", test.name)
+		}
+		// 4:2:0 frame of 640x480
+		sof := []byte{0xff, 0xc0, 0, 17, 8, 480 >> 8, 480 & 0xff, 640 >> 8, 640 & 0xff, 3, 0, 0x22}
+		if !bytes.Contains(img, sof) {
+			t.Errorf("%s: frame header not found", test.name)
+		}
+		hasDRI := bytes.Contains(img, []byte{0xff, 0xdd, 0, 4, byte(test.dri >> 8), byte(test.dri)})
+		if hasDRI != (test.dri != 0) {
+			t.Errorf("%s: restart interval present %v, expected %d", test.name, hasDRI, test.dri)
+		}
+	}
+}
+
+func TestMakeQuantizationTables(t *testing.T) {
+	tests := []struct {
+		q            byte
+		luma, chroma byte
+	}{
+		// first coefficients of JPEG Annex K tables scaled as of RFC 2435
+		{50, 16, 17},
+		{75, 8, 9},
+		{10, 80, 85},
+		{99, 1, 1},
+	}
+	for _, test := range tests {
+		tables := makeQuantizationTables(test.q)
+		if tables[0] != test.luma || tables[64] != test.chroma {
+			t.Errorf("Q %d: DC quantizers %d %d, expected %d %d", test.q, tables[0], tables[64], test.luma, test.chroma)
+		}
+	}
+}
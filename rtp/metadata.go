@@ -0,0 +1,47 @@
+package rtp
+
+// MetadataDepacketizer assembles XML documents of the ONVIF metadata stream,
+// a document may be split into several packets ending with the marker bit
+type MetadataDepacketizer struct {
+	clock     timeline
+	seq       sequence
+	timestamp uint32
+	data      []byte
+	damaged   bool
+}
+
+// NewMetadataDepacketizer creates MetadataDepacketizer
+func NewMetadataDepacketizer(clockRate int) *MetadataDepacketizer {
+	return &MetadataDepacketizer{clock: newTimeline(clockRate, 90000)}
+}
+
+// Depacketize returns the document completed by the packet
+func (d *MetadataDepacketizer) Depacketize(p *Packet) ([]*AccessUnit, error) {
+	var err error
+	if d.seq.lost(p.SequenceNumber) {
+		d.data = nil
+		d.damaged = true
+		err = errPacketLoss
+	}
+	if len(d.data) == 0 {
+		d.timestamp = p.Timestamp
+	}
+	d.data = append(d.data, p.Payload...)
+
+	if !p.Marker {
+		return nil, err
+	}
+
+	var units []*AccessUnit
+	if !d.damaged && len(d.data) > 0 {
+		units = append(units, &AccessUnit{
+			Timestamp: d.timestamp,
+			PTS:       d.clock.pts(d.timestamp),
+			Data:      d.data,
+			Keyframe:  true,
+		})
+	}
+	d.data = nil
+	d.damaged = false
+	return units, err
+}
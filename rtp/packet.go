@@ -0,0 +1,86 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformedPacket = errors.New("Malformed RTP packet")
+
+// Packet is the RTP packet
+type Packet struct {
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+	CSRC           []uint32
+	Payload        []byte
+}
+
+// Unmarshal parses the RTP packet. The payload refers to the data.
+func Unmarshal(data []byte) (*Packet, error) {
+	if len(data) < 12 || data[0]>>6 != 2 {
+		return nil, errMalformedPacket
+	}
+
+	p := &Packet{
+		Marker:         data[1]&0x80 != 0,
+		PayloadType:    data[1] & 0x7f,
+		SequenceNumber: binary.BigEndian.Uint16(data[2:]),
+		Timestamp:      binary.BigEndian.Uint32(data[4:]),
+		SSRC:           binary.BigEndian.Uint32(data[8:]),
+	}
+
+	offset := 12
+	cc := int(data[0] & 0x0f)
+	if len(data) < offset+4*cc {
+		return nil, errMalformedPacket
+	}
+	for i := 0; i < cc; i++ {
+		p.CSRC = append(p.CSRC, binary.BigEndian.Uint32(data[offset:]))
+		offset += 4
+	}
+
+	// Header extension is skipped
+	if data[0]&0x10 != 0 {
+		if len(data) < offset+4 {
+			return nil, errMalformedPacket
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:]))
+		if len(data) < offset {
+			return nil, errMalformedPacket
+		}
+	}
+
+	end := len(data)
+	if data[0]&0x20 != 0 {
+		padding := int(data[end-1])
+		if padding == 0 || end-padding < offset {
+			return nil, errMalformedPacket
+		}
+		end -= padding
+	}
+	p.Payload = data[offset:end]
+	return p, nil
+}
+
+// Marshal returns the packet data
+func (p *Packet) Marshal() []byte {
+	data := make([]byte, 12+4*len(p.CSRC)+len(p.Payload))
+	data[0] = 2<<6 | byte(len(p.CSRC)&0x0f)
+	data[1] = p.PayloadType & 0x7f
+	if p.Marker {
+		data[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(data[2:], p.SequenceNumber)
+	binary.BigEndian.PutUint32(data[4:], p.Timestamp)
+	binary.BigEndian.PutUint32(data[8:], p.SSRC)
+	offset := 12
+	for _, c := range p.CSRC {
+		binary.BigEndian.PutUint32(data[offset:], c)
+		offset += 4
+	}
+	copy(data[offset:], p.Payload)
+	return data
+}
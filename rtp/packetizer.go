@@ -0,0 +1,121 @@
+package rtp
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var errMalformedADTS = errors.New("Malformed ADTS header")
+
+// Packetizer creates packets of the stream with consecutive sequence numbers
+// and timestamps, starting at random values
+type Packetizer struct {
+	PayloadType uint8
+	SSRC        uint32
+
+	sequence  uint16
+	timestamp uint32
+	started   bool
+}
+
+// NewPacketizer creates Packetizer of the payload type
+func NewPacketizer(payloadType uint8) *Packetizer {
+	b := make([]byte, 10)
+	rand.Read(b)
+	return &Packetizer{
+		PayloadType: payloadType,
+		SSRC:        binary.BigEndian.Uint32(b),
+		sequence:    binary.BigEndian.Uint16(b[4:]),
+		timestamp:   binary.BigEndian.Uint32(b[6:]),
+	}
+}
+
+// Packet returns the packet of the payload lasting given number of samples.
+// The marker bit is set on the first packet as of the audio talkspurt start.
+func (p *Packetizer) Packet(payload []byte, samples uint32) *Packet {
+	packet := &Packet{
+		Marker:         !p.started,
+		PayloadType:    p.PayloadType,
+		SequenceNumber: p.sequence,
+		Timestamp:      p.timestamp,
+		SSRC:           p.SSRC,
+		Payload:        payload,
+	}
+	p.started = true
+	p.sequence++
+	p.timestamp += samples
+	return packet
+}
+
+// AACPayload returns RFC 3640 payload of the single AAC frame with the AU header
+// of given size and index lengths, e.g. 13 and 3 of AAC-hbr
+func AACPayload(frame []byte, sizeLength, indexLength int) []byte {
+	bits := sizeLength + indexLength
+	n := (bits + 7) / 8
+	payload := make([]byte, 2+n+len(frame))
+	binary.BigEndian.PutUint16(payload, uint16(bits))
+
+	// AU-size followed by zero AU-index, left aligned
+	header := uint64(len(frame)) << uint(64-sizeLength)
+	for i := 0; i < n; i++ {
+		payload[2+i] = byte(header >> uint(56-8*i))
+	}
+	copy(payload[2+n:], frame)
+	return payload
+}
+
+// ADTSReader reads AAC frames of the ADTS stream, e.g. of .aac files
+type ADTSReader struct {
+	r *bufio.Reader
+}
+
+// NewADTSReader creates ADTSReader
+func NewADTSReader(r io.Reader) *ADTSReader {
+	return &ADTSReader{r: bufio.NewReader(r)}
+}
+
+// ReadFrame returns the next frame without the ADTS header and its configuration
+func (a *ADTSReader) ReadFrame() ([]byte, *AACConfig, error) {
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(a.r, header); err != nil {
+		return nil, nil, err
+	}
+	if header[0] != 0xff || header[1]&0xf0 != 0xf0 {
+		return nil, nil, errMalformedADTS
+	}
+
+	index := int(header[2] >> 2 & 0x0f)
+	if index >= len(aacSampleRates) {
+		return nil, nil, errMalformedADTS
+	}
+	config := &AACConfig{
+		ObjectType:  int(header[2]>>6) + 1,
+		SampleRate:  aacSampleRates[index],
+		Channels:    int(header[2]&0x01)<<2 | int(header[3]>>6),
+		FrameLength: 1024,
+	}
+
+	length := int(header[3]&0x03)<<11 | int(header[4])<<3 | int(header[5]>>5)
+	headerLength := 7
+	if header[1]&0x01 == 0 {
+		// CRC
+		headerLength = 9
+	}
+	if length < headerLength {
+		return nil, nil, errMalformedADTS
+	}
+	if headerLength == 9 {
+		if _, err := a.r.Discard(2); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	frame := make([]byte, length-headerLength)
+	if _, err := io.ReadFull(a.r, frame); err != nil {
+		return nil, nil, err
+	}
+	return frame, config, nil
+}
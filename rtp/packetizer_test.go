@@ -0,0 +1,137 @@
+package rtp
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"testing"
+)
+
+// adts returns the frame with the ADTS header of AAC-LC 44100 Hz stereo,
+// the header is followed by CRC if set
+func adts(frame []byte, crc bool) []byte {
+	headerLength := 7
+	protectionAbsent := byte(1)
+	if crc {
+		headerLength, protectionAbsent = 9, 0
+	}
+	length := headerLength + len(frame)
+	const objectType, sampleRateIndex, channels = 2, 4, 2
+	header := []byte{
+		0xff,
+		0xf0 | protectionAbsent,
+		(objectType-1)<<6 | sampleRateIndex<<2 | channels>>2,
+		channels&0x03<<6 | byte(length>>11)&0x03,
+		byte(length >> 3),
+		byte(length&0x07)<<5 | 0x1f,
+		0xfc,
+	}
+	if crc {
+		header = append(header, 0x12, 0x34)
+	}
+	return append(header, frame...)
+}
+
+func TestADTSReader(t *testing.T) {
+	frames := [][]byte{
+		bytes.Repeat([]byte{0x21}, 200),
+		bytes.Repeat([]byte{0x42}, 371),
+		{0x01},
+	}
+	stream := append(append(adts(frames[0], false), adts(frames[1], true)...), adts(frames[2], false)...)
+
+	r := NewADTSReader(bytes.NewReader(stream))
+	for i, expected := range frames {
+		frame, config, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !bytes.Equal(frame, expected) {
+			t.Errorf("frame %d: %d bytes, expected %d", i, len(frame), len(expected))
+		}
+		if *config != (AACConfig{ObjectType: 2, SampleRate: 44100, Channels: 2, FrameLength: 1024}) {
+			t.Errorf("frame %d: config %+v", i, config)
+		}
+	}
+	if _, _, err := r.ReadFrame(); err != io.EOF {
+		t.Errorf("error %v at the end of the stream", err)
+	}
+}
+
+func TestADTSReaderMalformed(t *testing.T) {
+	frame := adts([]byte{1, 2, 3, 4}, false)
+	badSync := append([]byte{}, frame...)
+	badSync[1] = 0x71
+	badSampleRate := append([]byte{}, frame...)
+	badSampleRate[2] |= 0x0f << 2
+	shortLength := adts(nil, true)
+	shortLength[4], shortLength[5] = 0, 0x1f
+
+	tests := []struct {
+		name   string
+		stream []byte
+		err    error
+	}{
+		{"bad sync", badSync, errMalformedADTS},
+		{"bad sample rate", badSampleRate, errMalformedADTS},
+		{"length shorter than header", shortLength, errMalformedADTS},
+		{"truncated header", frame[:5], io.ErrUnexpectedEOF},
+		{"truncated frame", frame[:9], io.ErrUnexpectedEOF},
+		{"truncated CRC", adts(nil, true)[:8], io.EOF},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := NewADTSReader(bytes.NewReader(test.stream)).ReadFrame(); err != test.err {
+				t.Errorf("error %v, expected %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestAACPayload(t *testing.T) {
+	tests := []struct {
+		name        string
+		sizeLength  int
+		indexLength int
+		frames      [][]byte
+	}{
+		{"AAC-hbr", 13, 3, [][]byte{bytes.Repeat([]byte{0x21}, 1500), {0xff}, bytes.Repeat([]byte{0x42}, 371)}},
+		// maximum AU-size is 63
+		{"AAC-lbr", 6, 2, [][]byte{bytes.Repeat([]byte{0x21}, 63), {0xff}, bytes.Repeat([]byte{0x42}, 40)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fmtp := map[string]string{
+				"mode":             test.name,
+				"config":           "1210",
+				"sizelength":       strconv.Itoa(test.sizeLength),
+				"indexlength":      strconv.Itoa(test.indexLength),
+				"indexdeltalength": strconv.Itoa(test.indexLength),
+			}
+			d, err := NewAACDepacketizer(44100, fmtp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := NewPacketizer(96)
+			start := p.timestamp
+			for i, frame := range test.frames {
+				units, err := d.Depacketize(p.Packet(AACPayload(frame, test.sizeLength, test.indexLength), 1024))
+				if err != nil {
+					t.Fatalf("frame %d: %v", i, err)
+				}
+				if len(units) != 1 {
+					t.Fatalf("frame %d: %d units", i, len(units))
+				}
+				if !bytes.Equal(units[0].Data, frame) {
+					t.Errorf("frame %d: %x, expected %x", i, units[0].Data, frame)
+				}
+				if units[0].Timestamp != start+uint32(i)*1024 {
+					t.Errorf("frame %d: timestamp %d", i, units[0].Timestamp-start)
+				}
+			}
+		})
+	}
+}
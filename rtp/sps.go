@@ -0,0 +1,411 @@
+package rtp
+
+import (
+	"errors"
+)
+
+var (
+	errMalformedSPS = errors.New("Malformed sequence parameter set")
+	errBitsOverrun  = errors.New("Read beyond the end of data")
+)
+
+// SPS contains the stream properties of the sequence parameter set
+type SPS struct {
+	// profile_idc and level_idc, e.g. 100 and 40 for H.264 High 4.0,
+	// 1 and 120 for H.265 Main 4.0
+	Profile int
+	Level   int
+
+	// Size of the picture after cropping
+	Width  int
+	Height int
+
+	// Frame rate of the VUI timing info, 0 if not present
+	FrameRate float64
+}
+
+// ParseH264SPS parses H.264 sequence parameter set NAL unit
+func ParseH264SPS(nalu []byte) (*SPS, error) {
+	if len(nalu) < 4 || nalu[0]&0x1f != h264SPS {
+		return nil, errMalformedSPS
+	}
+	r := &bitReader{data: unescapeRBSP(nalu[1:])}
+	sps := &SPS{}
+
+	sps.Profile = int(r.bits(8))
+	r.bits(8)
+	sps.Level = int(r.bits(8))
+	r.ue()
+
+	chromaFormat := uint32(1)
+	separateColourPlane := false
+	switch sps.Profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormat = r.ue()
+		if chromaFormat == 3 {
+			separateColourPlane = r.bit()
+		}
+		r.ue()
+		r.ue()
+		r.bit()
+		if r.bit() {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if !r.bit() {
+					continue
+				}
+				size := 64
+				if i < 6 {
+					size = 16
+				}
+				last, next := int32(8), int32(8)
+				for j := 0; j < size; j++ {
+					if next != 0 {
+						next = (last + r.se() + 256) % 256
+					}
+					if next != 0 {
+						last = next
+					}
+				}
+			}
+		}
+	}
+
+	r.ue()
+	switch r.ue() {
+	case 0:
+		r.ue()
+	case 1:
+		r.bit()
+		r.se()
+		r.se()
+		for n := r.ue(); n > 0 && r.err == nil; n-- {
+			r.se()
+		}
+	}
+	r.ue()
+	r.bit()
+
+	widthMbs := int(r.ue()) + 1
+	heightMapUnits := int(r.ue()) + 1
+	frameMbsOnly := r.bit()
+	if !frameMbsOnly {
+		r.bit()
+	}
+	r.bit()
+
+	fieldFactor := 2
+	if frameMbsOnly {
+		fieldFactor = 1
+	}
+	sps.Width = widthMbs * 16
+	sps.Height = fieldFactor * heightMapUnits * 16
+
+	if r.bit() {
+		cropX, cropY := 1, fieldFactor
+		if !separateColourPlane {
+			switch chromaFormat {
+			case 1:
+				cropX, cropY = 2, 2*fieldFactor
+			case 2:
+				cropX = 2
+			}
+		}
+		left, right, top, bottom := int(r.ue()), int(r.ue()), int(r.ue()), int(r.ue())
+		sps.Width -= (left + right) * cropX
+		sps.Height -= (top + bottom) * cropY
+	}
+
+	if r.bit() {
+		if r.bit() && r.bits(8) == 255 {
+			r.bits(32)
+		}
+		if r.bit() {
+			r.bit()
+		}
+		if r.bit() {
+			r.bits(4)
+			if r.bit() {
+				r.bits(24)
+			}
+		}
+		if r.bit() {
+			r.ue()
+			r.ue()
+		}
+		if r.bit() {
+			units, scale := r.bits(32), r.bits(32)
+			if units > 0 {
+				sps.FrameRate = float64(scale) / float64(2*units)
+			}
+		}
+	}
+
+	if r.err != nil || sps.Width <= 0 || sps.Height <= 0 {
+		return nil, errMalformedSPS
+	}
+	return sps, nil
+}
+
+// ParseH265SPS parses H.265 sequence parameter set NAL unit
+func ParseH265SPS(nalu []byte) (*SPS, error) {
+	if len(nalu) < 4 || h265Type(nalu) != h265SPS {
+		return nil, errMalformedSPS
+	}
+	r := &bitReader{data: unescapeRBSP(nalu[2:])}
+	sps := &SPS{}
+
+	r.bits(4)
+	maxSubLayers := int(r.bits(3))
+	r.bit()
+
+	// profile_tier_level
+	r.bits(3)
+	sps.Profile = int(r.bits(5))
+	r.bits(32)
+	r.bits(32)
+	r.bits(16)
+	sps.Level = int(r.bits(8))
+	profilePresent := make([]bool, maxSubLayers)
+	levelPresent := make([]bool, maxSubLayers)
+	for i := 0; i < maxSubLayers; i++ {
+		profilePresent[i] = r.bit()
+		levelPresent[i] = r.bit()
+	}
+	if maxSubLayers > 0 {
+		for i := maxSubLayers; i < 8; i++ {
+			r.bits(2)
+		}
+	}
+	for i := 0; i < maxSubLayers; i++ {
+		if profilePresent[i] {
+			r.bits(32)
+			r.bits(32)
+			r.bits(24)
+		}
+		if levelPresent[i] {
+			r.bits(8)
+		}
+	}
+
+	r.ue()
+	chromaFormat := r.ue()
+	separateColourPlane := false
+	if chromaFormat == 3 {
+		separateColourPlane = r.bit()
+	}
+	sps.Width = int(r.ue())
+	sps.Height = int(r.ue())
+
+	if r.bit() {
+		cropX, cropY := 1, 1
+		if !separateColourPlane {
+			switch chromaFormat {
+			case 1:
+				cropX, cropY = 2, 2
+			case 2:
+				cropX = 2
+			}
+		}
+		left, right, top, bottom := int(r.ue()), int(r.ue()), int(r.ue()), int(r.ue())
+		sps.Width -= (left + right) * cropX
+		sps.Height -= (top + bottom) * cropY
+	}
+
+	r.ue()
+	r.ue()
+	pocLsbBits := int(r.ue()) + 4
+	first := maxSubLayers
+	if r.bit() {
+		first = 0
+	}
+	for i := first; i <= maxSubLayers; i++ {
+		r.ue()
+		r.ue()
+		r.ue()
+	}
+	for i := 0; i < 6; i++ {
+		r.ue()
+	}
+
+	if r.bit() && r.bit() {
+		skipH265ScalingListData(r)
+	}
+	r.bit()
+	r.bit()
+	if r.bit() {
+		r.bits(8)
+		r.ue()
+		r.ue()
+		r.bit()
+	}
+
+	numSets := int(r.ue())
+	if numSets > 64 {
+		return nil, errMalformedSPS
+	}
+	deltaPocs := make([]int, numSets)
+	for i := 0; i < numSets && r.err == nil; i++ {
+		if i > 0 && r.bit() {
+			// inter_ref_pic_set_prediction
+			r.bit()
+			r.ue()
+			n := 0
+			for j := 0; j <= deltaPocs[i-1]; j++ {
+				if r.bit() || r.bit() {
+					n++
+				}
+			}
+			deltaPocs[i] = n
+			continue
+		}
+		negative, positive := int(r.ue()), int(r.ue())
+		if negative > 16 || positive > 16 {
+			return nil, errMalformedSPS
+		}
+		for j := 0; j < negative+positive; j++ {
+			r.ue()
+			r.bit()
+		}
+		deltaPocs[i] = negative + positive
+	}
+
+	if r.bit() {
+		for n := r.ue(); n > 0 && r.err == nil; n-- {
+			r.bits(pocLsbBits)
+			r.bit()
+		}
+	}
+	r.bit()
+	r.bit()
+
+	if r.bit() {
+		if r.bit() && r.bits(8) == 255 {
+			r.bits(32)
+		}
+		if r.bit() {
+			r.bit()
+		}
+		if r.bit() {
+			r.bits(4)
+			if r.bit() {
+				r.bits(24)
+			}
+		}
+		if r.bit() {
+			r.ue()
+			r.ue()
+		}
+		r.bits(3)
+		if r.bit() {
+			r.ue()
+			r.ue()
+			r.ue()
+			r.ue()
+		}
+		if r.bit() {
+			units, scale := r.bits(32), r.bits(32)
+			if units > 0 {
+				sps.FrameRate = float64(scale) / float64(units)
+			}
+		}
+	}
+
+	if r.err != nil || sps.Width <= 0 || sps.Height <= 0 {
+		return nil, errMalformedSPS
+	}
+	return sps, nil
+}
+
+func skipH265ScalingListData(r *bitReader) {
+	for size := 0; size < 4; size++ {
+		step := 1
+		if size == 3 {
+			step = 3
+		}
+		for matrix := 0; matrix < 6; matrix += step {
+			if !r.bit() {
+				r.ue()
+				continue
+			}
+			coefs := 1 << uint(4+size<<1)
+			if coefs > 64 {
+				coefs = 64
+			}
+			if size > 1 {
+				r.se()
+			}
+			for i := 0; i < coefs; i++ {
+				r.se()
+			}
+		}
+	}
+}
+
+// unescapeRBSP removes emulation prevention bytes
+func unescapeRBSP(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c == 3 {
+			zeros = 0
+			continue
+		}
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// bitReader reads bits MSB first, the error is set on overrun
+type bitReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *bitReader) bit() bool {
+	return r.bits(1) == 1
+}
+
+func (r *bitReader) bits(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		if r.pos >= 8*len(r.data) {
+			r.err = errBitsOverrun
+			return 0
+		}
+		v = v<<1 | uint32(r.data[r.pos/8]>>(7-uint(r.pos%8))&1)
+		r.pos++
+	}
+	return v
+}
+
+// ue reads unsigned Exp-Golomb code
+func (r *bitReader) ue() uint32 {
+	zeros := 0
+	for !r.bit() {
+		if r.err != nil || zeros == 32 {
+			r.err = errBitsOverrun
+			return 0
+		}
+		zeros++
+	}
+	return 1<<uint(zeros) - 1 + r.bits(zeros)
+}
+
+// se reads signed Exp-Golomb code
+func (r *bitReader) se() int32 {
+	v := r.ue()
+	if v&1 == 1 {
+		return int32(v+1) / 2
+	}
+	return -int32(v / 2)
+}
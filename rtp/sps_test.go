@@ -0,0 +1,128 @@
+package rtp
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// unhex decodes hex bytes separated by spaces
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(strings.Replace(s, " ", "", -1))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestParseH264SPS(t *testing.T) {
+	tests := []struct {
+		name string
+		nalu string
+		sps  SPS
+	}{
+		{
+			"High 4.0 1080p30",
+			"6764 0028 acd9 4078 0227 e5c0 4400 0003 0004 0000 0300 f03c 60c6 58",
+			SPS{Profile: 100, Level: 40, Width: 1920, Height: 1080, FrameRate: 30},
+		},
+		{
+			"Constrained Baseline 3.1 720p25",
+			"6742 c01f da01 4016 e840 0000 0300 4000 000c 83c6 0ca8",
+			SPS{Profile: 66, Level: 31, Width: 1280, Height: 720, FrameRate: 25},
+		},
+		{
+			"Main 3.1 720p24",
+			"674d 401f e880 2802 dd80 b501 0101 4000 0003 0040 0000 0c03 c60c 4480",
+			SPS{Profile: 77, Level: 31, Width: 1280, Height: 720, FrameRate: 24},
+		},
+		{
+			"High 1.2 CIF 15 fps",
+			"6764 000c ac3b 50b0 4b42 0000 0300 0200 0003 003d 08",
+			SPS{Profile: 100, Level: 12, Width: 352, Height: 288, FrameRate: 15},
+		},
+	}
+	for _, test := range tests {
+		sps, err := ParseH264SPS(unhex(test.nalu))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if *sps != test.sps {
+			t.Errorf("%s: got %+v, expected %+v", test.name, *sps, test.sps)
+		}
+	}
+}
+
+func TestParseH265SPS(t *testing.T) {
+	nalu := unhex("4201 0101 6000 0003 0090 0000 0300 0003 0078 a003 c080 10e5 9666 6924 cae0 1000 0003 0010 0000 0301 e080")
+	sps, err := ParseH265SPS(nalu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := SPS{Profile: 1, Level: 120, Width: 1920, Height: 1080, FrameRate: 30}
+	if *sps != expected {
+		t.Errorf("got %+v, expected %+v", *sps, expected)
+	}
+}
+
+func TestParseSPSMalformed(t *testing.T) {
+	for _, nalu := range []string{
+		"",
+		"6764",
+		"6864 0028 acd9",                // PPS
+		"6764 0028 ac",                  // truncated
+		"4201 0101 6000 0003 0090 0000", // truncated H.265 SPS
+	} {
+		if _, err := ParseH264SPS(unhex(nalu)); err == nil {
+			t.Errorf("H.264 %q: no error", nalu)
+		}
+		if _, err := ParseH265SPS(unhex(nalu)); err == nil {
+			t.Errorf("H.265 %q: no error", nalu)
+		}
+	}
+}
+
+func TestUnescapeRBSP(t *testing.T) {
+	tests := []struct{ in, out string }{
+		{"0000 0300 01", "0000 0001"},
+		{"0000 0303", "0000 03"},
+		{"00 0300", "00 0300"},
+		{"0000 0300 0003 00", "0000 0000 00"},
+	}
+	for _, test := range tests {
+		if out := hex.EncodeToString(unescapeRBSP(unhex(test.in))); out != strings.Replace(test.out, " ", "", -1) {
+			t.Errorf("%s: got %s, expected %s", test.in, out, test.out)
+		}
+	}
+}
+
+func TestBitReader(t *testing.T) {
+	// 1 | 010 | 011 | 00100 | 00101 | 0001000 = 1, ue 1, ue 2, ue 3, se -2, ue 7
+	r := &bitReader{data: []byte{0xa6, 0x42, 0x88}}
+	if !r.bit() {
+		t.Error("bit")
+	}
+	if v := r.ue(); v != 1 {
+		t.Errorf("ue %d, expected 1", v)
+	}
+	if v := r.ue(); v != 2 {
+		t.Errorf("ue %d, expected 2", v)
+	}
+	if v := r.ue(); v != 3 {
+		t.Errorf("ue %d, expected 3", v)
+	}
+	if v := r.se(); v != -2 {
+		t.Errorf("se %d, expected -2", v)
+	}
+	if v := r.ue(); v != 7 {
+		t.Errorf("ue %d, expected 7", v)
+	}
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	r.bits(8)
+	if r.err == nil {
+		t.Error("no error reading beyond the end")
+	}
+}
@@ -0,0 +1,739 @@
+package rtsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/videonext/onvif/auth"
+)
+
+var (
+	errClosed         = errors.New("RTSP connection is closed")
+	errNotDescribed   = errors.New("DESCRIBE was not sent")
+	errNoTracks       = errors.New("No track is set up")
+	errNoUDPPorts     = errors.New("No free UDP port pair")
+	errInvalidScheme  = errors.New("URL scheme is not rtsp")
+	errPacketTooLarge = errors.New("Packet exceeds the interleaved frame size")
+)
+
+// RequireBackchannel is the Require header value of the ONVIF audio backchannel
+const RequireBackchannel = "www.onvif.org/ver20/backchannel"
+
+// Default session timeout if the server does not specify it
+const defaultSessionTimeout = 60 * time.Second
+
+// Transport of the RTP packets
+type Transport int
+
+const (
+	// TransportTCP is RTP interleaved in the RTSP connection
+	TransportTCP Transport = iota
+
+	// TransportUDP is RTP over UDP unicast
+	TransportUDP
+)
+
+type options struct {
+	transport  Transport
+	username   string
+	password   string
+	timeout    time.Duration
+	userAgent  string
+	require    []string
+	bufferSize int
+	keepAlive  time.Duration
+}
+
+var defaultOptions = options{
+	transport:  TransportTCP,
+	timeout:    10 * time.Second,
+	userAgent:  "onvif-rtsp",
+	bufferSize: 256,
+}
+
+// Option type
+type Option func(*options)
+
+// WithTransport is an Option to set the RTP transport, TCP by default
+func WithTransport(t Transport) Option {
+	return func(o *options) {
+		o.transport = t
+	}
+}
+
+// WithCredentials is an Option to set user name and password,
+// by default they are taken from the URL
+func WithCredentials(username, password string) Option {
+	return func(o *options) {
+		o.username = username
+		o.password = password
+	}
+}
+
+// WithTimeout is an Option to set timeout of the requests without context deadline
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithUserAgent is an Option to set the User-Agent header
+func WithUserAgent(ua string) Option {
+	return func(o *options) {
+		o.userAgent = ua
+	}
+}
+
+// WithRequire is an Option to send the Require header with DESCRIBE and SETUP,
+// e.g. RequireBackchannel
+func WithRequire(features ...string) Option {
+	return func(o *options) {
+		o.require = features
+	}
+}
+
+// WithBufferSize is an Option to set the size of the packet channel,
+// packets are dropped when it is full
+func WithBufferSize(n int) Option {
+	return func(o *options) {
+		o.bufferSize = n
+	}
+}
+
+// WithKeepAlive is an Option to set the keep alive interval,
+// by default half of the session timeout
+func WithKeepAlive(d time.Duration) Option {
+	return func(o *options) {
+		o.keepAlive = d
+	}
+}
+
+// Packet is the RTP or RTCP packet received on the track
+type Packet struct {
+	// Index of the track in the order of Setup calls
+	Track int
+
+	RTCP bool
+	Data []byte
+}
+
+// Track is the media set up for streaming
+type Track struct {
+	Media Media
+
+	// Control URL of the track
+	URL string
+
+	// Interleaved channels of RTP and RTCP, TCP transport
+	RTPChannel  int
+	RTCPChannel int
+
+	// Server ports of RTP and RTCP, UDP transport
+	ServerRTPPort  int
+	ServerRTCPPort int
+
+	rtpConn  *net.UDPConn
+	rtcpConn *net.UDPConn
+}
+
+// Stats contains counters of the received packets
+type Stats struct {
+	Packets uint64
+	Bytes   uint64
+
+	// Packets dropped because the channel was full
+	Dropped uint64
+
+	LastPacket time.Time
+}
+
+// Client is the RTSP client session. Requests are sent one at a time, while
+// the packets are received in the background and delivered with Packets.
+type Client struct {
+	opts options
+
+	// request URL without credentials
+	url      string
+	serverIP net.IP
+
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// reqMu serializes the requests and guards the session state below,
+	// it is read by keepAlive
+	reqMu          sync.Mutex
+	writeMu        sync.Mutex
+	cseq           int
+	challenge      *auth.Challenge
+	session        string
+	sessionTimeout time.Duration
+	public         []string
+	description    *SessionDescription
+	aggregateURL   string
+
+	responses chan *Response
+	packets   chan Packet
+	done      chan struct{}
+	wg        sync.WaitGroup
+
+	mu        sync.Mutex
+	err       error
+	tracks    []*Track
+	stats     Stats
+	failOnce  sync.Once
+	closeOnce sync.Once
+}
+
+// Dial connects to the RTSP server and sends OPTIONS. The URL may contain
+// credentials, e.g. the URI of media GetStreamUri with embedded credentials.
+func Dial(ctx context.Context, rawurl string, opt ...Option) (*Client, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(u.Scheme, "rtsp") {
+		return nil, errInvalidScheme
+	}
+
+	opts := defaultOptions
+	if u.User != nil {
+		opts.username = u.User.Username()
+		opts.password, _ = u.User.Password()
+	}
+	for _, o := range opt {
+		o(&opts)
+	}
+	u.User = nil
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "554")
+	}
+	d := net.Dialer{Timeout: opts.timeout}
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		opts:           opts,
+		url:            u.String(),
+		conn:           conn,
+		reader:         bufio.NewReaderSize(conn, 64*1024),
+		sessionTimeout: defaultSessionTimeout,
+		responses:      make(chan *Response, 8),
+		packets:        make(chan Packet, opts.bufferSize),
+		done:           make(chan struct{}),
+	}
+	c.aggregateURL = c.url
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		c.serverIP = addr.IP
+	}
+
+	c.wg.Add(1)
+	go c.readLoop()
+
+	if _, err := c.Options(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Options sends OPTIONS and returns the methods supported by the server
+func (c *Client) Options(ctx context.Context) ([]string, error) {
+	resp, err := c.Do(ctx, "OPTIONS", c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	public := []string{}
+	for _, m := range strings.Split(resp.Header.Get("Public"), ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			public = append(public, strings.ToUpper(m))
+		}
+	}
+	c.reqMu.Lock()
+	c.public = public
+	c.reqMu.Unlock()
+	return public, nil
+}
+
+// Describe sends DESCRIBE and returns the session description
+func (c *Client) Describe(ctx context.Context) (*SessionDescription, error) {
+	resp, err := c.Do(ctx, "DESCRIBE", c.url, map[string]string{"Accept": "application/sdp"})
+	if err != nil {
+		return nil, err
+	}
+
+	base := c.url
+	if cb := resp.Header.Get("Content-Base"); cb != "" {
+		base = strings.TrimSpace(cb)
+	} else if cl := resp.Header.Get("Content-Location"); cl != "" {
+		base = strings.TrimSpace(cl)
+	}
+
+	sd := ParseSDP(string(resp.Body))
+	c.reqMu.Lock()
+	c.description = sd
+	c.aggregateURL = controlURL(base, sd.Control)
+	c.reqMu.Unlock()
+	return sd, nil
+}
+
+// Setup sets up the media of the session description returned by Describe
+func (c *Client) Setup(ctx context.Context, m Media) (*Track, error) {
+	c.reqMu.Lock()
+	described, aggregateURL := c.description != nil, c.aggregateURL
+	c.reqMu.Unlock()
+	if !described {
+		return nil, errNotDescribed
+	}
+
+	t := &Track{Media: m, URL: controlURL(aggregateURL, m.Control)}
+	index := len(c.setUp())
+
+	var transport string
+	if c.opts.transport == TransportUDP {
+		rtp, rtcp, err := listenUDPPair()
+		if err != nil {
+			return nil, err
+		}
+		t.rtpConn, t.rtcpConn = rtp, rtcp
+		port := rtp.LocalAddr().(*net.UDPAddr).Port
+		transport = "RTP/AVP;unicast;client_port=" + strconv.Itoa(port) + "-" + strconv.Itoa(port+1)
+	} else {
+		t.RTPChannel, t.RTCPChannel = 2*index, 2*index+1
+		transport = "RTP/AVP/TCP;unicast;interleaved=" + strconv.Itoa(t.RTPChannel) + "-" + strconv.Itoa(t.RTCPChannel)
+	}
+
+	resp, err := c.Do(ctx, "SETUP", t.URL, map[string]string{"Transport": transport})
+	if err != nil {
+		t.close()
+		return nil, err
+	}
+
+	_, params := headerParams(resp.Header.Get("Transport"))
+	if v, ok := params["interleaved"]; ok {
+		if a, b, ok := portRange(v); ok {
+			t.RTPChannel, t.RTCPChannel = a, b
+		}
+	}
+	if v, ok := params["server_port"]; ok {
+		if a, b, ok := portRange(v); ok {
+			t.ServerRTPPort, t.ServerRTCPPort = a, b
+		}
+	}
+
+	if s := resp.Header.Get("Session"); s != "" {
+		id, sp := headerParams(s)
+		c.reqMu.Lock()
+		c.session = id
+		if timeout, err := strconv.Atoi(sp["timeout"]); err == nil && timeout > 0 {
+			c.sessionTimeout = time.Duration(timeout) * time.Second
+		}
+		c.reqMu.Unlock()
+	}
+
+	// the tracks are read by readLoop, a new slice is published
+	c.mu.Lock()
+	index = len(c.tracks)
+	c.tracks = append(append([]*Track(nil), c.tracks...), t)
+	c.mu.Unlock()
+
+	if t.rtpConn != nil {
+		c.wg.Add(2)
+		go c.readUDP(index, false, t.rtpConn)
+		go c.readUDP(index, true, t.rtcpConn)
+	}
+	return t, nil
+}
+
+// Play starts streaming of the tracks set up and keeps the session alive
+func (c *Client) Play(ctx context.Context) error {
+	if len(c.setUp()) == 0 {
+		return errNoTracks
+	}
+	if _, err := c.Do(ctx, "PLAY", c.aggregate(), map[string]string{"Range": "npt=0.000-"}); err != nil {
+		return err
+	}
+
+	c.wg.Add(1)
+	go c.keepAlive()
+	return nil
+}
+
+// Teardown ends the session and closes the connection
+func (c *Client) Teardown(ctx context.Context) error {
+	var err error
+	if c.Session() != "" {
+		_, err = c.Do(ctx, "TEARDOWN", c.aggregate(), nil)
+	}
+	c.Close()
+	return err
+}
+
+// Close closes the connection without TEARDOWN. The packet channel is closed.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		c.fail(errClosed)
+		c.conn.Close()
+		for _, t := range c.setUp() {
+			t.close()
+		}
+		c.wg.Wait()
+		close(c.packets)
+	})
+	return nil
+}
+
+// Tracks returns the tracks set up
+func (c *Client) Tracks() []*Track {
+	return append([]*Track(nil), c.setUp()...)
+}
+
+// Session returns the session identifier
+func (c *Client) Session() string {
+	c.reqMu.Lock()
+	defer c.reqMu.Unlock()
+	return c.session
+}
+
+// aggregate returns the aggregate control URL of the session description
+func (c *Client) aggregate() string {
+	c.reqMu.Lock()
+	defer c.reqMu.Unlock()
+	return c.aggregateURL
+}
+
+// Packets returns the channel of received packets, closed by Close
+func (c *Client) Packets() <-chan Packet {
+	return c.packets
+}
+
+// Done returns the channel closed when the connection fails or is closed
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error the connection failed with
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Stats returns counters of the received packets
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// WritePacket sends the RTP packet of the track, e.g. of the backchannel, interleaved
+// in the RTSP connection or to the server port
+func (c *Client) WritePacket(t *Track, data []byte) error {
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+
+	if t.rtpConn != nil {
+		_, err := t.rtpConn.WriteToUDP(data, &net.UDPAddr{IP: c.serverIP, Port: t.ServerRTPPort})
+		return err
+	}
+	if len(data) > 0xffff {
+		return errPacketTooLarge
+	}
+	return c.write(func(w io.Writer) error {
+		header := []byte{'$', byte(t.RTPChannel), byte(len(data) >> 8), byte(len(data))}
+		_, err := w.Write(append(header, data...))
+		return err
+	})
+}
+
+// Do sends the request with given headers and returns the response. It authenticates
+// when the server responds with 401 and returns *Error for status other than 200.
+func (c *Client) Do(ctx context.Context, method, url string, header map[string]string) (*Response, error) {
+	c.reqMu.Lock()
+	defer c.reqMu.Unlock()
+
+	if _, ok := ctx.Deadline(); !ok && c.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.timeout)
+		defer cancel()
+	}
+
+	for attempt := 0; ; attempt++ {
+		c.cseq++
+		req := &Request{Method: method, URL: url, Header: textproto.MIMEHeader{}}
+		for k, v := range header {
+			req.Header[k] = []string{v}
+		}
+		req.Header["CSeq"] = []string{strconv.Itoa(c.cseq)}
+		if c.opts.userAgent != "" {
+			req.Header["User-Agent"] = []string{c.opts.userAgent}
+		}
+		if c.session != "" {
+			req.Header["Session"] = []string{c.session}
+		}
+		if len(c.opts.require) > 0 && (method == "DESCRIBE" || method == "SETUP") {
+			req.Header["Require"] = []string{strings.Join(c.opts.require, ", ")}
+		}
+		if c.challenge != nil {
+			req.Header["Authorization"] = []string{c.challenge.Authorization(c.opts.username, c.opts.password, method, url)}
+		}
+
+		if err := c.write(func(w io.Writer) error { return req.write(w) }); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.wait(ctx, c.cseq)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == 401 && attempt == 0 && c.opts.username != "" {
+			challenge, err := auth.SelectChallenge(resp.Header["Www-Authenticate"])
+			if err != nil {
+				return resp, err
+			}
+			c.challenge = challenge
+			continue
+		}
+		if resp.StatusCode != 200 {
+			return resp, &Error{Method: method, StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+		return resp, nil
+	}
+}
+
+// write writes to the connection with the write timeout
+func (c *Client) write(fn func(w io.Writer) error) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.opts.timeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.opts.timeout))
+	}
+	if err := fn(c.conn); err != nil {
+		c.fail(err)
+		return err
+	}
+	return nil
+}
+
+// wait returns the response with the sequence number, late responses
+// of timed out requests are skipped
+func (c *Client) wait(ctx context.Context, cseq int) (*Response, error) {
+	for {
+		select {
+		case resp := <-c.responses:
+			if s := resp.Header.Get("CSeq"); s == "" || s == strconv.Itoa(cseq) {
+				return resp, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.done:
+			return nil, c.Err()
+		}
+	}
+}
+
+// readLoop reads responses and interleaved packets from the connection
+func (c *Client) readLoop() {
+	defer c.wg.Done()
+
+	for {
+		b, err := c.reader.Peek(1)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		if b[0] == '$' {
+			var header [4]byte
+			if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+				c.fail(err)
+				return
+			}
+			data := make([]byte, binary.BigEndian.Uint16(header[2:]))
+			if _, err := io.ReadFull(c.reader, data); err != nil {
+				c.fail(err)
+				return
+			}
+			c.dispatchChannel(int(header[1]), data)
+			continue
+		}
+
+		resp, err := readMessage(c.reader)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		// Requests of the server, e.g. ANNOUNCE, are not supported
+		if resp.StatusCode == 0 {
+			continue
+		}
+		select {
+		case c.responses <- resp:
+		default:
+		}
+	}
+}
+
+// readUDP reads packets of the track from the UDP socket
+func (c *Client) readUDP(track int, rtcp bool, conn *net.UDPConn) {
+	defer c.wg.Done()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		// Packets from other hosts are ignored
+		if c.serverIP != nil && !addr.IP.Equal(c.serverIP) {
+			continue
+		}
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		c.deliver(Packet{Track: track, RTCP: rtcp, Data: data})
+	}
+}
+
+func (c *Client) dispatchChannel(channel int, data []byte) {
+	for i, t := range c.setUp() {
+		switch channel {
+		case t.RTPChannel:
+			c.deliver(Packet{Track: i, Data: data})
+			return
+		case t.RTCPChannel:
+			c.deliver(Packet{Track: i, RTCP: true, Data: data})
+			return
+		}
+	}
+}
+
+// setUp returns the tracks set up, the slice is replaced by Setup and not modified
+func (c *Client) setUp() []*Track {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tracks
+}
+
+func (c *Client) deliver(p Packet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+
+	select {
+	case c.packets <- p:
+		c.stats.Packets++
+		c.stats.Bytes += uint64(len(p.Data))
+		c.stats.LastPacket = time.Now()
+	default:
+		c.stats.Dropped++
+	}
+}
+
+// keepAlive sends GET_PARAMETER, or OPTIONS if the server does not support it,
+// until the connection is closed
+func (c *Client) keepAlive() {
+	defer c.wg.Done()
+
+	c.reqMu.Lock()
+	interval := c.opts.keepAlive
+	if interval <= 0 {
+		interval = c.sessionTimeout / 2
+	}
+	method := "GET_PARAMETER"
+	if len(c.public) > 0 && !contains(c.public, method) {
+		method = "OPTIONS"
+	}
+	c.reqMu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+
+		url := c.aggregate()
+		if method == "OPTIONS" {
+			url = c.url
+		}
+		_, err := c.Do(context.Background(), method, url, nil)
+		if _, ok := err.(*Error); ok && method == "GET_PARAMETER" {
+			method = "OPTIONS"
+		}
+	}
+}
+
+// fail records the first error and closes the done channel
+func (c *Client) fail(err error) {
+	c.failOnce.Do(func() {
+		c.mu.Lock()
+		c.err = err
+		close(c.done)
+		c.mu.Unlock()
+	})
+}
+
+func (t *Track) close() {
+	if t.rtpConn != nil {
+		t.rtpConn.Close()
+		t.rtcpConn.Close()
+	}
+}
+
+// listenUDPPair listens on even RTP port and the next RTCP port
+func listenUDPPair() (*net.UDPConn, *net.UDPConn, error) {
+	for i := 0; i < 20; i++ {
+		rtp, err := net.ListenUDP("udp", &net.UDPAddr{})
+		if err != nil {
+			return nil, nil, err
+		}
+		port := rtp.LocalAddr().(*net.UDPAddr).Port
+		if port%2 != 0 {
+			rtp.Close()
+			continue
+		}
+		rtcp, err := net.ListenUDP("udp", &net.UDPAddr{Port: port + 1})
+		if err != nil {
+			rtp.Close()
+			continue
+		}
+		return rtp, rtcp, nil
+	}
+	return nil, nil, errNoUDPPorts
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,337 @@
+package rtsp
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testUser     = "admin"
+	testPassword = "secret"
+	testRealm    = "camera"
+	testNonce    = "0123456789abcdef"
+)
+
+const testSDP = "v=0\r\n" +
+	"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+	"s=test\r\n" +
+	"a=control:*\r\n" +
+	"m=video 0 RTP/AVP 96\r\n" +
+	"a=rtpmap:96 H264/90000\r\n" +
+	"a=fmtp:96 packetization-mode=1\r\n" +
+	"a=control:track1\r\n" +
+	"m=audio 0 RTP/AVP 0\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n" +
+	"a=control:track2\r\n"
+
+// testServer is the RTSP server stand-in accepting one connection
+type testServer struct {
+	t  *testing.T
+	ln net.Listener
+
+	mu       sync.Mutex
+	methods  []string
+	received [][]byte
+
+	done chan struct{}
+}
+
+func newTestServer(t *testing.T) *testServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{t: t, ln: ln, done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *testServer) url() string {
+	return "rtsp://" + s.ln.Addr().String() + "/stream"
+}
+
+func (s *testServer) close() {
+	s.ln.Close()
+	<-s.done
+}
+
+func (s *testServer) serve() {
+	defer close(s.done)
+
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	tp := textproto.NewReader(r)
+	var wmu sync.Mutex
+	write := func(b []byte) {
+		wmu.Lock()
+		defer wmu.Unlock()
+		conn.Write(b)
+	}
+	interleaved := func(channel int, data []byte) {
+		header := []byte{'$', byte(channel), 0, 0}
+		binary.BigEndian.PutUint16(header[2:], uint16(len(data)))
+		write(append(header, data...))
+	}
+
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return
+		}
+		if b[0] == '$' {
+			var header [4]byte
+			if _, err := io.ReadFull(r, header[:]); err != nil {
+				return
+			}
+			data := make([]byte, binary.BigEndian.Uint16(header[2:]))
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.received = append(s.received, data)
+			s.mu.Unlock()
+			continue
+		}
+
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		header, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		parts := strings.Fields(line)
+		method, uri := parts[0], parts[1]
+		s.mu.Lock()
+		s.methods = append(s.methods, method)
+		s.mu.Unlock()
+
+		resp := "RTSP/1.0 200 OK\r\nCSeq: " + header.Get("CSeq") + "\r\n"
+		var body string
+		switch {
+		case method != "OPTIONS" && !validDigest(header.Get("Authorization"), method, uri):
+			resp = "RTSP/1.0 401 Unauthorized\r\nCSeq: " + header.Get("CSeq") + "\r\n" +
+				`WWW-Authenticate: Digest realm="` + testRealm + `", nonce="` + testNonce + `", qop="auth"` + "\r\n"
+		case method == "OPTIONS":
+			resp += "Public: OPTIONS, DESCRIBE, SETUP, PLAY, TEARDOWN, GET_PARAMETER\r\n"
+		case method == "DESCRIBE":
+			resp += "Content-Base: " + s.url() + "/\r\nContent-Type: application/sdp\r\n"
+			body = testSDP
+		case method == "SETUP":
+			resp += "Transport: " + header.Get("Transport") + "\r\nSession: 12345678;timeout=60\r\n"
+			// packets of the first track arrive while the second one is set up
+			if strings.HasSuffix(uri, "track2") {
+				go func() {
+					for i := 0; i < 50; i++ {
+						interleaved(0, []byte{0x80, 96, 0, byte(i)})
+					}
+				}()
+			}
+		case method == "PLAY":
+			if header.Get("Session") != "12345678" {
+				resp = "RTSP/1.0 454 Session Not Found\r\nCSeq: " + header.Get("CSeq") + "\r\n"
+			}
+		}
+		if body != "" {
+			resp += fmt.Sprintf("Content-Length: %d\r\n", len(body))
+		}
+		write([]byte(resp + "\r\n" + body))
+
+		switch method {
+		case "PLAY":
+			interleaved(2, []byte{0x80, 0, 0, 1})
+			interleaved(3, []byte{0x80, 200, 0, 1})
+		case "TEARDOWN":
+			return
+		}
+	}
+}
+
+// validDigest checks the Digest Authorization header of the request
+func validDigest(authorization, method, uri string) bool {
+	if !strings.HasPrefix(authorization, "Digest ") {
+		return false
+	}
+	params := map[string]string{}
+	for _, p := range strings.Split(strings.TrimPrefix(authorization, "Digest "), ",") {
+		if kv := strings.SplitN(strings.TrimSpace(p), "=", 2); len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+	digest := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := digest(testUser + ":" + testRealm + ":" + testPassword)
+	ha2 := digest(method + ":" + uri)
+	expected := digest(ha1 + ":" + testNonce + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+	return params["username"] == testUser && params["uri"] == uri && params["response"] == expected
+}
+
+func TestClient(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := Dial(ctx, s.url(), WithCredentials(testUser, testPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	sd, err := c.Describe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sd.Media) != 2 || sd.Media[0].Encoding != "H264" || sd.Media[1].Encoding != "PCMU" {
+		t.Fatalf("unexpected media %+v", sd.Media)
+	}
+
+	video, err := c.Setup(ctx, sd.Media[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if video.URL != s.url()+"/track1" || video.RTPChannel != 0 || video.RTCPChannel != 1 {
+		t.Fatalf("unexpected video track %+v", video)
+	}
+	audio, err := c.Setup(ctx, sd.Media[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audio.RTPChannel != 2 || audio.RTCPChannel != 3 {
+		t.Fatalf("unexpected audio track %+v", audio)
+	}
+	if c.Session() != "12345678" {
+		t.Fatalf("session %q", c.Session())
+	}
+	if n := len(c.Tracks()); n != 2 {
+		t.Fatalf("%d tracks", n)
+	}
+
+	if err := c.Play(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var audioRTP, audioRTCP bool
+	for !audioRTP || !audioRTCP {
+		select {
+		case p := <-c.Packets():
+			switch {
+			case p.Track == 1 && p.RTCP:
+				audioRTCP = true
+			case p.Track == 1:
+				audioRTP = true
+			case p.Track != 0 || p.RTCP:
+				t.Fatalf("unexpected packet %+v", p)
+			}
+		case <-ctx.Done():
+			t.Fatal("packets of the audio track not received")
+		}
+	}
+
+	if err := c.WritePacket(audio, []byte{0x80, 0, 0, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Teardown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	methods := strings.Join(s.methods, " ")
+	expected := "OPTIONS DESCRIBE DESCRIBE SETUP SETUP PLAY TEARDOWN"
+	if methods != expected {
+		t.Errorf("methods %q, expected %q", methods, expected)
+	}
+	if len(s.received) != 1 || s.received[0][3] != 2 {
+		t.Errorf("interleaved packets received by the server %v", s.received)
+	}
+	if c.Stats().Packets == 0 {
+		t.Error("no packets counted")
+	}
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := Dial(ctx, "rtsp://"+testUser+":wrong@"+s.ln.Addr().String()+"/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, err = c.Describe(ctx)
+	if e, ok := err.(*Error); !ok || e.StatusCode != 401 {
+		t.Fatalf("expected 401, got %v", err)
+	}
+}
+
+func TestClientKeepAlive(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := Dial(ctx, s.url(), WithCredentials(testUser, testPassword), WithKeepAlive(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	sd, err := c.Describe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Setup(ctx, sd.Media[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Play(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the session state is updated while keep alive requests are sent
+	for i := 0; i < 5; i++ {
+		if _, err := c.Options(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Describe(ctx); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := c.Teardown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if methods := strings.Join(s.methods, " "); !strings.Contains(methods, "GET_PARAMETER") {
+		t.Errorf("no keep alive in %q", methods)
+	}
+}
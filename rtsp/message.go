@@ -0,0 +1,142 @@
+package rtsp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var errMalformedResponse = errors.New("Malformed RTSP response")
+
+// Maximum size of the message body accepted
+const maxBodySize = 1 << 20
+
+// Request is the RTSP request
+type Request struct {
+	Method string
+	URL    string
+	Header textproto.MIMEHeader
+	Body   []byte
+}
+
+// Response is the RTSP response
+type Response struct {
+	StatusCode int
+	Status     string
+	Header     textproto.MIMEHeader
+	Body       []byte
+}
+
+// Error is returned for responses with status other than 200
+type Error struct {
+	Method     string
+	StatusCode int
+	Status     string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("RTSP %s failed: %d %s", e.Method, e.StatusCode, e.Status)
+}
+
+// write writes the request, headers are sorted to keep the output stable
+func (r *Request) write(w io.Writer) error {
+	b := bytes.Buffer{}
+	b.WriteString(r.Method + " " + r.URL + " RTSP/1.0\r\n")
+
+	keys := make([]string, 0, len(r.Header))
+	for k := range r.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range r.Header[k] {
+			b.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	if len(r.Body) > 0 {
+		b.WriteString("Content-Length: " + strconv.Itoa(len(r.Body)) + "\r\n")
+	}
+	b.WriteString("\r\n")
+	b.Write(r.Body)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// readMessage reads RTSP response, or request sent by the server which is returned
+// with zero StatusCode
+func readMessage(r *bufio.Reader) (*Response, error) {
+	tp := textproto.NewReader(r)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &Response{}
+	if strings.HasPrefix(line, "RTSP/") {
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) < 2 {
+			return nil, errMalformedResponse
+		}
+		if resp.StatusCode, err = strconv.Atoi(parts[1]); err != nil {
+			return nil, errMalformedResponse
+		}
+		if len(parts) > 2 {
+			resp.Status = parts[2]
+		}
+	}
+
+	if resp.Header, err = tp.ReadMIMEHeader(); err != nil {
+		return nil, err
+	}
+
+	if cl := resp.Header.Get("Content-Length"); cl != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(cl))
+		if err != nil || n < 0 || n > maxBodySize {
+			return nil, errMalformedResponse
+		}
+		resp.Body = make([]byte, n)
+		if _, err := io.ReadFull(r, resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// headerParams parses header value like "12345678;timeout=60" into the first
+// value and the parameters
+func headerParams(s string) (string, map[string]string) {
+	parts := strings.Split(s, ";")
+	params := map[string]string{}
+	for _, p := range parts[1:] {
+		p = strings.TrimSpace(p)
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[strings.ToLower(p[:i])] = p[i+1:]
+		} else if p != "" {
+			params[strings.ToLower(p)] = ""
+		}
+	}
+	return strings.TrimSpace(parts[0]), params
+}
+
+// portRange parses "5000-5001"
+func portRange(s string) (int, int, bool) {
+	parts := strings.SplitN(s, "-", 2)
+	a, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	b := a + 1
+	if len(parts) == 2 {
+		if b, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, false
+		}
+	}
+	return a, b, true
+}
@@ -0,0 +1,175 @@
+package rtsp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SessionDescription is the SDP returned by DESCRIBE
+type SessionDescription struct {
+	// Aggregate control URL, empty if not given
+	Control string
+
+	Media []Media
+
+	// SDP as received
+	Raw string
+}
+
+// Media is the media description of the SDP
+type Media struct {
+	// Type is "video", "audio", "application", etc.
+	Type string
+
+	// Transport protocol, e.g. "RTP/AVP"
+	Protocol string
+
+	// First payload type of the media
+	PayloadType int
+
+	// Encoding name, clock rate and channels of the rtpmap attribute,
+	// e.g. "H264", 90000 and 0
+	Encoding  string
+	ClockRate int
+	Channels  int
+
+	// Format parameters of the fmtp attribute, e.g. "sprop-parameter-sets"
+	FMTP map[string]string
+
+	// Control URL of the media, relative to the aggregate one
+	Control string
+
+	// Direction is "sendonly", "recvonly", "sendrecv" or "inactive" from the client's
+	// point of view, empty if not given. ONVIF backchannel media is "sendonly".
+	Direction string
+
+	// Attributes contains all "a=" lines of the media without the prefix
+	Attributes []string
+}
+
+// Backchannel returns the ONVIF backchannel media, nil if the server has none.
+// DESCRIBE must be sent with Require: www.onvif.org/ver20/backchannel.
+func (sd *SessionDescription) Backchannel() *Media {
+	for i := range sd.Media {
+		if sd.Media[i].Type == "audio" && sd.Media[i].Direction == "sendonly" {
+			return &sd.Media[i]
+		}
+	}
+	return nil
+}
+
+// ParseSDP parses session description
+func ParseSDP(s string) *SessionDescription {
+	sd := &SessionDescription{Raw: s}
+
+	var m *Media
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if len(line) < 2 || line[1] != '=' {
+			continue
+		}
+		value := line[2:]
+
+		switch line[0] {
+		case 'm':
+			sd.Media = append(sd.Media, parseMediaLine(value))
+			m = &sd.Media[len(sd.Media)-1]
+		case 'a':
+			name, arg := value, ""
+			if i := strings.IndexByte(value, ':'); i >= 0 {
+				name, arg = value[:i], value[i+1:]
+			}
+			if m == nil {
+				if name == "control" {
+					sd.Control = strings.TrimSpace(arg)
+				}
+				continue
+			}
+			m.Attributes = append(m.Attributes, value)
+			m.parseAttribute(name, strings.TrimSpace(arg))
+		}
+	}
+	return sd
+}
+
+// parseMediaLine parses "video 0 RTP/AVP 96"
+func parseMediaLine(s string) Media {
+	m := Media{PayloadType: -1}
+	fields := strings.Fields(s)
+	if len(fields) > 0 {
+		m.Type = fields[0]
+	}
+	if len(fields) > 2 {
+		m.Protocol = fields[2]
+	}
+	if len(fields) > 3 {
+		if pt, err := strconv.Atoi(fields[3]); err == nil {
+			m.PayloadType = pt
+		}
+	}
+	// Static payload types may come without rtpmap
+	switch m.PayloadType {
+	case 0:
+		m.Encoding, m.ClockRate = "PCMU", 8000
+	case 8:
+		m.Encoding, m.ClockRate = "PCMA", 8000
+	case 26:
+		m.Encoding, m.ClockRate = "JPEG", 90000
+	}
+	return m
+}
+
+func (m *Media) parseAttribute(name, arg string) {
+	switch name {
+	case "control":
+		m.Control = arg
+	case "sendonly", "recvonly", "sendrecv", "inactive":
+		m.Direction = name
+	case "rtpmap":
+		// 96 H264/90000 or 0 PCMU/8000/1
+		fields := strings.Fields(arg)
+		if len(fields) < 2 || fields[0] != strconv.Itoa(m.PayloadType) {
+			return
+		}
+		parts := strings.Split(fields[1], "/")
+		m.Encoding = parts[0]
+		if len(parts) > 1 {
+			m.ClockRate, _ = strconv.Atoi(parts[1])
+		}
+		if len(parts) > 2 {
+			m.Channels, _ = strconv.Atoi(parts[2])
+		}
+	case "fmtp":
+		// 96 packetization-mode=1;profile-level-id=42e01f
+		i := strings.IndexByte(arg, ' ')
+		if i < 0 || arg[:i] != strconv.Itoa(m.PayloadType) {
+			return
+		}
+		m.FMTP = map[string]string{}
+		for _, p := range strings.Split(arg[i+1:], ";") {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			if j := strings.IndexByte(p, '='); j >= 0 {
+				m.FMTP[strings.ToLower(p[:j])] = p[j+1:]
+			} else {
+				m.FMTP[strings.ToLower(p)] = ""
+			}
+		}
+	}
+}
+
+// controlURL resolves the control attribute against the base URL
+func controlURL(base, control string) string {
+	switch {
+	case control == "" || control == "*":
+		return base
+	case strings.HasPrefix(strings.ToLower(control), "rtsp://") || strings.HasPrefix(strings.ToLower(control), "rtsps://"):
+		return control
+	case strings.HasSuffix(base, "/"):
+		return base + control
+	default:
+		return base + "/" + control
+	}
+}
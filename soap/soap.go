@@ -12,6 +12,8 @@ import (
 	"net"
 	"net/http"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -117,6 +119,119 @@ type SOAPFaultDetail struct {
 	XMLName xml.Name `xml:"http://www.w3.org/2003/05/soap-envelope Detail"`
 
 	Text string
+
+	// Fault specific elements, use Element.Decode to get typed faults
+	Content []Element `xml:",any"`
+}
+
+// Element is a generic XML element with resolved namespaces
+type Element struct {
+	XMLName Name
+
+	Attr []Attr `xml:",any,attr"`
+
+	Value string `xml:",chardata"`
+
+	Children []Element `xml:",any"`
+
+	// Namespaces of the prefixes used in the value and attribute values,
+	// e.g. of xsi:type="tt:CellMotionEngine", marshalled as xmlns declarations
+	Namespaces map[string]string `xml:"-"`
+}
+
+// ResolveNamespaces implements NamespaceResolver
+func (e *Element) ResolveNamespaces(scope map[string]string) {
+	e.Namespaces = UsedNamespaces(e.Value, scope)
+	for _, a := range e.Attr {
+		for prefix, uri := range UsedNamespaces(a.Value, scope) {
+			if e.Namespaces == nil {
+				e.Namespaces = map[string]string{}
+			}
+			e.Namespaces[prefix] = uri
+		}
+	}
+}
+
+// UsedNamespaces returns the bindings of the scope of the prefixes used in
+// the text, e.g. QName values or XPath expressions
+func UsedNamespaces(text string, scope map[string]string) map[string]string {
+	var namespaces map[string]string
+	for i := strings.IndexByte(text, ':'); i >= 0; {
+		start := i
+		for start > 0 && isPrefixChar(text[start-1]) {
+			start--
+		}
+		if uri, ok := scope[text[start:i]]; ok && start < i {
+			if namespaces == nil {
+				namespaces = map[string]string{}
+			}
+			namespaces[text[start:i]] = uri
+		}
+		next := strings.IndexByte(text[i+1:], ':')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return namespaces
+}
+
+func isPrefixChar(c byte) bool {
+	return c == '_' || c == '-' || c == '.' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// MarshalXML implements xml.Marshaler
+func (e Element) MarshalXML(enc *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{Name: xml.Name{Space: e.XMLName.Space, Local: e.XMLName.Local}}
+	for _, a := range e.Attr {
+		// Namespace declarations are restored by the encoder from the names
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Space: a.Name.Space, Local: a.Name.Local}, Value: a.Value})
+	}
+	// except of the prefixes used in the values
+	prefixes := make([]string, 0, len(e.Namespaces))
+	for prefix := range e.Namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: e.Namespaces[prefix]})
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := enc.EncodeToken(xml.CharData(e.Value)); err != nil {
+		return err
+	}
+	for _, c := range e.Children {
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// Decode decodes the element into v
+func (e *Element) Decode(v interface{}) error {
+	b, err := xml.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// HTTPError is returned for HTTP error responses without SOAP fault, e.g. 401 Unauthorized
+type HTTPError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return "HTTP request failed: " + e.Status
 }
 
 type SOAPFault struct {
@@ -380,7 +495,7 @@ func (s *Client) call(ctx context.Context, xaddr string, soapAction string, requ
 		req.SetBasicAuth(s.opts.auth.Login, s.opts.auth.Password)
 	}
 
-	req.WithContext(ctx)
+	req = req.WithContext(ctx)
 
 	req.Header.Add("Content-Type", "application/soap+xml; charset=utf-8; action=\""+soapAction+"\"")
 	req.Header.Add("Soapaction", "\""+soapAction+"\"")
@@ -417,6 +532,9 @@ func (s *Client) call(ctx context.Context, xaddr string, soapAction string, requ
 	dec := NewDecoder(res.Body)
 
 	if err := dec.Decode(respEnvelope); err != nil {
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			return &HTTPError{StatusCode: res.StatusCode, Status: res.Status}
+		}
 		return err
 	}
 
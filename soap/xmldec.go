@@ -2227,6 +2227,13 @@ type Unmarshaler interface {
 	UnmarshalXML(d *Decoder, start StartElement) error
 }
 
+// NamespaceResolver is the interface implemented by objects whose content refers
+// to name space prefixes, e.g. QName values. ResolveNamespaces is called after the
+// element is unmarshaled with the bindings in scope of the element.
+type NamespaceResolver interface {
+	ResolveNamespaces(scope map[string]string)
+}
+
 // UnmarshalerAttr is the interface implemented by objects that can unmarshal
 // an XML attribute description of themselves.
 //
@@ -2350,10 +2357,11 @@ func (d *Decoder) unmarshalAttr(val reflect.Value, attr Attr) error {
 }
 
 var (
-	attrType            = reflect.TypeOf(Attr{})
-	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
-	unmarshalerAttrType = reflect.TypeOf((*UnmarshalerAttr)(nil)).Elem()
-	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	attrType              = reflect.TypeOf(Attr{})
+	unmarshalerType       = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	unmarshalerAttrType   = reflect.TypeOf((*UnmarshalerAttr)(nil)).Elem()
+	namespaceResolverType = reflect.TypeOf((*NamespaceResolver)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
 )
 
 // Unmarshal a single XML element into val.
@@ -2399,6 +2407,14 @@ func (d *Decoder) unmarshal(val reflect.Value, start *StartElement) error {
 		if pv.CanInterface() && pv.Type().Implements(unmarshalerType) {
 			return d.unmarshalInterface(pv.Interface().(Unmarshaler), start)
 		}
+		if pv.CanInterface() && pv.Type().Implements(namespaceResolverType) {
+			r := pv.Interface().(NamespaceResolver)
+			scope := make(map[string]string, len(d.ns))
+			for prefix, uri := range d.ns {
+				scope[prefix] = uri
+			}
+			defer r.ResolveNamespaces(scope)
+		}
 	}
 
 	if val.CanInterface() && val.Type().Implements(textUnmarshalerType) {